@@ -0,0 +1,312 @@
+package apu
+
+import "github.com/raidancampbell/goby/mem"
+
+const (
+	// ClockHz is the rate of the 4MHz clock that drives the APU
+	ClockHz = 4194304
+
+	// the frame sequencer runs at 512Hz, clocking length counters, the sweep, and envelopes
+	sequencerPeriod = ClockHz / 512
+
+	// DefaultSampleRate is the output rate used unless the host asks for something else
+	DefaultSampleRate = 48000
+
+	// amplitude is the peak value of a full-volume mix, leaving headroom in the int16 range
+	amplitude = 16000
+
+	regNR10 = 0xFF10
+	regNR52 = 0xFF26
+	regWave = 0xFF30
+	regEnd  = 0xFF3F
+)
+
+// readMasks are OR'd into register reads: unused and write-only bits read back as set. FF10-FF2F
+var readMasks = [0x20]byte{
+	0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10-NR14
+	0xFF, 0x3F, 0x00, 0xFF, 0xBF, // NR20-NR24
+	0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30-NR34
+	0xFF, 0xFF, 0x00, 0x00, 0xBF, // NR40-NR44
+	0x00, 0x00, 0x70, // NR50-NR52
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // FF27-FF2F unused
+}
+
+// Sink receives the APU's output, e.g. an SDL audio queue
+type Sink interface {
+	//Queue takes interleaved left/right samples
+	Queue(samples []int16) error
+}
+
+// APU is the audio processing unit: two square channels (the first with a frequency sweep),
+// a wave channel, and a noise channel, mixed down to stereo and resampled to the host's sample rate
+type APU struct {
+	ch1 square
+	ch2 square
+	ch3 wave
+	ch4 noise
+
+	regs    [0x20]byte // raw register values, FF10-FF2F
+	powered bool       // NR52 bit 7
+
+	seqTimer int
+	seqStep  byte
+
+	sampleRate      int
+	cyclesPerSample float64
+	sampleCycles    float64 // cycles accumulated towards the next output sample
+	sumLeft         float64 // mix accumulated over sampleCycles, for a box filter
+	sumRight        float64
+	capLeft         float64 // high pass filter state, to remove the DACs' DC offset
+	capRight        float64
+	buf             []int16
+}
+
+// New creates an APU producing stereo samples at the given rate, e.g. 44100 or 48000,
+// and maps its registers and wave RAM into memory at FF10-FF3F
+func New(ram *mem.RAM, sampleRate int) *APU {
+	a := &APU{seqTimer: sequencerPeriod}
+	a.ch1.hasSweep = true
+	a.SetSampleRate(float64(sampleRate))
+	a.sampleRate = sampleRate
+	ram.Map(regNR10, regEnd, a)
+	return a
+}
+
+// SampleRate returns the nominal output rate given to New
+func (a *APU) SampleRate() int {
+	return a.sampleRate
+}
+
+// SetOutputRate changes the nominal output rate given to New, e.g. to match an audio device
+func (a *APU) SetOutputRate(sampleRate int) {
+	a.sampleRate = sampleRate
+	a.SetSampleRate(float64(sampleRate))
+}
+
+// SetSampleRate changes the effective output rate.
+// small adjustments around the nominal rate keep an audio queue from draining or overfilling
+func (a *APU) SetSampleRate(rate float64) {
+	a.cyclesPerSample = ClockHz / rate
+}
+
+// Samples returns the interleaved left/right samples produced since the last call
+func (a *APU) Samples() []int16 {
+	out := a.buf
+	a.buf = make([]int16, 0, len(out))
+	return out
+}
+
+// Step advances the APU by the given number of 4MHz cycles
+func (a *APU) Step(cycles int) {
+	if a.powered {
+		a.ch1.step(cycles)
+		a.ch2.step(cycles)
+		a.ch3.step(cycles)
+		a.ch4.step(cycles)
+
+		a.seqTimer -= cycles
+		for a.seqTimer <= 0 {
+			a.seqTimer += sequencerPeriod
+			a.clockSequencer()
+		}
+	}
+
+	left, right := a.mix()
+	a.sumLeft += left * float64(cycles)
+	a.sumRight += right * float64(cycles)
+	a.sampleCycles += float64(cycles)
+	for a.sampleCycles >= a.cyclesPerSample {
+		a.emit(a.sumLeft/a.sampleCycles, a.sumRight/a.sampleCycles)
+		a.sampleCycles -= a.cyclesPerSample
+		// the remainder belongs to the next sample
+		a.sumLeft = left * a.sampleCycles
+		a.sumRight = right * a.sampleCycles
+	}
+}
+
+// clockSequencer runs one of the frame sequencer's 8 steps
+// length counters are clocked at 256Hz, the sweep at 128Hz, and envelopes at 64Hz
+func (a *APU) clockSequencer() {
+	if a.seqStep%2 == 0 {
+		a.ch1.enabled = a.ch1.length.clock() && a.ch1.enabled
+		a.ch2.enabled = a.ch2.length.clock() && a.ch2.enabled
+		a.ch3.enabled = a.ch3.length.clock() && a.ch3.enabled
+		a.ch4.enabled = a.ch4.length.clock() && a.ch4.enabled
+	}
+	if a.seqStep == 2 || a.seqStep == 6 {
+		a.ch1.clockSweep()
+	}
+	if a.seqStep == 7 {
+		a.ch1.env.clock()
+		a.ch2.env.clock()
+		a.ch4.env.clock()
+	}
+	a.seqStep = (a.seqStep + 1) % 8
+}
+
+// mix converts each channel through its DAC, and pans and scales them per NR51 and NR50
+func (a *APU) mix() (float64, float64) {
+	if !a.powered {
+		return 0, 0
+	}
+	outputs := [4]byte{a.ch1.output(), a.ch2.output(), a.ch3.output(), a.ch4.output()}
+	dacs := [4]bool{a.ch1.dacOn, a.ch2.dacOn, a.ch3.dacOn, a.ch4.dacOn}
+	nr50 := a.regs[0x14]
+	nr51 := a.regs[0x15]
+
+	var left, right float64
+	for i, out := range outputs {
+		if !dacs[i] {
+			continue
+		}
+		analog := float64(out)/7.5 - 1
+		if nr51&(0x10<<uint(i)) != 0 {
+			left += analog
+		}
+		if nr51&(0x01<<uint(i)) != 0 {
+			right += analog
+		}
+	}
+	left *= float64((nr50>>4)&0x07+1) / 8
+	right *= float64(nr50&0x07+1) / 8
+	return left / 4, right / 4
+}
+
+// emit high pass filters a stereo sample, the way the output capacitor does on hardware, and appends it
+func (a *APU) emit(left, right float64) {
+	const charge = 0.996
+	outLeft := left - a.capLeft
+	a.capLeft = left - outLeft*charge
+	outRight := right - a.capRight
+	a.capRight = right - outRight*charge
+	a.buf = append(a.buf, int16(outLeft*amplitude), int16(outRight*amplitude))
+}
+
+func (a *APU) Read(addr uint16) byte {
+	switch {
+	case addr >= regWave:
+		return a.ch3.ram[addr-regWave]
+	case addr == regNR52:
+		status := a.regs[addr-regNR10] | readMasks[addr-regNR10]
+		for i, on := range []bool{a.ch1.enabled, a.ch2.enabled, a.ch3.enabled, a.ch4.enabled} {
+			if on {
+				status |= 1 << uint(i)
+			}
+		}
+		return status
+	default:
+		return a.regs[addr-regNR10] | readMasks[addr-regNR10]
+	}
+}
+
+func (a *APU) Write(addr uint16, val byte) {
+	if addr >= regWave {
+		a.ch3.ram[addr-regWave] = val
+		return
+	}
+	if addr == regNR52 {
+		// only the power bit is writable. the channel status bits are computed on read
+		a.setPower(val&0x80 != 0)
+		a.regs[addr-regNR10] = val & 0x80
+		return
+	}
+	// while powered off, every register other than NR52 is read-only
+	if !a.powered {
+		return
+	}
+	a.regs[addr-regNR10] = val
+
+	switch addr {
+	// channel 1
+	case 0xFF10:
+		a.ch1.sweepPeriod = (val >> 4) & 0x07
+		a.ch1.sweepNegate = val&0x08 != 0
+		a.ch1.sweepShift = val & 0x07
+	case 0xFF11:
+		a.ch1.duty = val >> 6
+		a.ch1.length.counter = 64 - int(val&0x3F)
+	case 0xFF12:
+		a.ch1.env.load(val)
+		a.ch1.dacOn = val&0xF8 != 0
+		a.ch1.enabled = a.ch1.enabled && a.ch1.dacOn
+	case 0xFF13:
+		a.ch1.freq = a.ch1.freq&0x700 | uint16(val)
+	case 0xFF14:
+		a.ch1.freq = a.ch1.freq&0xFF | uint16(val&0x07)<<8
+		a.ch1.length.enabled = val&0x40 != 0
+		if val&0x80 != 0 {
+			a.ch1.trigger()
+		}
+
+	// channel 2
+	case 0xFF16:
+		a.ch2.duty = val >> 6
+		a.ch2.length.counter = 64 - int(val&0x3F)
+	case 0xFF17:
+		a.ch2.env.load(val)
+		a.ch2.dacOn = val&0xF8 != 0
+		a.ch2.enabled = a.ch2.enabled && a.ch2.dacOn
+	case 0xFF18:
+		a.ch2.freq = a.ch2.freq&0x700 | uint16(val)
+	case 0xFF19:
+		a.ch2.freq = a.ch2.freq&0xFF | uint16(val&0x07)<<8
+		a.ch2.length.enabled = val&0x40 != 0
+		if val&0x80 != 0 {
+			a.ch2.trigger()
+		}
+
+	// channel 3
+	case 0xFF1A:
+		a.ch3.dacOn = val&0x80 != 0
+		a.ch3.enabled = a.ch3.enabled && a.ch3.dacOn
+	case 0xFF1B:
+		a.ch3.length.counter = 256 - int(val)
+	case 0xFF1C:
+		a.ch3.volume = (val >> 5) & 0x03
+	case 0xFF1D:
+		a.ch3.freq = a.ch3.freq&0x700 | uint16(val)
+	case 0xFF1E:
+		a.ch3.freq = a.ch3.freq&0xFF | uint16(val&0x07)<<8
+		a.ch3.length.enabled = val&0x40 != 0
+		if val&0x80 != 0 {
+			a.ch3.trigger()
+		}
+
+	// channel 4
+	case 0xFF20:
+		a.ch4.length.counter = 64 - int(val&0x3F)
+	case 0xFF21:
+		a.ch4.env.load(val)
+		a.ch4.dacOn = val&0xF8 != 0
+		a.ch4.enabled = a.ch4.enabled && a.ch4.dacOn
+	case 0xFF22:
+		a.ch4.shift = val >> 4
+		a.ch4.narrow = val&0x08 != 0
+		a.ch4.divisor = val & 0x07
+	case 0xFF23:
+		a.ch4.length.enabled = val&0x40 != 0
+		if val&0x80 != 0 {
+			a.ch4.trigger()
+		}
+	}
+}
+
+// setPower turns the APU on or off.  Powering off clears every register and silences every channel.
+// wave RAM is left intact
+func (a *APU) setPower(on bool) {
+	if on == a.powered {
+		return
+	}
+	a.powered = on
+	if on {
+		a.seqStep = 0
+		a.seqTimer = sequencerPeriod
+		return
+	}
+	wavRAM := a.ch3.ram
+	a.ch1 = square{hasSweep: true}
+	a.ch2 = square{}
+	a.ch3 = wave{ram: wavRAM}
+	a.ch4 = noise{}
+	a.regs = [0x20]byte{}
+}
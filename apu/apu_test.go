@@ -0,0 +1,61 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPU_powerOffClearsRegisters(t *testing.T) {
+	a := New(&mem.RAM{}, DefaultSampleRate)
+	a.Write(regNR52, 0x80)
+	a.Write(0xFF12, 0xF0) // channel 1 DAC on, full volume
+	a.Write(0xFF14, 0x80) // trigger
+	a.Write(0xFF30, 0xAB)
+	assert.Equal(t, byte(0xF1), a.Read(regNR52))
+	assert.Equal(t, byte(0xF0), a.Read(0xFF12))
+
+	a.Write(regNR52, 0x00)
+	assert.Equal(t, byte(0x70), a.Read(regNR52))
+	assert.Equal(t, byte(0x00), a.Read(0xFF12))
+	// wave RAM survives, and registers are read-only until powered back on
+	assert.Equal(t, byte(0xAB), a.Read(0xFF30))
+	a.Write(0xFF12, 0xF0)
+	assert.Equal(t, byte(0x00), a.Read(0xFF12))
+}
+
+func TestAPU_lengthCounterDisablesChannel(t *testing.T) {
+	a := New(&mem.RAM{}, DefaultSampleRate)
+	a.Write(regNR52, 0x80)
+	a.Write(0xFF17, 0xF0) // channel 2 DAC on
+	a.Write(0xFF16, 0x3E) // length of 64-62 = 2 ticks
+	a.Write(0xFF19, 0xC0) // trigger with the length counter enabled
+	assert.Equal(t, byte(0xF2), a.Read(regNR52))
+
+	// the length counter is clocked on every other step of the 512Hz sequencer
+	a.Step(sequencerPeriod * 2)
+	assert.Equal(t, byte(0xF2), a.Read(regNR52))
+	a.Step(sequencerPeriod * 2)
+	assert.Equal(t, byte(0xF0), a.Read(regNR52))
+}
+
+func TestNoise_narrowLFSR(t *testing.T) {
+	n := noise{lfsr: 0x7FFF, narrow: true}
+	seen := map[uint16]bool{}
+	// a 7-bit LFSR repeats every 127 clocks
+	for i := 0; i < 127; i++ {
+		n.clockLFSR()
+		seen[n.lfsr&0x7F] = true
+	}
+	assert.Len(t, seen, 127)
+}
+
+func TestAPU_resamples(t *testing.T) {
+	a := New(&mem.RAM{}, 48000)
+	a.Step(ClockHz / 60)
+	samples := a.Samples()
+	// one 60th of a second of stereo audio
+	assert.InDelta(t, 2*48000/60, len(samples), 2)
+	assert.Empty(t, a.Samples())
+}
@@ -0,0 +1,259 @@
+package apu
+
+// dutyPatterns are the square wave shapes selectable by NRx1 bits 6-7: 12.5%, 25%, 50% and 75%
+var dutyPatterns = [4][8]byte{
+	{0, 0, 0, 0, 0, 0, 0, 1},
+	{1, 0, 0, 0, 0, 0, 0, 1},
+	{1, 0, 0, 0, 0, 1, 1, 1},
+	{0, 1, 1, 1, 1, 1, 1, 0},
+}
+
+// noiseDivisors are the base periods of the noise channel, selected by NR43 bits 0-2
+var noiseDivisors = [8]int{8, 16, 32, 48, 64, 80, 96, 112}
+
+// lengthCounter silences a channel after a set number of 256Hz ticks, if enabled
+type lengthCounter struct {
+	counter int
+	enabled bool
+}
+
+// clock ticks the counter down, and returns false when the channel should be disabled
+func (l *lengthCounter) clock() bool {
+	if !l.enabled || l.counter == 0 {
+		return true
+	}
+	l.counter--
+	return l.counter != 0
+}
+
+// envelope ramps a channel's volume up or down at 64Hz
+type envelope struct {
+	initial byte // NRx2 bits 4-7
+	up      bool // NRx2 bit 3
+	period  byte // NRx2 bits 0-2. 0 disables the envelope
+	volume  byte
+	timer   byte
+}
+
+func (e *envelope) load(nrx2 byte) {
+	e.initial = nrx2 >> 4
+	e.up = nrx2&0x08 != 0
+	e.period = nrx2 & 0x07
+}
+
+func (e *envelope) trigger() {
+	e.volume = e.initial
+	e.timer = e.period
+}
+
+func (e *envelope) clock() {
+	if e.period == 0 {
+		return
+	}
+	if e.timer > 0 {
+		e.timer--
+	}
+	if e.timer > 0 {
+		return
+	}
+	e.timer = e.period
+	if e.up && e.volume < 15 {
+		e.volume++
+	} else if !e.up && e.volume > 0 {
+		e.volume--
+	}
+}
+
+// square is channels 1 and 2.  Only channel 1 has a frequency sweep
+type square struct {
+	enabled bool
+	dacOn   bool
+	duty    byte
+	dutyPos byte
+	freq    uint16 // 11 bits, NRx3 and NRx4 bits 0-2
+	timer   int
+	length  lengthCounter
+	env     envelope
+
+	hasSweep     bool
+	sweepPeriod  byte // NR10 bits 4-6
+	sweepNegate  bool // NR10 bit 3
+	sweepShift   byte // NR10 bits 0-2
+	sweepTimer   byte
+	sweepEnabled bool
+	shadowFreq   uint16
+}
+
+func (s *square) period() int {
+	return (2048 - int(s.freq)) * 4
+}
+
+func (s *square) step(cycles int) {
+	s.timer -= cycles
+	for s.timer <= 0 {
+		s.timer += s.period()
+		s.dutyPos = (s.dutyPos + 1) % 8
+	}
+}
+
+func (s *square) output() byte {
+	if !s.enabled || !s.dacOn {
+		return 0
+	}
+	return dutyPatterns[s.duty][s.dutyPos] * s.env.volume
+}
+
+func (s *square) trigger() {
+	s.enabled = s.dacOn
+	if s.length.counter == 0 {
+		s.length.counter = 64
+	}
+	s.timer = s.period()
+	s.env.trigger()
+
+	if !s.hasSweep {
+		return
+	}
+	s.shadowFreq = s.freq
+	s.sweepTimer = s.sweepPeriod
+	if s.sweepTimer == 0 {
+		s.sweepTimer = 8
+	}
+	s.sweepEnabled = s.sweepPeriod != 0 || s.sweepShift != 0
+	if s.sweepShift != 0 {
+		s.sweepCalc()
+	}
+}
+
+// sweepCalc computes the next swept frequency, disabling the channel if it overflows 11 bits
+func (s *square) sweepCalc() uint16 {
+	delta := s.shadowFreq >> s.sweepShift
+	next := s.shadowFreq + delta
+	if s.sweepNegate {
+		next = s.shadowFreq - delta
+	}
+	if next > 2047 {
+		s.enabled = false
+	}
+	return next
+}
+
+func (s *square) clockSweep() {
+	if s.sweepTimer > 0 {
+		s.sweepTimer--
+	}
+	if s.sweepTimer > 0 {
+		return
+	}
+	s.sweepTimer = s.sweepPeriod
+	if s.sweepTimer == 0 {
+		s.sweepTimer = 8
+	}
+	if !s.sweepEnabled || s.sweepPeriod == 0 {
+		return
+	}
+	next := s.sweepCalc()
+	if next <= 2047 && s.sweepShift != 0 {
+		s.shadowFreq = next
+		s.freq = next
+		// the new frequency is checked for overflow again, but not written back
+		s.sweepCalc()
+	}
+}
+
+// wave is channel 3, which plays back 32 4-bit samples from wave RAM
+type wave struct {
+	enabled bool
+	dacOn   bool   // NR30 bit 7
+	volume  byte   // NR32 bits 5-6
+	freq    uint16 // 11 bits, NR33 and NR34 bits 0-2
+	timer   int
+	pos     byte
+	length  lengthCounter
+	ram     [16]byte // FF30-FF3F
+}
+
+func (w *wave) period() int {
+	return (2048 - int(w.freq)) * 2
+}
+
+func (w *wave) step(cycles int) {
+	w.timer -= cycles
+	for w.timer <= 0 {
+		w.timer += w.period()
+		w.pos = (w.pos + 1) % 32
+	}
+}
+
+func (w *wave) output() byte {
+	if !w.enabled || !w.dacOn || w.volume == 0 {
+		return 0
+	}
+	sample := w.ram[w.pos/2]
+	if w.pos%2 == 0 {
+		sample >>= 4
+	}
+	// volume codes 1, 2 and 3 are 100%, 50% and 25%
+	return (sample & 0x0F) >> (w.volume - 1)
+}
+
+func (w *wave) trigger() {
+	w.enabled = w.dacOn
+	if w.length.counter == 0 {
+		w.length.counter = 256
+	}
+	w.timer = w.period()
+	w.pos = 0
+}
+
+// noise is channel 4, which outputs the low bit of a linear feedback shift register
+type noise struct {
+	enabled bool
+	dacOn   bool
+	shift   byte // NR43 bits 4-7
+	narrow  bool // NR43 bit 3: 7-bit LFSR instead of 15-bit
+	divisor byte // NR43 bits 0-2
+	lfsr    uint16
+	timer   int
+	length  lengthCounter
+	env     envelope
+}
+
+func (n *noise) period() int {
+	return noiseDivisors[n.divisor] << n.shift
+}
+
+func (n *noise) step(cycles int) {
+	n.timer -= cycles
+	for n.timer <= 0 {
+		n.timer += n.period()
+		n.clockLFSR()
+	}
+}
+
+// clockLFSR XORs the low two bits, shifts right, and feeds the result back into bit 14 (and bit 6 in narrow mode)
+func (n *noise) clockLFSR() {
+	feedback := (n.lfsr ^ (n.lfsr >> 1)) & 1
+	n.lfsr = (n.lfsr >> 1) | (feedback << 14)
+	if n.narrow {
+		n.lfsr = (n.lfsr &^ (1 << 6)) | (feedback << 6)
+	}
+}
+
+func (n *noise) output() byte {
+	if !n.enabled || !n.dacOn {
+		return 0
+	}
+	// the output is the inverse of bit 0
+	return byte(^n.lfsr&1) * n.env.volume
+}
+
+func (n *noise) trigger() {
+	n.enabled = n.dacOn
+	if n.length.counter == 0 {
+		n.length.counter = 64
+	}
+	n.timer = n.period()
+	n.lfsr = 0x7FFF
+	n.env.trigger()
+}
@@ -0,0 +1,61 @@
+//go:build sdl
+// +build sdl
+
+package apu
+
+import (
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// SDLOutput queues samples to the default SDL audio device.
+// SDL must already be initialized with the audio subsystem
+type SDLOutput struct {
+	// Volume scales every sample, from 0, silent, to 1, which OpenSDL starts it at
+	Volume float64
+
+	dev sdl.AudioDeviceID
+}
+
+//OpenSDL opens the default audio device for signed 16-bit stereo at the given rate
+func OpenSDL(sampleRate int) (*SDLOutput, error) {
+	spec := sdl.AudioSpec{
+		Freq:     int32(sampleRate),
+		Format:   sdl.AUDIO_S16SYS,
+		Channels: 2,
+		Samples:  1024,
+	}
+	dev, err := sdl.OpenAudioDevice("", false, &spec, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	sdl.PauseAudioDevice(dev, false)
+	return &SDLOutput{Volume: 1, dev: dev}, nil
+}
+
+//Queue appends interleaved left/right samples to the device's queue
+func (o *SDLOutput) Queue(samples []int16) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	if o.Volume != 1 {
+		scaled := make([]int16, len(samples))
+		for i, s := range samples {
+			scaled[i] = int16(float64(s) * o.Volume)
+		}
+		samples = scaled
+	}
+	data := (*[1 << 24]byte)(unsafe.Pointer(&samples[0]))[:len(samples)*2]
+	return sdl.QueueAudio(o.dev, data)
+}
+
+//Queued returns how many stereo samples are waiting to be played
+func (o *SDLOutput) Queued() int {
+	return int(sdl.GetQueuedAudioSize(o.dev)) / 4
+}
+
+func (o *SDLOutput) Close() error {
+	sdl.CloseAudioDevice(o.dev)
+	return nil
+}
@@ -0,0 +1,72 @@
+package apu
+
+import "github.com/raidancampbell/goby/state"
+
+func (l *lengthCounter) serialize(s *state.Serializer) {
+	s.Int(&l.counter)
+	s.Bool(&l.enabled)
+}
+
+func (e *envelope) serialize(s *state.Serializer) {
+	s.Uint8(&e.initial)
+	s.Bool(&e.up)
+	s.Uint8(&e.period)
+	s.Uint8(&e.volume)
+	s.Uint8(&e.timer)
+}
+
+func (sq *square) serialize(s *state.Serializer) {
+	s.Bool(&sq.enabled)
+	s.Bool(&sq.dacOn)
+	s.Uint8(&sq.duty)
+	s.Uint8(&sq.dutyPos)
+	s.Uint16(&sq.freq)
+	s.Int(&sq.timer)
+	sq.length.serialize(s)
+	sq.env.serialize(s)
+	s.Uint8(&sq.sweepPeriod)
+	s.Bool(&sq.sweepNegate)
+	s.Uint8(&sq.sweepShift)
+	s.Uint8(&sq.sweepTimer)
+	s.Bool(&sq.sweepEnabled)
+	s.Uint16(&sq.shadowFreq)
+}
+
+func (w *wave) serialize(s *state.Serializer) {
+	s.Bool(&w.enabled)
+	s.Bool(&w.dacOn)
+	s.Uint8(&w.volume)
+	s.Uint16(&w.freq)
+	s.Int(&w.timer)
+	s.Uint8(&w.pos)
+	w.length.serialize(s)
+	s.Bytes(w.ram[:])
+}
+
+func (n *noise) serialize(s *state.Serializer) {
+	s.Bool(&n.enabled)
+	s.Bool(&n.dacOn)
+	s.Uint8(&n.shift)
+	s.Bool(&n.narrow)
+	s.Uint8(&n.divisor)
+	s.Uint16(&n.lfsr)
+	s.Int(&n.timer)
+	n.length.serialize(s)
+	n.env.serialize(s)
+}
+
+//Serialize saves or restores the registers and every channel.  The output filter and resampler aren't part of
+// the machine, so they carry on from wherever they are, and samples not yet collected are dropped on load
+func (a *APU) Serialize(s *state.Serializer) {
+	a.ch1.serialize(s)
+	a.ch2.serialize(s)
+	a.ch3.serialize(s)
+	a.ch4.serialize(s)
+	s.Bytes(a.regs[:])
+	s.Bool(&a.powered)
+	s.Int(&a.seqTimer)
+	s.Uint8(&a.seqStep)
+	if s.Loading() {
+		a.buf = a.buf[:0]
+	}
+}
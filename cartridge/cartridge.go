@@ -1,6 +1,7 @@
 package cartridge
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 )
@@ -14,19 +15,28 @@ type ROM []byte
 //a basic size check is executed
 //TODO: do the checksum calculation too
 func Load(f *os.File) *ROM {
-	var r ROM
-	b, err := ioutil.ReadFile(f.Name())
-	if err != nil{
+	r, err := Open(f.Name())
+	if err != nil {
 		panic(err)
 	}
+	return r
+}
+
+//Open reads a cartridge from the given path, returning an error if it can't be read,
+// or is too big or too small to be one
+func Open(path string) (*ROM, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
 	if len(b) > maxROMSize {
-		panic("ROM overread")
+		return nil, fmt.Errorf("%s is %d bytes, more than the largest cartridge's %d", path, len(b), maxROMSize)
 	}
 	if len(b) < minROMSize {
-		panic("rom underread")
+		return nil, fmt.Errorf("%s is %d bytes, less than the smallest cartridge's %d", path, len(b), minROMSize)
 	}
-	r = b
-	return &r
+	r := ROM(b)
+	return &r, nil
 }
 
 //GetTitle returns the title of the Cartridge
@@ -34,8 +44,10 @@ func (r *ROM) GetTitle() string {
 	return string((*r)[0x0134:0x0142])
 }
 
+//IsGBC returns whether the cartridge supports CGB features.  Bit 7 is set both for games that also run
+// on the DMG (80) and for CGB only games (C0)
 func (r *ROM) IsGBC() bool {
-	return (*r)[0x0143] == 0x80
+	return (*r)[0x0143]&0x80 != 0
 }
 
 func(r *ROM) LicenseeCode() [2]byte {
@@ -45,3 +57,35 @@ func(r *ROM) LicenseeCode() [2]byte {
 func(r *ROM) IsSuperGB() bool {
 	return (*r)[0x0146] == 0x03
 }
+
+//OldLicenseeCode returns the licensee byte from before the two character codes were introduced.
+// 33 means the new code, from LicenseeCode, is used instead
+func (r *ROM) OldLicenseeCode() byte {
+	return (*r)[0x014B]
+}
+
+//IsNintendo returns whether Nintendo published the cartridge, by either licensee code
+func (r *ROM) IsNintendo() bool {
+	old := r.OldLicenseeCode()
+	return old == 0x01 || old == 0x33 && r.LicenseeCode() == [2]byte{'0', '1'}
+}
+
+//TitleChecksum sums the 16 title bytes, including the CGB flag, which is still part of the title on older cartridges.
+// the CGB boot ROM uses this to pick a palette for DMG games
+func (r *ROM) TitleChecksum() byte {
+	var sum byte
+	for _, b := range (*r)[0x0134:0x0144] {
+		sum += b
+	}
+	return sum
+}
+
+//Type returns the cartridge type, which says which memory bank controller it has.  see types.go
+func (r *ROM) Type() TYPE {
+	return TYPE((*r)[0x0147])
+}
+
+//RAMSizeCode returns the header's code for how much RAM the cartridge has
+func (r *ROM) RAMSizeCode() byte {
+	return (*r)[0x0149]
+}
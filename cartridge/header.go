@@ -0,0 +1,64 @@
+package cartridge
+
+import "strings"
+
+//Name returns the title, without the padding after it
+func (r *ROM) Name() string {
+	title := r.GetTitle()
+	if i := strings.IndexByte(title, 0); i >= 0 {
+		title = title[:i]
+	}
+	return strings.TrimRight(title, " ")
+}
+
+//IsGBCOnly returns whether the cartridge only runs on the CGB
+func (r *ROM) IsGBCOnly() bool {
+	return (*r)[0x0143] == 0xC0
+}
+
+//ROMBanks returns how many 16KB banks the header says the cartridge has, or 0 if the header's code is unknown
+func (r *ROM) ROMBanks() int {
+	code := (*r)[0x0148]
+	if code > 8 {
+		return 0
+	}
+	return 2 << code
+}
+
+// ramSizes is the cartridge RAM size for each header code at 0149
+var ramSizes = map[byte]int{0: 0, 1: 0x800, 2: 0x2000, 3: 0x8000, 4: 0x20000, 5: 0x10000}
+
+//RAMSize returns how much RAM the header says the cartridge has, and false if the header's code is unknown
+func (r *ROM) RAMSize() (int, bool) {
+	size, ok := ramSizes[r.RAMSizeCode()]
+	return size, ok
+}
+
+//IsJapanese returns whether the cartridge was sold in Japan, rather than overseas
+func (r *ROM) IsJapanese() bool {
+	return (*r)[0x014A] == 0
+}
+
+//Version returns the mask ROM version, which is usually 0
+func (r *ROM) Version() byte {
+	return (*r)[0x014C]
+}
+
+//HeaderChecksum returns the header checksum at 014D, and what it should be.  The boot ROM won't start the cartridge
+// if they differ
+func (r *ROM) HeaderChecksum() (stored, computed byte) {
+	for _, b := range (*r)[0x0134:0x014D] {
+		computed = computed - b - 1
+	}
+	return (*r)[0x014D], computed
+}
+
+//GlobalChecksum returns the checksum of the whole ROM at 014E, and what it should be.  Nothing checks it
+func (r *ROM) GlobalChecksum() (stored, computed uint16) {
+	for i, b := range *r {
+		if i != 0x014E && i != 0x014F {
+			computed += uint16(b)
+		}
+	}
+	return uint16((*r)[0x014E])<<8 | uint16((*r)[0x014F]), computed
+}
@@ -0,0 +1,50 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader(t *testing.T) {
+	r := make(ROM, 0x8000)
+	copy(r[0x0134:], "TETRIS")
+	r[0x0148] = 0x01
+	r[0x0149] = 0x02
+	r[0x014A] = 0x01
+	stored, computed := r.HeaderChecksum()
+	r[0x014D] = computed
+
+	assert.Equal(t, "TETRIS", r.Name())
+	assert.Equal(t, 4, r.ROMBanks())
+	size, ok := r.RAMSize()
+	assert.True(t, ok)
+	assert.Equal(t, 0x2000, size)
+	assert.False(t, r.IsJapanese())
+	assert.Equal(t, "ROM ONLY", r.Type().String())
+	assert.False(t, r.Type().HasBattery())
+
+	stored, computed = r.HeaderChecksum()
+	assert.Equal(t, stored, computed)
+	// the 25 bytes from 0134 to 014C, which sum to 479, each subtracted along with 1: -504 is 08
+	assert.Equal(t, byte(0x08), computed)
+
+	globalStored, globalComputed := r.GlobalChecksum()
+	assert.Equal(t, uint16(0), globalStored)
+	assert.Equal(t, uint16(479+0x08), globalComputed)
+}
+
+func TestTYPE_HasBattery(t *testing.T) {
+	for typ, want := range map[TYPE]bool{0x01: false, 0x03: true, 0x0F: true, 0x12: false, 0x1B: true, 0x1D: false} {
+		assert.Equal(t, want, typ.HasBattery(), typ.String())
+	}
+}
+
+func TestTitleChecksum(t *testing.T) {
+	r := make(ROM, 0x8000)
+	copy(r[0x0134:], "TETRIS")
+	assert.Equal(t, byte(0xDB), r.TitleChecksum())
+	// every one of the 16 title bytes counts, up to and including the CGB flag
+	r[0x0142], r[0x0143] = 0x01, 0x02
+	assert.Equal(t, byte(0xDE), r.TitleChecksum())
+}
@@ -1,5 +1,7 @@
 package cartridge
 
+import "strings"
+
 type TYPE uint8
 
 
@@ -38,3 +40,26 @@ FD-Bandai TAMA5
 FE - Hudson HuC-3
 FF - Hudson HuC-1
  */
+
+// typeNames are the cartridge types, as listed above
+var typeNames = map[TYPE]string{
+	0x00: "ROM ONLY", 0x01: "MBC1", 0x02: "MBC1+RAM", 0x03: "MBC1+RAM+BATTERY",
+	0x05: "MBC2", 0x06: "MBC2+BATTERY", 0x08: "ROM+RAM", 0x09: "ROM+RAM+BATTERY",
+	0x0B: "MMM01", 0x0C: "MMM01+RAM", 0x0D: "MMM01+RAM+BATTERY",
+	0x0F: "MBC3+TIMER+BATTERY", 0x10: "MBC3+TIMER+RAM+BATTERY", 0x11: "MBC3", 0x12: "MBC3+RAM", 0x13: "MBC3+RAM+BATTERY",
+	0x19: "MBC5", 0x1A: "MBC5+RAM", 0x1B: "MBC5+RAM+BATTERY",
+	0x1C: "MBC5+RUMBLE", 0x1D: "MBC5+RUMBLE+RAM", 0x1E: "MBC5+RUMBLE+RAM+BATTERY",
+	0x1F: "POCKET CAMERA", 0xFD: "BANDAI TAMA5", 0xFE: "HuC3", 0xFF: "HuC1+RAM+BATTERY",
+}
+
+func (t TYPE) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+//HasBattery returns whether the cartridge's RAM is kept by a battery, so games can save to it
+func (t TYPE) HasBattery() bool {
+	return strings.Contains(t.String(), "BATTERY")
+}
@@ -0,0 +1,56 @@
+// Command disasm writes a disassembly of a whole ROM to a text file, following the code from the entry points.
+// a symbol file next to the ROM, e.g. rom.sym, is used to label it.
+// usage: disasm [-o listing.asm] rom.gb
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raidancampbell/goby/disasm"
+	"github.com/raidancampbell/goby/symbols"
+)
+
+func main() {
+	out := flag.String("o", "", "where to write the listing. defaults to the ROM's path with a .asm extension")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-o listing.asm] rom.gb\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	romPath := flag.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".asm"
+	}
+
+	rom, err := ioutil.ReadFile(romPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	listing := disasm.Disassemble(rom)
+	if listing.Symbols, err = symbols.ForROM(romPath); err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := listing.WriteTo(f); err != nil {
+		f.Close()
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %s: %d of %d bytes are code", *out, listing.Coverage(), len(rom))
+}
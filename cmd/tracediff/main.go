@@ -0,0 +1,88 @@
+// Command tracediff runs a ROM with tracing on, and compares the trace against a reference log from another emulator
+// as it goes, stopping at the first line that differs.  The reference can be gzipped.
+// usage: tracediff [-frames N] [-context N] rom.gb reference.log[.gz]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/gameboy"
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/trace"
+)
+
+//run runs the emulator until the diff is done or the frames run out.  The emulator panics on opcodes it
+// doesn't implement yet, which is returned as an error, so the lines leading up to it can still be shown
+func run(gb *gameboy.GameBoy, diff *trace.Diff, frames int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("emulator stopped: %v", r)
+		}
+	}()
+	gb.BeforeStep = func() bool {
+		return !diff.Done()
+	}
+	for i := 0; i < frames; i++ {
+		if err := gb.RunFrame(); err == gameboy.ErrBreak {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	frames := flag.Int("frames", 3600, "give up after this many frames without a difference")
+	context := flag.Int("context", 5, "how many lines to show either side of the difference")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-frames N] [-context N] rom.gb reference.log[.gz]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cart, err := cartridge.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ref, err := trace.OpenLog(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ref.Close()
+
+	// no boot ROM: reference logs start at 0100, with the registers the boot ROM leaves behind
+	gb, err := gameboy.Create(cart, gameboy.ModelAuto, &render.Headless{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	diff := trace.NewDiff(ref, *context)
+	tracer := trace.New(diff, gb.RAM, gb.MBC)
+	gb.CPU.Trace = tracer.Instruction
+	runErr := run(gb, diff, *frames)
+	tracer.Flush()
+
+	switch {
+	case diff.Mismatch != nil:
+		diff.Mismatch.WriteTo(os.Stdout)
+		os.Exit(1)
+	case runErr != nil:
+		fmt.Printf("%v, after %d matching lines\n", runErr, diff.Lines)
+		for _, line := range diff.Recent() {
+			fmt.Println(line)
+		}
+		os.Exit(1)
+	case diff.ReferenceEnded():
+		fmt.Printf("all %d lines of the reference match\n", diff.Lines)
+	default:
+		fmt.Printf("no difference in %d lines, after %d frames\n", diff.Lines, *frames)
+	}
+}
@@ -0,0 +1,258 @@
+// Package config reads goby's settings file, config.json in the user's config directory, e.g. ~/.config/goby on Linux.
+// everything in it is optional, and command line flags override it.  Settings can be overridden for particular ROMs,
+// picked by title and checksum:
+//
+//	{
+//	  "palette": "classic",
+//	  "scale": 4,
+//	  "keys": {"a": "X", "b": "Z", "start": "Return", "select": "Backspace"},
+//	  "gamepad": {"a": "b", "b": "a"},
+//	  "audio": {"sample_rate": 44100, "volume": 0.5},
+//	  "sync": "video",
+//	  "fast_forward": 8,
+//	  "save_dir": "~/goby/saves",
+//	  "state_dir": "~/goby/states",
+//	  "screenshot_dir": "~/goby/screenshots",
+//	  "model": "dmg",
+//	  "boot_roms": {"dmg": "~/goby/dmg_boot.bin"},
+//	  "roms": [
+//	    {"title": "POKEMON YELLOW", "model": "cgb"},
+//	    {"title": "TETRIS", "checksum": "0A6B", "palette": "pocket"}
+//	  ]
+//	}
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/joypad"
+)
+
+// Settings are everything that can be set for every ROM, or overridden for one.  Empty fields are left as they are
+type Settings struct {
+	// Palette is a DMG palette, as -palette takes
+	Palette string `json:"palette,omitempty"`
+	// Scale is the window size, as a multiple of the screen
+	Scale int `json:"scale,omitempty"`
+	// Keys and Gamepad bind Game Boy buttons, e.g. start, to SDL key and controller button names, e.g. Return and start.
+	// buttons that aren't listed keep their default bindings
+	Keys    map[string]string `json:"keys,omitempty"`
+	Gamepad map[string]string `json:"gamepad,omitempty"`
+	Audio   Audio             `json:"audio"`
+	// Sync is what emulation is paced against, as -sync takes, and FastForward how many times real speed the
+	// fast-forward key runs at
+	Sync        string `json:"sync,omitempty"`
+	FastForward int    `json:"fast_forward,omitempty"`
+	// SaveDir is where the RAM of cartridges with a battery is kept, and StateDir where save states are,
+	// instead of next to the ROM
+	SaveDir       string `json:"save_dir,omitempty"`
+	StateDir      string `json:"state_dir,omitempty"`
+	ScreenshotDir string `json:"screenshot_dir,omitempty"`
+	// Model is the hardware to emulate, as -model takes
+	Model string `json:"model,omitempty"`
+	// BootROMs are the boot ROM to run for each model, e.g. dmg, when -bootrom isn't given
+	BootROMs map[string]string `json:"boot_roms,omitempty"`
+}
+
+// Audio is how sound is played
+type Audio struct {
+	SampleRate int `json:"sample_rate,omitempty"`
+	// Volume is from 0, silent, to 1.  It's a pointer so 0 can be told apart from unset
+	Volume *float64 `json:"volume,omitempty"`
+}
+
+// ROMSettings override Settings for ROMs with the given title, and, if it's set, global checksum, in hex as it is
+// at 014E, e.g. 0A6B.  Titles are compared ignoring case
+type ROMSettings struct {
+	Title    string `json:"title"`
+	Checksum string `json:"checksum,omitempty"`
+	Settings
+}
+
+// Config is the whole settings file
+type Config struct {
+	Settings
+	ROMs []ROMSettings `json:"roms,omitempty"`
+}
+
+//Path returns where the settings file is kept: goby/config.json in the user's config directory
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goby", "config.json"), nil
+}
+
+//Load reads the settings file at the given path.  A missing file isn't an error: it's the same as an empty one
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+//Parse reads settings.  Fields it doesn't know are an error, as they're most likely misspelled
+func Parse(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	if err := c.Settings.validate(); err != nil {
+		return nil, err
+	}
+	for _, rom := range c.ROMs {
+		if rom.Title == "" && rom.Checksum == "" {
+			return nil, fmt.Errorf("ROM settings need a title, a checksum, or both")
+		}
+		if err := rom.Settings.validate(); err != nil {
+			return nil, fmt.Errorf("ROM settings for %s: %v", rom.Title+rom.Checksum, err)
+		}
+	}
+	return &c, nil
+}
+
+//validate checks what can be checked without SDL: the names of the buttons bound, and that numbers are in range
+func (s Settings) validate() error {
+	for _, bindings := range []map[string]string{s.Keys, s.Gamepad} {
+		for name := range bindings {
+			if _, err := joypad.ParseButton(name); err != nil {
+				return err
+			}
+		}
+	}
+	if s.Scale < 0 {
+		return fmt.Errorf("scale can't be negative, got %d", s.Scale)
+	}
+	if s.FastForward < 0 {
+		return fmt.Errorf("fast forward can't be negative, got %d", s.FastForward)
+	}
+	if s.Audio.SampleRate < 0 {
+		return fmt.Errorf("sample rate can't be negative, got %d", s.Audio.SampleRate)
+	}
+	if v := s.Audio.Volume; v != nil && (*v < 0 || *v > 1) {
+		return fmt.Errorf("volume must be between 0 and 1, got %g", *v)
+	}
+	return nil
+}
+
+//For returns the settings for a ROM: the file's, with those of every matching entry in roms laid over them, in order.
+// paths starting with ~ are expanded to the user's home directory
+func (c *Config) For(rom *cartridge.ROM) Settings {
+	s := c.Settings.merge(Settings{})
+	for _, r := range c.ROMs {
+		if r.matches(rom) {
+			s = s.merge(r.Settings)
+		}
+	}
+	s.SaveDir = expandHome(s.SaveDir)
+	s.StateDir = expandHome(s.StateDir)
+	s.ScreenshotDir = expandHome(s.ScreenshotDir)
+	for model, path := range s.BootROMs {
+		s.BootROMs[model] = expandHome(path)
+	}
+	return s
+}
+
+//matches returns whether these settings are for the given ROM
+func (r ROMSettings) matches(rom *cartridge.ROM) bool {
+	if r.Title != "" && !strings.EqualFold(r.Title, rom.Name()) {
+		return false
+	}
+	if r.Checksum != "" {
+		stored, _ := rom.GlobalChecksum()
+		if !strings.EqualFold(strings.TrimPrefix(r.Checksum, "$"), fmt.Sprintf("%04X", stored)) {
+			return false
+		}
+	}
+	return true
+}
+
+//merge returns a copy of the settings, with those set in o replacing them.  Maps are merged key by key
+func (s Settings) merge(o Settings) Settings {
+	if o.Palette != "" {
+		s.Palette = o.Palette
+	}
+	if o.Scale != 0 {
+		s.Scale = o.Scale
+	}
+	s.Keys = mergeMap(s.Keys, o.Keys)
+	s.Gamepad = mergeMap(s.Gamepad, o.Gamepad)
+	if o.Sync != "" {
+		s.Sync = o.Sync
+	}
+	if o.FastForward != 0 {
+		s.FastForward = o.FastForward
+	}
+	if o.Audio.SampleRate != 0 {
+		s.Audio.SampleRate = o.Audio.SampleRate
+	}
+	if o.Audio.Volume != nil {
+		s.Audio.Volume = o.Audio.Volume
+	}
+	if o.SaveDir != "" {
+		s.SaveDir = o.SaveDir
+	}
+	if o.StateDir != "" {
+		s.StateDir = o.StateDir
+	}
+	if o.ScreenshotDir != "" {
+		s.ScreenshotDir = o.ScreenshotDir
+	}
+	if o.Model != "" {
+		s.Model = o.Model
+	}
+	s.BootROMs = mergeMap(s.BootROMs, o.BootROMs)
+	return s
+}
+
+//mergeMap returns a new map with the entries of a, then b
+func mergeMap(a, b map[string]string) map[string]string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	merged := map[string]string{}
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+//Level returns the volume to play at, which is full unless it's set
+func (a Audio) Level() float64 {
+	if a.Volume == nil {
+		return 1
+	}
+	return *a.Volume
+}
+
+//expandHome replaces a leading ~ in a path with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
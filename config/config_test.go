@@ -0,0 +1,79 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//rom is a cartridge with the given title and global checksum
+func rom(title string, checksum uint16) *cartridge.ROM {
+	r := make(cartridge.ROM, 0x8000)
+	copy(r[0x0134:], title)
+	r[0x014E], r[0x014F] = byte(checksum>>8), byte(checksum)
+	return &r
+}
+
+func TestFor(t *testing.T) {
+	c, err := Parse(strings.NewReader(`{
+		"palette": "classic",
+		"scale": 4,
+		"keys": {"a": "X", "b": "Z"},
+		"audio": {"volume": 0.5},
+		"boot_roms": {"dmg": "dmg_boot.bin"},
+		"save_dir": "saves",
+		"state_dir": "states",
+		"roms": [
+			{"title": "tetris", "scale": 2, "keys": {"a": "S"}},
+			{"title": "TETRIS", "checksum": "BEEF", "palette": "pocket", "audio": {"volume": 0}},
+			{"checksum": "$1234", "model": "cgb"}
+		]
+	}`))
+	require.NoError(t, err)
+
+	other := c.For(rom("ZELDA", 0xBEEF))
+	assert.Equal(t, "classic", other.Palette)
+	assert.Equal(t, 4, other.Scale)
+	assert.Equal(t, 0.5, other.Audio.Level())
+	assert.Equal(t, "", other.Model)
+
+	tetris := c.For(rom("TETRIS", 0x0001))
+	assert.Equal(t, "classic", tetris.Palette)
+	assert.Equal(t, 2, tetris.Scale)
+	assert.Equal(t, map[string]string{"a": "S", "b": "Z"}, tetris.Keys)
+	assert.Equal(t, map[string]string{"dmg": "dmg_boot.bin"}, tetris.BootROMs)
+	assert.Equal(t, "saves", tetris.SaveDir)
+	assert.Equal(t, "states", tetris.StateDir)
+
+	exact := c.For(rom("TETRIS", 0xBEEF))
+	assert.Equal(t, "pocket", exact.Palette)
+	assert.Equal(t, 2, exact.Scale)
+	assert.Equal(t, 0.0, exact.Audio.Level())
+
+	assert.Equal(t, "cgb", c.For(rom("ANYTHING", 0x1234)).Model)
+	// overrides don't leak into the file's own settings
+	assert.Equal(t, map[string]string{"a": "X", "b": "Z"}, c.Keys)
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, bad := range []string{
+		`{"scael": 2}`,
+		`{"keys": {"x": "Y"}}`,
+		`{"audio": {"volume": 2}}`,
+		`{"roms": [{"palette": "gray"}]}`,
+		`{"roms": [{"title": "TETRIS", "gamepad": {"turbo": "x"}}]}`,
+		`{"scale": "big"}`,
+	} {
+		_, err := Parse(strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestLoad_missing(t *testing.T) {
+	c, err := Load("testdata/does-not-exist.json")
+	require.NoError(t, err)
+	assert.Equal(t, Settings{}, c.For(rom("TETRIS", 0)))
+}
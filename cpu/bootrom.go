@@ -1,25 +1,20 @@
 package cpu
 
-import (
-	"crypto/md5"
-	"fmt"
-	"io/ioutil"
-	"log"
-	"os"
+import "fmt"
+
+// boot ROM sizes.  The DMG, MGB and SGB boot ROMs are 256 bytes.  The CGB's is 2304, and skips over the cartridge
+// header at 0100-01FF
+const (
+	DMGBootROMSize = 0x100
+	CGBBootROMSize = 0x900
 )
 
-func LoadBootrom(f *os.File) {
-	b, err := ioutil.ReadFile(f.Name())
-	if err != nil{
-		panic(err)
-	}
-	sum := md5.Sum(b)
-	actual := fmt.Sprintf("%x", sum)
-	expected := "32fbbd84168d3482956eb3c5051637f5"
-	if actual != expected {
-		log.Panicf("bootrom checksum does not match, actual: %s, expected %s", actual, expected)
-	}
-	for i := range b {
-		c.ram[i] = b[i]
+//LoadBootrom overlays a boot ROM on the start of the cartridge, after checking it's the size of one.
+// which boot ROM it is, and whether it suits the hardware, is left to the caller
+func (c *CPU) LoadBootrom(b []byte) error {
+	if len(b) != DMGBootROMSize && len(b) != CGBBootROMSize {
+		return fmt.Errorf("boot ROMs are %d or %d bytes, got %d", DMGBootROMSize, CGBBootROMSize, len(b))
 	}
-}
\ No newline at end of file
+	c.ram.LoadBootROM(b)
+	return nil
+}
@@ -2,7 +2,7 @@ package cpu
 
 // tests the given bit of the given byte
 // all bit opcodes alter flags Z01, where Z is the main meaning of the opcodes
-func testBit(bit, byt byte) {
+func (c *CPU) testBit(bit, byt byte) {
 	isSet := (byt>>bit)&1 == 0
 	c.setFlag(flagZero,  isSet)
 	c.setFlag(flagSubtract, false)
@@ -22,9 +22,9 @@ var opcb7c = opcode{
 	cycles4: 8,
 	label:   "BIT 7,H",
 	value:   0x7c,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z01
-		testBit(0x7, c.hlREG[0])
+		c.testBit(0x7, c.hlREG[0])
 		c.pc++
 	},
 }
@@ -34,8 +34,8 @@ var opcb4f = opcode{
 	cycles4: 8,
 	label:   "BIT 1, A",
 	value:   0x4f,
-	impl: func() {
-		testBit(0x1, c.accFlagReg[0])
+	impl: func(c *CPU) {
+		c.testBit(0x1, c.accFlagReg[0])
 		c.pc++
 	},
 }
@@ -46,7 +46,7 @@ var opcb11 = opcode{
 	cycles4: 8,
 	label:   "RL C",
 	value:   0x11,
-	impl: func() {
+	impl: func(c *CPU) {
 		val := c.bcREG[1]
 		var carry byte
 		var rot byte
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/state"
 )
 
 type REG [2]byte
@@ -27,7 +28,7 @@ func (r *REG) fromUint16(u uint16) {
 }
 
 // CPU is the main brains of the operation
-// it executes the opcodes and writes to memory through the memory controller
+// it executes the opcodes and writes to memory through the memory controller, which it owns
 type CPU struct {
 	pc                  uint16 // program counter
 	sp                  uint16 // stack pointer
@@ -36,31 +37,82 @@ type CPU struct {
 	// for BC/DE/HL, the first register is considered bits 8-15, and the second is bits 0-7
 	// e.g. 9FFF is stored as (9F FF) in registers, whereas in ROM it is (FF 9F)
 	ram                 mem.RAM
-	interruptEnabled    bool
+	interruptEnabled    bool // IME
+	halted              bool // HALT stops execution until an interrupt is pending
+	doubleSpeed         bool // CGB only: the CPU runs at 8MHz
+	speedArmed          bool // KEY1 bit 0: the next STOP switches speed
+	stall               int  // cycles the CPU sits idle after a speed switch
+
+	// Trace, if set, is called before each instruction the CPU runs, with the registers as they are before it.
+	// it isn't called for interrupt dispatch or while halted, as no instruction runs
+	Trace func(r Registers)
+}
+
+//New returns a CPU with the registers the DMG bootrom leaves behind, and its own memory with nothing mapped
+func New() *CPU {
+	c := &CPU{}
+	c.initRegisters()
+	return c
 }
 
 //GetRAM returns a pointer to the memory.  This is used for loading a cartridge
 //TODO: invert responsibility here. pass the cartridge to the memory controller
-func GetRAM() *mem.RAM {
+func (c *CPU) GetRAM() *mem.RAM {
 	return &c.ram
 }
 
-func Get() *CPU {
-	return c
-}
-
 //Run begins reading the memory and executing opcodes
 // the bootrom isn't necessary assuming the program counter begins at 0x0100
-func Run() {
+func (c *CPU) Run() {
 	for i := 0; i < 90000; i++ {
-		opByte := c.ram.ReadByte(c.pc)
-		newOp, ok := table[opByte]
-		fmt.Printf("executing opcode %x at location %x, execution number %v\t%s\n", opByte, c.pc, i, newOp.label)
-		if !ok {
-			panic(fmt.Sprintf("unable to find opcode %x", c.ram.ReadByte(c.pc)))
+		c.Step()
+	}
+}
+
+//Step executes the opcode at the program counter, and returns how many 4MHz cycles it took
+func (c *CPU) Step() int {
+	if cycles, serviced := c.serviceInterrupt(); serviced {
+		return cycles
+	}
+	if c.halted {
+		return 4
+	}
+	if c.Trace != nil {
+		c.Trace(c.Regs())
+	}
+	opByte := c.ram.ReadByte(c.pc)
+	newOp, ok := table[opByte]
+	if !ok {
+		panic(fmt.Sprintf("unable to find opcode %x", c.ram.ReadByte(c.pc)))
+	}
+	newOp.impl(c)
+	cycles := int(newOp.cycles4) + c.stall
+	c.stall = 0
+	return cycles
+}
+
+//serviceInterrupt dispatches the highest priority interrupt that is both requested and enabled.
+// a pending interrupt always wakes the CPU from HALT, but is only dispatched if IME is set
+func (c *CPU) serviceInterrupt() (int, bool) {
+	pending := c.ram.ReadByte(mem.RegIF) & c.ram.ReadByte(mem.RegIE) & 0x1F
+	if pending == 0 {
+		return 0, false
+	}
+	c.halted = false
+	if !c.interruptEnabled {
+		return 0, false
+	}
+	for bit := uint8(0); bit < 5; bit++ {
+		if pending&(1<<bit) == 0 {
+			continue
 		}
-		newOp.impl()
+		c.interruptEnabled = false
+		c.ram.WriteByte(mem.RegIF, c.ram.ReadByte(mem.RegIF)&^(1<<bit))
+		c.pushWord(c.pc)
+		c.pc = 0x0040 + uint16(bit)*8
+		return 20, true
 	}
+	return 0, false
 }
 
 //setFlag sets the given bit in the flag register to the given value (i.e. setFlag can clear a bit)
@@ -82,7 +134,6 @@ func (c *CPU) getFlag(flag uint8) bool {
 //TODO: shift this to big endian
 func (c *CPU) popWord() uint16 {
 	val := uint16(c.ram.ReadByte(c.sp)) + (uint16(c.ram.ReadByte(c.sp+1)) << 8)
-	fmt.Printf("popped %x from stack addr %x\n", val, c.sp)
 	c.sp += 2
 	return val
 }
@@ -93,7 +144,6 @@ func (c *CPU) pushWord(word uint16) {
 	high := byte(word >> 8)
 	low := byte(word & 0x00FF)
 	c.pushBytes(low, high)
-	fmt.Printf("pushed %x to stack addr %x\n", word, c.sp)
 }
 
 func (c *CPU) pushBytes(low, high byte) {
@@ -107,13 +157,11 @@ const (
 	flagZero      = 0x7 //Z
 	flagSubtract  = 0x6 //N
 	flagHalfCarry = 0x5 //H
-	flagCarry     = 0x5 //C
+	flagCarry     = 0x4 //C
 )
 
-var c *CPU
-
-func init() {
-	c = &CPU{}
+//initRegisters sets the registers the DMG bootrom leaves behind
+func (c *CPU) initRegisters() {
 	c.accFlagReg = [2]byte{0x01, 0xb0}
 	c.bcREG = [2]byte{0x00, 0x13}
 	c.deREG = [2]byte{0x00, 0xd8}
@@ -122,8 +170,41 @@ func init() {
 	c.sp = 0xFFFE
 }
 
+//InitForCGB sets the registers the CGB bootrom leaves behind.  Games check for A=11 to detect CGB hardware
+// KEY1 is mapped here too, as only CGB can switch speed
+func (c *CPU) InitForCGB() {
+	c.accFlagReg[0] = 0x11
+	c.mapSpeedRegister()
+}
+
+//InitForMGB sets the registers the MGB bootrom leaves behind.  Only A differs from the DMG, which games check for FF
+func (c *CPU) InitForMGB() {
+	c.accFlagReg[0] = 0xFF
+}
+
+//InitForAGB sets the registers the AGB bootrom leaves behind.  It's the CGB's, but with bit 0 of B set, which games
+// check for to detect the GBA
+func (c *CPU) InitForAGB() {
+	c.bcREG[0] |= 0x01
+}
+
 //InitPCForBootrom resets the program counter to 0, indicating that the bootrom should execute
 // by default the program counter is initialized to 0x0100, the beginning of the cartridge ROM
-func InitPCForBootrom() {
+func (c *CPU) InitPCForBootrom() {
 	c.pc = 0x0000
 }
+
+//Serialize saves or restores the registers and execution state.  Memory is saved separately
+func (c *CPU) Serialize(s *state.Serializer) {
+	s.Uint16(&c.pc)
+	s.Uint16(&c.sp)
+	s.Bytes(c.accFlagReg[:])
+	s.Bytes(c.bcREG[:])
+	s.Bytes(c.deREG[:])
+	s.Bytes(c.hlREG[:])
+	s.Bool(&c.interruptEnabled)
+	s.Bool(&c.halted)
+	s.Bool(&c.doubleSpeed)
+	s.Bool(&c.speedArmed)
+	s.Int(&c.stall)
+}
@@ -2,7 +2,9 @@ package cpu
 
 import (
 	"encoding/binary"
+	"github.com/raidancampbell/goby/internal/testrom"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -35,4 +37,49 @@ func TestREG_roundTrip(t *testing.T) {
 	assert.Equal(t, r[0], newReg[0])
 	assert.Equal(t, r[1], newReg[1])
 	assert.Equal(t, uint16(0x9FFF), newReg.toUint16())
-}
\ No newline at end of file
+}
+//load returns a CPU with a cartridge that runs the given code mapped in, through its MBC like any other
+func load(t *testing.T, code ...byte) *CPU {
+	c := New()
+	_, err := c.GetRAM().LoadCartridge(testrom.New(code...))
+	require.NoError(t, err)
+	return c
+}
+
+func TestCPU_loadSPFromCartridge(t *testing.T) {
+	c := load(t, 0x31, 0xFE, 0xCF) // LD SP,$CFFE
+	c.Step()
+	assert.Equal(t, uint16(0xCFFE), c.Regs().SP)
+	assert.Equal(t, uint16(0x0103), c.Regs().PC)
+}
+
+func TestCPU_carryFlag(t *testing.T) {
+	c := load(t,
+		0x21, 0x00, 0xC0, // LD HL,$C000
+		0x3E, 0xF0, // LD A,$F0
+		0x86,       // ADD A,(HL)
+		0xCE, 0x00, // ADC A,$00
+	)
+	c.GetRAM().WriteByte(0xC000, 0x20)
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	// F0+20 overflows.  the carry is bit 4 of F, not bit 5, which is the half carry
+	assert.Equal(t, byte(0x10), c.Regs().A)
+	assert.Equal(t, byte(FlagC), c.Regs().F)
+	assert.Equal(t, byte(0x10), c.Regs().F)
+
+	// and ADC adds it back in
+	c.Step()
+	assert.Equal(t, byte(0x11), c.Regs().A)
+	assert.Equal(t, byte(0x00), c.Regs().F)
+}
+
+func TestCPU_loadD(t *testing.T) {
+	c := load(t, 0x06, 0x11, 0x16, 0x22) // LD B,$11; LD D,$22
+	c.Step()
+	c.Step()
+	assert.Equal(t, byte(0x22), c.Regs().D)
+	assert.Equal(t, byte(0x11), c.Regs().B, "B is left alone")
+	assert.Equal(t, uint16(0x0104), c.Regs().PC)
+}
@@ -55,6 +55,10 @@ var table = map[byte]opcode{
 	0x50: op50,
 	0x4F: op4f,
 	0x57: op57,
+	0x76: op76,
+	0xf3: opf3,
+	0xd9: opd9,
+	0x10: op10,
 }
 
 // verify opcodes
@@ -72,9 +76,9 @@ type opcode struct {
 	cycles4 uint8 // 4MHz cycles. all opcodes should be divisible by 4,
 	// as that's the clock rate used for executing the opcodes.
 	// The 4MHz rate is used in the PPU
-	label string // for human readability
-	value byte   // what's the machine code value to invoke this instruction.  like 0x00 is a NOP
-	impl  func() // the opcode implementation
+	label string       // for human readability
+	value byte         // what's the machine code value to invoke this instruction.  like 0x00 is a NOP
+	impl  func(c *CPU) // the opcode implementation
 	// ALL opcodes will change the CPU's program counter register
 	// MOST opcodes will change other registers or memory
 	// SOME opcodes will read ahead (e.g. opcodes that take more than one byte)
@@ -89,9 +93,9 @@ var op31 = opcode{
 	cycles4: 12,
 	label:   "LD SP, d16",
 	value:   0x31,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
-		c.sp = uint16(c.ram[c.pc+1]) | (uint16(c.ram[c.pc+2]) << 8)
+		c.sp = uint16(c.ram.ReadByte(c.pc+1)) | (uint16(c.ram.ReadByte(c.pc+2)) << 8)
 		c.pc+=3
 	},
 }
@@ -103,7 +107,7 @@ var opaf = opcode{
 	cycles4: 4,
 	label:   "XOR A",
 	value:   0xAF,
-	impl: func() {
+	impl: func(c *CPU) {
 		c.accFlagReg[0] = 0x00
 		c.setFlag(flagZero, true)
 		c.setFlag(flagSubtract, false)
@@ -119,7 +123,7 @@ var op21 = opcode{
 	cycles4: 12,
 	label:   "LD HL,d16",
 	value:   0x21,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		//e.x. 0x21, 0xFF, 0x9F to load 0x9FFF into HL
@@ -140,7 +144,7 @@ var op32 = opcode{
 	cycles4: 8,
 	label:   "LD (HL-),A",
 	value:   0x32,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.ram.WriteByte(c.hlREG.toUint16(), c.accFlagReg[0])
 		c.hlREG.fromUint16(c.hlREG.toUint16()-1)
@@ -154,14 +158,13 @@ var opcb = opcode{
 	cycles4: 4,
 	label:   "PREFIX CB",
 	value:   0xCB,
-	impl: func() {
+	impl: func(c *CPU) {
 		c.pc++
 		newOp, ok := cbTable[c.ram.ReadByte(c.pc)]
-		fmt.Printf("executing opcode %x at location %x, execution number ??\t%s\n", newOp.value, c.pc, newOp.label)
 		if !ok {
 			panic(fmt.Sprintf("unable to find CB opcode %v", c.ram.ReadByte(c.pc)))
 		}
-		newOp.impl()
+		newOp.impl(c)
 	},
 }
 
@@ -174,7 +177,7 @@ var op20 = opcode{
 	cycles4: 8, //todo: 12 if jump is taken
 	label:   "JR NZ,r8",
 	value:   0x20,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		if !c.getFlag(flagZero) {
@@ -193,7 +196,7 @@ var opfb = opcode{
 	cycles4: 4,
 	label:   "ei",
 	value:   0xFB,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		c.interruptEnabled = true
@@ -206,7 +209,7 @@ var op0e = opcode{
 	cycles4: 8,
 	label:   "LD C,d8",
 	value:   0x0E,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		c.bcREG[1] = c.ram.ReadByte(c.pc)
@@ -220,7 +223,7 @@ var op3e = opcode{
 	cycles4: 8,
 	label:   "LD A,d8",
 	value:   0x3E,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		c.accFlagReg[0] = c.ram.ReadByte(c.pc)
@@ -234,7 +237,7 @@ var ope2 = opcode{
 	cycles4: 8,
 	label:   "LD (C),A",
 	value:   0xE2,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		c.ram.WriteByte(0xFF00+uint16(c.bcREG[1]), c.accFlagReg[0])
@@ -247,7 +250,7 @@ var op0c = opcode{
 	cycles4: 4,
 	label:   "INC C",
 	value:   0x0C,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z0H flags
 		c.pc++
 		c.bcREG[1]++
@@ -266,7 +269,7 @@ var op77 = opcode{
 	cycles4: 8,
 	label:   "LD (HL), A",
 	value:   0x77,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		c.ram.WriteByte(c.hlREG.toUint16(), c.accFlagReg[0])
@@ -279,7 +282,7 @@ var ope0 = opcode{
 	cycles4: 12,
 	label:   "LDH (a8), A", // LD ($FF00+a8), A
 	value:   0xE0,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		c.ram.WriteByte(0xFF00+uint16(c.ram.ReadByte(c.pc)), c.accFlagReg[0])
@@ -293,7 +296,7 @@ var op11 = opcode{
 	cycles4: 12,
 	label:   "LD DE, d16",
 	value:   0x11,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		c.deREG[1] = c.ram.ReadByte(c.pc)
@@ -309,7 +312,7 @@ var op1a = opcode{
 	cycles4: 8,
 	label:   "LD A, (DE)",
 	value:   0x1A,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		c.accFlagReg[0] = c.ram.ReadByte(c.deREG.toUint16())
@@ -324,7 +327,7 @@ var opcd = opcode{
 	cycles4: 24,
 	label:   "CALL a16",
 	value:   0xCD,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flag changes
 		c.pc++
 		// first byte is smaller, second byte is larger. OR'd together, it's little-endian
@@ -342,7 +345,7 @@ var op13 = opcode{
 	cycles4: 8,
 	label:   "INC DE",
 	value:   0x13,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.deREG.fromUint16(c.deREG.toUint16()+1)
@@ -355,7 +358,7 @@ var op7b = opcode{
 	cycles4: 4,
 	label:   "LD A, E",
 	value:   0x7B,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.accFlagReg[0] = c.deREG[1]
@@ -368,7 +371,7 @@ var opfe = opcode{
 	cycles4: 8,
 	label:   "CP d8",
 	value:   0xfe,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z 1 H C
 		c.pc++
 		res := c.accFlagReg[0] - c.ram.ReadByte(c.pc)
@@ -388,7 +391,7 @@ var op06 = opcode{
 	cycles4: 8,
 	label:   "LD B, d8",
 	value:   0x06,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.bcREG[0] = c.ram.ReadByte(c.pc)
@@ -402,7 +405,7 @@ var op23 = opcode{
 	cycles4: 8,
 	label:   "INC HL",
 	value:   0x23,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.hlREG.fromUint16(c.hlREG.toUint16()+1)
@@ -415,7 +418,7 @@ var op05 = opcode{
 	cycles4: 4,
 	label:   "DEC B",
 	value:   0x05,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z1H
 		c.pc++
 		c.setFlag(flagHalfCarry, c.bcREG[0]&0xF == 0)
@@ -431,7 +434,7 @@ var opea = opcode{
 	cycles4: 16,
 	label:   "LD (a16), A",
 	value:   0xEA,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		targetAddr := uint16(c.ram.ReadByte(c.pc)) | uint16(c.ram.ReadByte(c.pc+1))<<8
@@ -446,7 +449,7 @@ var op3d = opcode{
 	cycles4: 4,
 	label:   "DEC A",
 	value:   0x3d,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z1H
 		c.pc++
 		c.setFlag(flagHalfCarry, c.accFlagReg[0]&0xF == 0)
@@ -462,7 +465,7 @@ var op28 = opcode{
 	cycles4: 8, //12 if jump is taken
 	label:   "JR Z, r8",
 	value:   0x28,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		if c.getFlag(flagZero) {
@@ -481,7 +484,7 @@ var op0d = opcode{
 	cycles4: 4,
 	label:   "DEC C",
 	value:   0x0D,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z1H
 		c.pc++
 		c.setFlag(flagHalfCarry, c.bcREG[1]&0xF == 0)
@@ -497,7 +500,7 @@ var op2e = opcode{
 	cycles4: 8,
 	label:   "LD L, d8",
 	value:   0x2e,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.hlREG[1] = c.ram.ReadByte(c.pc)
@@ -511,7 +514,7 @@ var op18 = opcode{
 	cycles4: 12,
 	label:   "JR r8",
 	value:   0x18,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.pc++
 		relJump := int8(c.ram.ReadByte(c.pc))
@@ -526,7 +529,7 @@ var op67 = opcode{
 	cycles4: 4,
 	label:   "LD H, A",
 	value:   0x67,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.hlREG[0] = c.accFlagReg[0]
@@ -539,7 +542,7 @@ var op04 = opcode{
 	cycles4: 4,
 	label:   "INC B",
 	value:   0x04,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z0H flags
 		c.pc++
 		c.bcREG[0]++
@@ -559,7 +562,7 @@ var op1e = opcode{
 	cycles4: 8,
 	label:   "LD E, d8",
 	value:   0x1E,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.deREG[1] = c.ram.ReadByte(c.pc)
@@ -573,7 +576,7 @@ var opf0 = opcode{
 	cycles4: 12,
 	label:   "LDH A, (a8)",
 	value:   0xF0,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		contents := c.ram.ReadByte(0xFF00+uint16(c.ram.ReadByte(c.pc)))
@@ -588,7 +591,7 @@ var op1d = opcode{
 	cycles4: 4,
 	label:   "DEC E",
 	value:   0x05,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z1H
 		c.pc++
 		c.setFlag(flagHalfCarry, c.deREG[1]&0xF == 0)
@@ -604,7 +607,7 @@ var op24 = opcode{
 	cycles4: 4,
 	label:   "INC H",
 	value:   0x24,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z0H flags
 		c.pc++
 		c.hlREG[0]++
@@ -624,7 +627,7 @@ var op7c = opcode{
 	cycles4: 4,
 	label:   "LD A, H",
 	value:   0x7C,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.accFlagReg[0] = c.hlREG[0]
@@ -637,7 +640,7 @@ var op90 = opcode{
 	cycles4: 4,
 	label:   "SUB B",
 	value:   0x90,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z1HC
 		c.pc++
 		c.setFlag(flagHalfCarry, int16(c.accFlagReg[0]&0xF) - int16(c.bcREG[0]&0xF) < 0)
@@ -655,7 +658,7 @@ var op42 = opcode{
 	cycles4: 4,
 	label:   "LD B, D",
 	value:   0x42,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.bcREG[0] = c.deREG[0]
@@ -668,7 +671,7 @@ var op15 = opcode{
 	cycles4: 4,
 	label:   "DEC D",
 	value:   0x15,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z1H
 		c.pc++
 		c.setFlag(flagHalfCarry, c.deREG[0]&0xF == 0)
@@ -684,10 +687,10 @@ var op16 = opcode{
 	cycles4: 8,
 	label:   "LD D, d8",
 	value:   0x16,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
-		c.bcREG[0] = c.ram.ReadByte(c.pc)
+		c.deREG[0] = c.ram.ReadByte(c.pc)
 		c.pc++
 	},
 }
@@ -698,7 +701,7 @@ var op17 = opcode{
 	cycles4: 4,
 	label:   "RLA",
 	value:   0x17,
-	impl: func() {
+	impl: func(c *CPU) {
 		//000C
 		c.pc++
 		carryFlag := byte(0)
@@ -719,7 +722,7 @@ var opc5 = opcode{
 	cycles4: 16,
 	label:   "PUSH BC",
 	value:   0xC5,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.pushWord(c.bcREG.toUint16())
@@ -732,7 +735,7 @@ var opc1 = opcode{
 	cycles4: 12,
 	label:   "POP BC",
 	value:   0xC1,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags changed
 		c.pc++
 		c.bcREG.fromUint16(c.popWord())
@@ -745,7 +748,7 @@ var op22 = opcode{
 	cycles4: 8,
 	label:   "LD (HL+), A",
 	value:   0x22,
-	impl: func() {
+	impl: func(c *CPU) {
 		// no flag changes
 		c.ram.WriteByte(c.hlREG.toUint16(), c.accFlagReg[0])
 		c.hlREG.fromUint16(c.hlREG.toUint16()+1)
@@ -759,7 +762,7 @@ var opc9 = opcode{
 	cycles4: 16,
 	label:   "RET",
 	value:   0xC9,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flag changes
 		// PC is getting clobbered, no point in incrementing
 		c.pc = c.popWord()
@@ -772,16 +775,16 @@ var opce = opcode{
 	cycles4: 8,
 	label:   "ADC A, d8",
 	value:   0xCE,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z0HC
 		c.pc++
 		carry := byte(0)
 		if c.getFlag(flagCarry) {
 			carry = 0x1
 		}
-		res := int16(c.accFlagReg[0] + c.ram.ReadByte(c.pc) + carry)
+		res := int(c.accFlagReg[0]) + int(c.ram.ReadByte(c.pc)) + int(carry)
 
-		c.setFlag(flagZero, res == 0)
+		c.setFlag(flagZero, byte(res) == 0)
 		c.setFlag(flagSubtract, false)
 		c.setFlag(flagHalfCarry, c.accFlagReg[0]&0xF + c.ram.ReadByte(c.pc)&0xF + carry > 0xF)
 		c.setFlag(flagCarry, res > 0xFF)
@@ -796,7 +799,7 @@ var op7d = opcode{
 	cycles4: 4,
 	label:   "LD A, L",
 	value:   0x7D,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags set
 		c.pc++
 		c.accFlagReg[0] = c.hlREG[1]
@@ -809,7 +812,7 @@ var op78 = opcode{
 	cycles4: 4,
 	label:   "LD A, B",
 	value:   0x7D,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags set
 		c.pc++
 		c.accFlagReg[0] = c.bcREG[0]
@@ -822,14 +825,14 @@ var op86 = opcode{
 	cycles4: 8,
 	label:   "ADD A, (HL)",
 	value:   0x86,
-	impl: func() {
+	impl: func(c *CPU) {
 		//Z0HC
 		c.pc++
 		other := c.ram.ReadByte(c.hlREG.toUint16())
 
-		res := int16(c.accFlagReg[0] + other)
+		res := int(c.accFlagReg[0]) + int(other)
 
-		c.setFlag(flagZero, res == 0)
+		c.setFlag(flagZero, byte(res) == 0)
 		c.setFlag(flagSubtract, false)
 		c.setFlag(flagHalfCarry, c.accFlagReg[0]&0xF + other&0xF > 0xF)
 		c.setFlag(flagCarry, res > 0xFF)
@@ -843,7 +846,7 @@ var op50 = opcode{
 	cycles4: 4,
 	label:   "LD D, B",
 	value:   0x50,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags set
 		c.pc++
 		c.deREG[0] = c.bcREG[0]
@@ -855,7 +858,7 @@ var op4f = opcode{
 	cycles4: 4,
 	label:   "LD C, A",
 	value:   0x4F,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags set
 		c.pc++
 		c.bcREG[1] = c.accFlagReg[0]
@@ -867,9 +870,61 @@ var op57 = opcode{
 	cycles4: 4,
 	label:   "LD D, A",
 	value:   0x57,
-	impl: func() {
+	impl: func(c *CPU) {
 		//no flags set
 		c.pc++
 		c.deREG[0] = c.accFlagReg[0]
 	},
-}
\ No newline at end of file
+}
+
+//op76 halts the CPU until an interrupt is pending
+var op76 = opcode{
+	length:  1,
+	cycles4: 4,
+	label:   "HALT",
+	value:   0x76,
+	impl: func(c *CPU) {
+		//no flags changed
+		c.pc++
+		c.halted = true
+	},
+}
+
+//opf3 disables interrupts
+var opf3 = opcode{
+	length:  1,
+	cycles4: 4,
+	label:   "DI",
+	value:   0xF3,
+	impl: func(c *CPU) {
+		// no flag changes
+		c.pc++
+		c.interruptEnabled = false
+	},
+}
+
+//opd9 returns from an interrupt handler, re-enabling interrupts
+var opd9 = opcode{
+	length:  1,
+	cycles4: 16,
+	label:   "RETI",
+	value:   0xD9,
+	impl: func(c *CPU) {
+		//no flag changes
+		c.pc = c.popWord()
+		c.interruptEnabled = true
+	},
+}
+
+//op10 stops the CPU.  On CGB this is how a speed switch armed through KEY1 is performed
+var op10 = opcode{
+	length:  2,
+	cycles4: 4,
+	label:   "STOP",
+	value:   0x10,
+	impl: func(c *CPU) {
+		//no flags changed
+		c.pc += 2
+		c.stop()
+	},
+}
@@ -0,0 +1,36 @@
+package cpu
+
+// Registers is a copy of the CPU's registers, for debuggers and tracers
+type Registers struct {
+	A, F, B, C, D, E, H, L byte
+	SP, PC                 uint16
+	IME                    bool // interrupts enabled
+	Halted                 bool
+}
+
+func (r Registers) AF() uint16 { return uint16(r.A)<<8 | uint16(r.F) }
+func (r Registers) BC() uint16 { return uint16(r.B)<<8 | uint16(r.C) }
+func (r Registers) DE() uint16 { return uint16(r.D)<<8 | uint16(r.E) }
+func (r Registers) HL() uint16 { return uint16(r.H)<<8 | uint16(r.L) }
+
+// flag bits in F
+const (
+	FlagZ = 1 << flagZero
+	FlagN = 1 << flagSubtract
+	FlagH = 1 << flagHalfCarry
+	FlagC = 1 << flagCarry
+)
+
+//Regs returns a copy of the CPU's registers
+func (c *CPU) Regs() Registers {
+	return Registers{
+		A: c.accFlagReg[0], F: c.accFlagReg[1],
+		B: c.bcREG[0], C: c.bcREG[1],
+		D: c.deREG[0], E: c.deREG[1],
+		H: c.hlREG[0], L: c.hlREG[1],
+		SP:     c.sp,
+		PC:     c.pc,
+		IME:    c.interruptEnabled,
+		Halted: c.halted,
+	}
+}
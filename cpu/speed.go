@@ -0,0 +1,50 @@
+package cpu
+
+const (
+	// RegKEY1 prepares a CGB speed switch, which the next STOP performs
+	RegKEY1 = 0xFF4D
+
+	// speedSwitchCycles is roughly how long the CPU is paused while the clock changes speed
+	speedSwitchCycles = 8200
+)
+
+// speedRegister handles KEY1.  bit 7 reads the current speed, bit 0 arms a switch
+type speedRegister struct {
+	c *CPU
+}
+
+func (r speedRegister) Read(addr uint16) byte {
+	c := r.c
+	val := byte(0x7E)
+	if c.doubleSpeed {
+		val |= 0x80
+	}
+	if c.speedArmed {
+		val |= 0x01
+	}
+	return val
+}
+
+func (r speedRegister) Write(addr uint16, val byte) {
+	r.c.speedArmed = val&0x01 != 0
+}
+
+//DoubleSpeed returns whether the CPU is running at 8MHz, in CGB double speed mode
+func (c *CPU) DoubleSpeed() bool {
+	return c.doubleSpeed
+}
+
+//stop performs an armed speed switch.  Without one, STOP is treated like HALT
+func (c *CPU) stop() {
+	if !c.speedArmed {
+		c.halted = true
+		return
+	}
+	c.speedArmed = false
+	c.doubleSpeed = !c.doubleSpeed
+	c.stall += speedSwitchCycles
+}
+
+func (c *CPU) mapSpeedRegister() {
+	c.ram.Map(RegKEY1, RegKEY1, speedRegister{c})
+}
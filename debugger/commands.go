@@ -0,0 +1,364 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/raidancampbell/goby/cpu"
+	"github.com/raidancampbell/goby/disasm"
+)
+
+const help = `commands:
+  s, step [n]            run n instructions (default 1)
+  n, next                step, running over CALLs and RSTs
+  finish                 run until the current call returns
+  c, continue            run until a breakpoint or watchpoint
+  b, break ADDR [if C]   break before the instruction at ADDR, optionally only if C holds,
+                         e.g. "if A == 0 && HL >= C000". registers: A F B C D E H L AF BC DE HL SP PC
+  w, watch ADDR[-END] [r|w|rw]
+                         break after an instruction reads or writes memory (default w)
+  d, delete N            delete breakpoint or watchpoint N
+  i, breaks              list breakpoints and watchpoints
+  r, regs                show registers and flags
+  x ADDR [LEN]           dump memory (default 64 bytes)
+  bt                     show the call stack
+  q, quit                stop the emulator
+numbers are hex. addresses can also be symbols, e.g. Main.loop or Main.loop+12. an empty line repeats the last command`
+
+type pointKind int
+
+const (
+	breakpoint pointKind = iota
+	watchpoint
+)
+
+// point is a breakpoint or a watchpoint. they share ids, so either can be deleted by number
+type point struct {
+	id         int
+	kind       pointKind
+	addr, end  uint16
+	bank       int        // the ROM bank a breakpoint set by symbol is in, or -1 for any
+	label      string     // the address, and its symbol if there is one
+	conditions conditions // breakpoints only
+	read       bool       // watchpoints only
+	write      bool
+}
+
+func (p *point) String() string {
+	if p.kind == breakpoint {
+		s := fmt.Sprintf("%d: break %s", p.id, p.label)
+		if len(p.conditions) > 0 {
+			s += " if " + p.conditions.String()
+		}
+		return s
+	}
+	access := map[[2]bool]string{{true, false}: "r", {false, true}: "w", {true, true}: "rw"}[[2]bool{p.read, p.write}]
+	if p.end == p.addr {
+		return fmt.Sprintf("%d: watch %s %s", p.id, p.label, access)
+	}
+	return fmt.Sprintf("%d: watch %s-%04x %s", p.id, p.label, p.end, access)
+}
+
+//Prompt shows where execution paused, then reads and runs commands until one resumes it.
+// it returns false once the emulator should stop: on quit, or when the input runs out
+func (d *Debugger) Prompt() bool {
+	fmt.Fprintf(d.out, "%s\n", d.reason)
+	d.showInstruction(d.gb.CPU.Regs().PC)
+	for {
+		fmt.Fprint(d.out, "(goby) ")
+		if !d.in.Scan() {
+			return false
+		}
+		line := strings.TrimSpace(d.in.Text())
+		if line == "" {
+			line = d.last
+		}
+		d.last = line
+		quit, err := d.run(strings.Fields(line))
+		if err != nil {
+			fmt.Fprintf(d.out, "error: %v\n", err)
+		}
+		if quit {
+			return false
+		}
+		if !d.paused {
+			return true
+		}
+	}
+}
+
+//run runs one command, and returns true if it was quit
+func (d *Debugger) run(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case "s", "step":
+		n := 1
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v < 1 {
+				return false, fmt.Errorf("bad step count %q", args[1])
+			}
+			n = v
+		}
+		d.steps = n
+		d.resume()
+	case "n", "next":
+		r := d.gb.CPU.Regs()
+		inst := d.decode(r.PC)
+		if !inst.Call {
+			d.steps = 1
+		} else {
+			ret, depth := r.PC+uint16(len(inst.Bytes)), len(d.stack)
+			d.until = func(r cpu.Registers) bool {
+				return r.PC == ret && len(d.stack) <= depth
+			}
+		}
+		d.resume()
+	case "finish":
+		depth := len(d.stack)
+		if depth == 0 {
+			return false, errors.New("not in a call")
+		}
+		d.until = func(cpu.Registers) bool {
+			return len(d.stack) < depth
+		}
+		d.resume()
+	case "c", "continue":
+		d.resume()
+	case "b", "break":
+		return false, d.addBreakpoint(args[1:])
+	case "w", "watch":
+		return false, d.addWatchpoint(args[1:])
+	case "d", "delete":
+		return false, d.delete(args[1:])
+	case "i", "breaks":
+		for _, p := range d.points {
+			fmt.Fprintln(d.out, p)
+		}
+	case "r", "regs":
+		d.showRegisters()
+	case "x":
+		return false, d.dump(args[1:])
+	case "bt":
+		d.backtrace()
+	case "h", "help":
+		fmt.Fprintln(d.out, help)
+	case "q", "quit":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command %q. try help", args[0])
+	}
+	return false, nil
+}
+
+func (d *Debugger) addBreakpoint(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: break ADDR [if CONDITION]")
+	}
+	addr, bank, err := d.parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	p := &point{kind: breakpoint, addr: addr, bank: bank, label: d.location(bank, addr)}
+	if bank < 0 {
+		p.label = d.location(d.bank(addr), addr)
+	}
+	if len(args) > 1 {
+		if args[1] != "if" {
+			return fmt.Errorf("expected if, got %q", args[1])
+		}
+		if p.conditions, err = parseConditions(args[2:]); err != nil {
+			return err
+		}
+	}
+	d.add(p)
+	return nil
+}
+
+func (d *Debugger) addWatchpoint(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: watch ADDR[-END] [r|w|rw]")
+	}
+	p := &point{kind: watchpoint}
+	bounds := strings.SplitN(args[0], "-", 2)
+	var err error
+	if p.addr, _, err = d.parseAddress(bounds[0]); err != nil {
+		return err
+	}
+	p.end = p.addr
+	p.label = d.location(d.bank(p.addr), p.addr)
+	if len(bounds) == 2 {
+		if p.end, _, err = d.parseAddress(bounds[1]); err != nil {
+			return err
+		}
+		if p.end < p.addr {
+			return fmt.Errorf("range %s ends before it starts", args[0])
+		}
+	}
+	access := "w"
+	if len(args) > 1 {
+		access = args[1]
+	}
+	switch access {
+	case "r":
+		p.read = true
+	case "w":
+		p.write = true
+	case "rw":
+		p.read, p.write = true, true
+	default:
+		return fmt.Errorf("unknown access %q: use r, w or rw", access)
+	}
+	d.add(p)
+	d.watch()
+	return nil
+}
+
+func (d *Debugger) add(p *point) {
+	p.id = d.nextID
+	d.nextID++
+	d.points = append(d.points, p)
+	fmt.Fprintln(d.out, p)
+}
+
+func (d *Debugger) delete(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: delete N")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("bad number %q", args[0])
+	}
+	for i, p := range d.points {
+		if p.id == id {
+			d.points = append(d.points[:i], d.points[i+1:]...)
+			d.watch()
+			return nil
+		}
+	}
+	return fmt.Errorf("no breakpoint or watchpoint %d", id)
+}
+
+//decode disassembles the instruction at addr
+func (d *Debugger) decode(addr uint16) disasm.Instruction {
+	var data [3]byte
+	for i := range data {
+		data[i] = d.gb.RAM.Peek(addr + uint16(i))
+	}
+	inst, _ := disasm.Decode(data[:], addr)
+	return inst
+}
+
+//showInstruction prints the instruction at addr, with its bytes
+func (d *Debugger) showInstruction(addr uint16) {
+	inst := d.decode(addr)
+	var raw []string
+	for _, b := range inst.Bytes {
+		raw = append(raw, fmt.Sprintf("%02x", b))
+	}
+	bank := d.bank(addr)
+	names := func(target uint16) string {
+		if target >= 0x4000 && target < 0x8000 {
+			return d.Symbols.Describe(d.bank(target), target)
+		}
+		return d.Symbols.Describe(0, target)
+	}
+	fmt.Fprintf(d.out, "%s: %-9s %s\n", d.location(bank, addr), strings.Join(raw, " "), inst.Format(names))
+}
+
+func (d *Debugger) showRegisters() {
+	r := d.gb.CPU.Regs()
+	flags := []byte("----")
+	for i, f := range []byte{cpu.FlagZ, cpu.FlagN, cpu.FlagH, cpu.FlagC} {
+		if r.F&f != 0 {
+			flags[i] = "ZNHC"[i]
+		}
+	}
+	fmt.Fprintf(d.out, "AF=%04x BC=%04x DE=%04x HL=%04x SP=%04x PC=%04x flags=%s ime=%t halted=%t\n",
+		r.AF(), r.BC(), r.DE(), r.HL(), r.SP, r.PC, flags, r.IME, r.Halted)
+}
+
+//dump prints memory as hex and ASCII, 16 bytes to a line
+func (d *Debugger) dump(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: x ADDR [LEN]")
+	}
+	addr, _, err := d.parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	length := 64
+	if len(args) > 1 {
+		n, err := parseHex(args[1])
+		if err != nil {
+			return err
+		}
+		length = int(n)
+	}
+	for line := 0; line < length; line += 16 {
+		var hex, text strings.Builder
+		for i := line; i < line+16 && i < length; i++ {
+			b := d.gb.RAM.Peek(addr + uint16(i))
+			fmt.Fprintf(&hex, "%02x ", b)
+			if b < 0x20 || b > 0x7E {
+				b = '.'
+			}
+			text.WriteByte(b)
+		}
+		fmt.Fprintf(d.out, "%04x: %-48s %s\n", addr+uint16(line), hex.String(), text.String())
+	}
+	return nil
+}
+
+//backtrace prints the call stack, innermost first
+func (d *Debugger) backtrace() {
+	pc := d.gb.CPU.Regs().PC
+	fmt.Fprintf(d.out, "#0 %s\n", d.location(d.bank(pc), pc))
+	for i := len(d.stack) - 1; i >= 0; i-- {
+		f := d.stack[i]
+		caller, target := d.location(f.callerBank, f.caller), d.location(f.targetBank, f.target)
+		if f.interrupt != "" {
+			fmt.Fprintf(d.out, "#%d %s  (%s interrupt at %s)\n", len(d.stack)-i, caller, f.interrupt, target)
+		} else {
+			fmt.Fprintf(d.out, "#%d %s  (call to %s)\n", len(d.stack)-i, caller, target)
+		}
+	}
+}
+
+//parseAddress reads an address in hex, or a symbol, optionally plus a hex offset.  For symbols in switched ROM,
+// bank is the bank it's in. otherwise it's -1
+func (d *Debugger) parseAddress(s string) (addr uint16, bank int, err error) {
+	name, offset := s, uint16(0)
+	if i := strings.LastIndexByte(s, '+'); i > 0 {
+		if offset, err = parseHex(s[i+1:]); err != nil {
+			return 0, 0, err
+		}
+		name = s[:i]
+	}
+	if sym, ok := d.Symbols.Lookup(name); ok {
+		bank = -1
+		if sym.Addr >= 0x4000 && sym.Addr < 0x8000 {
+			bank = sym.Bank
+		}
+		return sym.Addr + offset, bank, nil
+	}
+	if offset != 0 {
+		return 0, 0, fmt.Errorf("unknown symbol %q", name)
+	}
+	addr, err = parseHex(s)
+	return addr, -1, err
+}
+
+//parseHex reads an address or value, in hex with an optional $ or 0x prefix
+func parseHex(s string) (uint16, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "$"), "0x")
+	v, err := strconv.ParseUint(trimmed, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad hex number %q", s)
+	}
+	return uint16(v), nil
+}
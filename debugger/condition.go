@@ -0,0 +1,95 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raidancampbell/goby/cpu"
+)
+
+// condition compares a register with a value, e.g. A == 3
+type condition struct {
+	reg string
+	op  string
+	val uint16
+}
+
+// conditions must all hold for a breakpoint to break
+type conditions []condition
+
+var registers = map[string]func(r cpu.Registers) uint16{
+	"A": func(r cpu.Registers) uint16 { return uint16(r.A) },
+	"F": func(r cpu.Registers) uint16 { return uint16(r.F) },
+	"B": func(r cpu.Registers) uint16 { return uint16(r.B) },
+	"C": func(r cpu.Registers) uint16 { return uint16(r.C) },
+	"D": func(r cpu.Registers) uint16 { return uint16(r.D) },
+	"E": func(r cpu.Registers) uint16 { return uint16(r.E) },
+	"H": func(r cpu.Registers) uint16 { return uint16(r.H) },
+	"L": func(r cpu.Registers) uint16 { return uint16(r.L) },
+
+	"AF": cpu.Registers.AF,
+	"BC": cpu.Registers.BC,
+	"DE": cpu.Registers.DE,
+	"HL": cpu.Registers.HL,
+	"SP": func(r cpu.Registers) uint16 { return r.SP },
+	"PC": func(r cpu.Registers) uint16 { return r.PC },
+}
+
+var comparisons = map[string]func(a, b uint16) bool{
+	"==": func(a, b uint16) bool { return a == b },
+	"!=": func(a, b uint16) bool { return a != b },
+	"<":  func(a, b uint16) bool { return a < b },
+	">":  func(a, b uint16) bool { return a > b },
+	"<=": func(a, b uint16) bool { return a <= b },
+	">=": func(a, b uint16) bool { return a >= b },
+}
+
+//parseConditions reads conditions joined by &&, e.g. A == 3 && HL >= C000.  Every token must be separated by spaces
+func parseConditions(args []string) (conditions, error) {
+	var out conditions
+	for len(args) > 0 {
+		if len(args) < 3 {
+			return nil, fmt.Errorf("incomplete condition %q", strings.Join(args, " "))
+		}
+		reg := strings.ToUpper(args[0])
+		if _, ok := registers[reg]; !ok {
+			return nil, fmt.Errorf("unknown register %q", args[0])
+		}
+		if _, ok := comparisons[args[1]]; !ok {
+			return nil, fmt.Errorf("unknown comparison %q", args[1])
+		}
+		val, err := parseHex(args[2])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, condition{reg: reg, op: args[1], val: val})
+		args = args[3:]
+		if len(args) > 0 {
+			if args[0] != "&&" {
+				return nil, fmt.Errorf("expected &&, got %q", args[0])
+			}
+			args = args[1:]
+			if len(args) == 0 {
+				return nil, fmt.Errorf("condition missing after &&")
+			}
+		}
+	}
+	return out, nil
+}
+
+func (cs conditions) match(r cpu.Registers) bool {
+	for _, c := range cs {
+		if !comparisons[c.op](registers[c.reg](r), c.val) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cs conditions) String() string {
+	var parts []string
+	for _, c := range cs {
+		parts = append(parts, fmt.Sprintf("%s %s %x", c.reg, c.op, c.val))
+	}
+	return strings.Join(parts, " && ")
+}
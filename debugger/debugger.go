@@ -0,0 +1,205 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/raidancampbell/goby/cpu"
+	"github.com/raidancampbell/goby/gameboy"
+	"github.com/raidancampbell/goby/symbols"
+)
+
+// interrupt vectors, where the CPU jumps when it dispatches an interrupt
+var vectors = map[uint16]string{0x40: "vblank", 0x48: "stat", 0x50: "timer", 0x58: "serial", 0x60: "joypad"}
+
+// Debugger is a command line debugger that stops the GameBoy before chosen instructions, and inspects it.
+// it hooks in before every instruction, which also lets it follow CALLs and RETs to keep a call stack
+type Debugger struct {
+	// Symbols, if set, name addresses, and can be used in place of them in commands
+	Symbols *symbols.Table
+
+	gb  *gameboy.GameBoy
+	in  *bufio.Scanner
+	out io.Writer
+
+	points []*point
+	nextID int
+	stack  []frame
+
+	paused  bool
+	reason  string // why execution paused, shown at the prompt
+	resumed bool   // the next instruction runs without checking breakpoints, so execution can leave one
+	steps   int    // instructions left to step, or 0 if not stepping
+	// until, if set, pauses execution once it returns true, e.g. when stepping over a CALL
+	until func(r cpu.Registers) bool
+	last  string // the last command, repeated by an empty line
+
+	// what the CPU was about to run at the last hook, for following the call stack
+	prev cpu.Registers
+	op   byte
+}
+
+// frame is a call in progress: where it was called from, and where to
+type frame struct {
+	caller, target         uint16
+	callerBank, targetBank int
+	interrupt              string // the interrupt that was dispatched, if it wasn't a call
+}
+
+//New attaches a debugger to the GameBoy, reading commands from in and writing to out.
+// execution runs freely until a breakpoint or Break
+func New(gb *gameboy.GameBoy, in io.Reader, out io.Writer) *Debugger {
+	d := &Debugger{gb: gb, in: bufio.NewScanner(in), out: out, nextID: 1}
+	d.prev = gb.CPU.Regs()
+	d.op = gb.RAM.Peek(d.prev.PC)
+	gb.BeforeStep = d.beforeStep
+	return d
+}
+
+//Break pauses execution before the next instruction, e.g. at startup or on a hotkey
+func (d *Debugger) Break() {
+	d.pause("break")
+}
+
+//Paused returns whether execution is paused, waiting for Prompt
+func (d *Debugger) Paused() bool {
+	return d.paused
+}
+
+func (d *Debugger) pause(reason string) {
+	d.paused = true
+	d.reason = reason
+	d.steps = 0
+	d.until = nil
+}
+
+//resume lets execution continue.  The instruction it's paused at runs first, without hitting its own breakpoint again
+func (d *Debugger) resume() {
+	d.paused = false
+	d.resumed = true
+}
+
+//beforeStep is called by the GameBoy before each instruction, and returns false to pause before it
+func (d *Debugger) beforeStep() bool {
+	r := d.gb.CPU.Regs()
+	d.follow(r)
+	if d.paused {
+		return false
+	}
+	if d.resumed {
+		d.resumed = false
+		return true
+	}
+	if d.steps > 0 {
+		d.steps--
+		if d.steps == 0 {
+			d.pause("step")
+			return false
+		}
+	}
+	if d.until != nil && d.until(r) {
+		d.pause("step")
+		return false
+	}
+	if r.Halted {
+		// the CPU idles at the same PC until an interrupt, which shouldn't hit its breakpoint every time
+		return true
+	}
+	for _, p := range d.points {
+		if p.kind == breakpoint && p.addr == r.PC && (p.bank < 0 || p.bank == d.bank(r.PC)) && p.conditions.match(r) {
+			d.pause(fmt.Sprintf("breakpoint %d", p.id))
+			return false
+		}
+	}
+	return true
+}
+
+//follow updates the call stack from what the last instruction did.  A CALL or RST pushes its return address,
+// and a RET pops one, so comparing SP with before the instruction shows whether a conditional one was taken
+func (d *Debugger) follow(r cpu.Registers) {
+	prev, op := d.prev, d.op
+	d.prev, d.op = r, d.gb.RAM.Peek(r.PC)
+	if r.PC == prev.PC && r.SP == prev.SP {
+		// halted, stalled, or paused and resumed at the same place
+		return
+	}
+	if name, ok := vectors[r.PC]; ok && prev.IME && !r.IME && r.SP == prev.SP-2 {
+		// the interrupt was dispatched instead of the instruction
+		d.stack = append(d.stack, d.frame(prev.PC, r.PC, name))
+		return
+	}
+	switch {
+	case isCall(op) && r.SP == prev.SP-2:
+		d.stack = append(d.stack, d.frame(prev.PC, r.PC, ""))
+	case isReturn(op) && r.SP == prev.SP+2 && len(d.stack) > 0:
+		d.stack = d.stack[:len(d.stack)-1]
+	}
+}
+
+//frame records a call, with the banks that were switched in at the time
+func (d *Debugger) frame(caller, target uint16, interrupt string) frame {
+	return frame{caller: caller, target: target, callerBank: d.bank(caller), targetBank: d.bank(target), interrupt: interrupt}
+}
+
+//bank returns the ROM bank an address is in right now.  Outside ROM, it's 0
+func (d *Debugger) bank(addr uint16) int {
+	if addr >= 0x4000 && addr < 0x8000 {
+		return d.gb.MBC.ROMBank()
+	}
+	return 0
+}
+
+//location shows an address, with its symbol if there is one, e.g. 4123 <Main.loop+$3>
+func (d *Debugger) location(bank int, addr uint16) string {
+	if name := d.Symbols.Describe(bank, addr); name != "" {
+		return fmt.Sprintf("%04x <%s>", addr, name)
+	}
+	return fmt.Sprintf("%04x", addr)
+}
+
+//isCall returns whether the opcode is a CALL, conditional or not, or an RST
+func isCall(op byte) bool {
+	switch op {
+	case 0xCD, 0xC4, 0xCC, 0xD4, 0xDC:
+		return true
+	}
+	return op&0xC7 == 0xC7
+}
+
+//isReturn returns whether the opcode is a RET, conditional or not, or a RETI
+func isReturn(op byte) bool {
+	switch op {
+	case 0xC9, 0xD9, 0xC0, 0xC8, 0xD0, 0xD8:
+		return true
+	}
+	return false
+}
+
+//onAccess pauses execution after an instruction touches a watched address
+func (d *Debugger) onAccess(addr uint16, val byte, write bool) {
+	if d.paused {
+		return
+	}
+	for _, p := range d.points {
+		if p.kind == watchpoint && addr >= p.addr && addr <= p.end && (write && p.write || !write && p.read) {
+			verb := "read"
+			if write {
+				verb = "write"
+			}
+			d.pause(fmt.Sprintf("watchpoint %d: %s %02x at %04x", p.id, verb, val, addr))
+			return
+		}
+	}
+}
+
+//watch only hooks memory accesses while there are watchpoints, as it slows down every read and write
+func (d *Debugger) watch() {
+	d.gb.RAM.OnAccess = nil
+	for _, p := range d.points {
+		if p.kind == watchpoint {
+			d.gb.RAM.OnAccess = d.onAccess
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/cpu"
+	"github.com/raidancampbell/goby/gameboy"
+	"github.com/raidancampbell/goby/internal/testrom"
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/symbols"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//callROM calls a subroutine that writes to C001, then halts
+func callROM() *cartridge.ROM {
+	rom := testrom.New(
+		0x31, 0xFE, 0xFF, // LD SP,$FFFE
+		0xCD, 0x00, 0x02, // CALL $0200
+		0x76, // HALT
+	)
+	copy((*rom)[0x0200:], []byte{
+		0x21, 0x01, 0xC0, // LD HL,$C001
+		0x32, // LD (HL-),A
+		0xC9, // RET
+	})
+	return rom
+}
+
+func TestDebugger(t *testing.T) {
+	gb := gameboy.New(callROM(), gameboy.ModelDMG, &render.Headless{})
+	script := strings.Join([]string{
+		"break Sub",
+		"c",
+		"bt",
+		"watch c001",
+		"c",
+		"finish",
+		"bt",
+		"quit",
+	}, "\n")
+	var out bytes.Buffer
+	d := New(gb, strings.NewReader(script), &out)
+	var err error
+	d.Symbols, err = symbols.Parse(strings.NewReader("00:0100 Main\n00:0200 Sub\n00:C000 wBuffer\n"))
+	require.NoError(t, err)
+	d.Break()
+	for i := 0; i < 10; i++ {
+		err := gb.RunFrame()
+		if err == gameboy.ErrBreak {
+			if !d.Prompt() {
+				break
+			}
+			continue
+		}
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, uint16(0x0106), gb.CPU.Regs().PC)
+	printed := out.String()
+	assert.Contains(t, printed, "1: break 0200 <Sub>\n")
+	assert.Contains(t, printed, "breakpoint 1\n0200 <Sub>: 21 01 c0  LD HL,$C001")
+	assert.Contains(t, printed, "#1 0103 <Main+$3>  (call to 0200 <Sub>)")
+	assert.Contains(t, printed, "watchpoint 2: write 01 at c001\n0204 <Sub+$4>: c9")
+	assert.Contains(t, printed, "step\n0106 <Main+$6>: 76")
+	// the call has returned
+	assert.True(t, strings.HasSuffix(printed, "(goby) #0 0106 <Main+$6>\n(goby) "), printed)
+}
+
+func TestParseConditions(t *testing.T) {
+	cs, err := parseConditions(strings.Fields("a == 3 && HL >= $C000"))
+	require.NoError(t, err)
+	assert.Equal(t, "A == 3 && HL >= c000", cs.String())
+	assert.True(t, cs.match(cpu.Registers{A: 3, H: 0xC0, L: 0x10}))
+	assert.False(t, cs.match(cpu.Registers{A: 3, H: 0xBF}))
+	assert.False(t, cs.match(cpu.Registers{A: 4, H: 0xC0}))
+
+	for _, bad := range []string{"A ==", "Q == 1", "A =~ 1", "A == 1 &&", "A == 1 B == 2", "A == zz"} {
+		_, err := parseConditions(strings.Fields(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestDebugger_watchHardware(t *testing.T) {
+	gb := gameboy.New(callROM(), gameboy.ModelDMG, &render.Headless{})
+	var out bytes.Buffer
+	d := New(gb, strings.NewReader("watch ff44\nwatch fe00\nc\n"), &out)
+	d.Break()
+	require.Equal(t, gameboy.ErrBreak, gb.RunFrame())
+	require.True(t, d.Prompt())
+	// the PPU writing LY and reading OAM every line isn't the CPU, so neither trips a watchpoint
+	for i := 0; i < 3; i++ {
+		require.NoError(t, gb.RunFrame())
+	}
+	assert.NotContains(t, out.String(), "watchpoint 1:")
+	assert.NotContains(t, out.String(), "watchpoint 2:")
+}
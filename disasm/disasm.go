@@ -0,0 +1,201 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operand placeholders in the tables below, and how they're shown once decoded:
+//  d8, d16: immediate data, e.g. $12 or $1234
+//  a8: the low byte of an address in FF00-FFFF, e.g. $FF00+$44
+//  a16: an address, e.g. $C000
+//  r8: a relative jump, shown as the absolute address it lands on
+//  s8: a signed offset added to SP, e.g. +2 or -2
+var ops = [256]string{
+	"NOP", "LD BC,d16", "LD (BC),A", "INC BC", "INC B", "DEC B", "LD B,d8", "RLCA",
+	"LD (a16),SP", "ADD HL,BC", "LD A,(BC)", "DEC BC", "INC C", "DEC C", "LD C,d8", "RRCA",
+	"STOP", "LD DE,d16", "LD (DE),A", "INC DE", "INC D", "DEC D", "LD D,d8", "RLA",
+	"JR r8", "ADD HL,DE", "LD A,(DE)", "DEC DE", "INC E", "DEC E", "LD E,d8", "RRA",
+	"JR NZ,r8", "LD HL,d16", "LD (HL+),A", "INC HL", "INC H", "DEC H", "LD H,d8", "DAA",
+	"JR Z,r8", "ADD HL,HL", "LD A,(HL+)", "DEC HL", "INC L", "DEC L", "LD L,d8", "CPL",
+	"JR NC,r8", "LD SP,d16", "LD (HL-),A", "INC SP", "INC (HL)", "DEC (HL)", "LD (HL),d8", "SCF",
+	"JR C,r8", "ADD HL,SP", "LD A,(HL-)", "DEC SP", "INC A", "DEC A", "LD A,d8", "CCF",
+	// 40-BF are filled in by init
+	0xC0: "RET NZ", "POP BC", "JP NZ,a16", "JP a16", "CALL NZ,a16", "PUSH BC", "ADD A,d8", "RST $00",
+	"RET Z", "RET", "JP Z,a16", "PREFIX CB", "CALL Z,a16", "CALL a16", "ADC A,d8", "RST $08",
+	"RET NC", "POP DE", "JP NC,a16", "", "CALL NC,a16", "PUSH DE", "SUB d8", "RST $10",
+	"RET C", "RETI", "JP C,a16", "", "CALL C,a16", "", "SBC A,d8", "RST $18",
+	"LDH (a8),A", "POP HL", "LD (C),A", "", "", "PUSH HL", "AND d8", "RST $20",
+	"ADD SP,s8", "JP HL", "LD (a16),A", "", "", "", "XOR d8", "RST $28",
+	"LDH A,(a8)", "POP AF", "LD A,(C)", "DI", "", "PUSH AF", "OR d8", "RST $30",
+	"LD HL,SPs8", "LD SP,HL", "LD A,(a16)", "EI", "", "", "CP d8", "RST $38",
+}
+
+// cbOps are the opcodes after a CB prefix
+var cbOps [256]string
+
+// registers in the order the opcodes encode them, in their low 3 bits
+var registers = [8]string{"B", "C", "D", "E", "H", "L", "(HL)", "A"}
+
+func init() {
+	for op := 0x40; op < 0x80; op++ {
+		ops[op] = "LD " + registers[op>>3&7] + "," + registers[op&7]
+	}
+	ops[0x76] = "HALT"
+	alu := [8]string{"ADD A,", "ADC A,", "SUB ", "SBC A,", "AND ", "XOR ", "OR ", "CP "}
+	for op := 0x80; op < 0xC0; op++ {
+		ops[op] = alu[op>>3&7] + registers[op&7]
+	}
+
+	shifts := [8]string{"RLC", "RRC", "RL", "RR", "SLA", "SRA", "SWAP", "SRL"}
+	for op := 0; op < 0x100; op++ {
+		reg := registers[op&7]
+		bit := op >> 3 & 7
+		switch op >> 6 {
+		case 0:
+			cbOps[op] = shifts[bit] + " " + reg
+		case 1:
+			cbOps[op] = fmt.Sprintf("BIT %d,%s", bit, reg)
+		case 2:
+			cbOps[op] = fmt.Sprintf("RES %d,%s", bit, reg)
+		case 3:
+			cbOps[op] = fmt.Sprintf("SET %d,%s", bit, reg)
+		}
+	}
+}
+
+// Instruction is one decoded instruction
+type Instruction struct {
+	Addr  uint16
+	Bytes []byte
+	// Text is the instruction with its operands filled in, e.g. JR NZ,$0150
+	Text string
+	// Target is where a jump, call or RST goes, if HasTarget is set.  JP HL has no known target
+	Target    uint16
+	HasTarget bool
+	// Call is set for calls and RSTs, which come back to the next instruction
+	Call bool
+	// Ends is set when execution never carries on to the next instruction: unconditional jumps,
+	// returns, and opcodes that don't exist, which lock up the CPU
+	Ends bool
+
+	template string
+	operand  uint16
+}
+
+func (i Instruction) String() string {
+	return i.Text
+}
+
+//Format fills in the operands like Text, but shows addresses by name where names returns one, e.g. CALL Main.draw.
+// names returns "" for addresses it doesn't know
+func (i Instruction) Format(names func(addr uint16) string) string {
+	name := func(addr uint16, plain string) string {
+		if names != nil {
+			if n := names(addr); n != "" {
+				return n
+			}
+		}
+		return plain
+	}
+	text := i.template
+	switch {
+	case strings.Contains(text, "d16"):
+		text = strings.Replace(text, "d16", fmt.Sprintf("$%04X", i.operand), 1)
+	case strings.Contains(text, "a16"):
+		text = strings.Replace(text, "a16", name(i.operand, fmt.Sprintf("$%04X", i.operand)), 1)
+	case strings.Contains(text, "d8"):
+		text = strings.Replace(text, "d8", fmt.Sprintf("$%02X", i.operand), 1)
+	case strings.Contains(text, "a8"):
+		text = strings.Replace(text, "a8", name(0xFF00|i.operand, fmt.Sprintf("$FF00+$%02X", i.operand)), 1)
+	case strings.Contains(text, "r8"):
+		text = strings.Replace(text, "r8", name(i.Target, fmt.Sprintf("$%04X", i.Target)), 1)
+	case strings.Contains(text, "s8"):
+		text = strings.Replace(text, "s8", fmt.Sprintf("%+d", int8(i.operand)), 1)
+	}
+	return text
+}
+
+//Decode decodes the instruction at the start of data, which is at addr in memory.
+// ok is false if data ends partway through the instruction
+func Decode(data []byte, addr uint16) (inst Instruction, ok bool) {
+	if len(data) == 0 {
+		return Instruction{}, false
+	}
+	op := data[0]
+	template := ops[op]
+	length := 1 + operandSize(template)
+	switch {
+	case op == 0xCB:
+		if len(data) < 2 {
+			return Instruction{}, false
+		}
+		template = cbOps[data[1]]
+		length = 2
+	case op == 0x10:
+		// STOP is followed by a byte that's skipped
+		length = 2
+	case template == "":
+		template = fmt.Sprintf("DB $%02X", op)
+	}
+	if len(data) < length {
+		return Instruction{}, false
+	}
+
+	var operand uint16
+	if length == 3 {
+		operand = uint16(data[1]) | uint16(data[2])<<8
+	} else if length == 2 {
+		operand = uint16(data[1])
+	}
+	inst = Instruction{Addr: addr, Bytes: data[:length:length], template: template, operand: operand}
+	if strings.Contains(template, "r8") {
+		inst.Target = addr + uint16(length) + uint16(int8(operand))
+	}
+	inst.Text = inst.Format(nil)
+
+	switch op {
+	case 0xC3, 0xC2, 0xCA, 0xD2, 0xDA, 0xCD, 0xC4, 0xCC, 0xD4, 0xDC:
+		inst.Target, inst.HasTarget = operand, true
+	case 0x18, 0x20, 0x28, 0x30, 0x38:
+		inst.HasTarget = true
+	}
+	switch {
+	case op&0xC7 == 0xC7:
+		// RST
+		inst.Target, inst.HasTarget = uint16(op&0x38), true
+		inst.Call = true
+	case op == 0xCD, op == 0xC4, op == 0xCC, op == 0xD4, op == 0xDC:
+		inst.Call = true
+	case op == 0xC3, op == 0x18, op == 0xE9, op == 0xC9, op == 0xD9, ops[op] == "":
+		inst.Ends = true
+	}
+	return inst, true
+}
+
+//operandSize returns how many bytes of operand follow the opcode
+func operandSize(template string) int {
+	switch {
+	case strings.Contains(template, "16"):
+		return 2
+	case strings.Contains(template, "8") && !strings.HasPrefix(template, "RST"):
+		return 1
+	}
+	return 0
+}
+
+//Range decodes the instructions in data, which starts at addr, one after the other.
+// an instruction cut off by the end of data is left out
+func Range(data []byte, addr uint16) []Instruction {
+	var out []Instruction
+	for len(data) > 0 {
+		inst, ok := Decode(data, addr)
+		if !ok {
+			break
+		}
+		out = append(out, inst)
+		data = data[len(inst.Bytes):]
+		addr += uint16(len(inst.Bytes))
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package disasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/raidancampbell/goby/symbols"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		data []byte
+		text string
+	}{
+		{[]byte{0x00}, "NOP"},
+		{[]byte{0x31, 0xFE, 0xFF}, "LD SP,$FFFE"},
+		{[]byte{0x3E, 0x12}, "LD A,$12"},
+		{[]byte{0xE0, 0x44}, "LDH ($FF00+$44),A"},
+		{[]byte{0xFA, 0x00, 0xC0}, "LD A,($C000)"},
+		{[]byte{0x20, 0xFB}, "JR NZ,$014D"},
+		{[]byte{0x18, 0x10}, "JR $0162"},
+		{[]byte{0xF8, 0xFE}, "LD HL,SP-2"},
+		{[]byte{0xE8, 0x02}, "ADD SP,+2"},
+		{[]byte{0x7E}, "LD A,(HL)"},
+		{[]byte{0x96}, "SUB (HL)"},
+		{[]byte{0xCB, 0x7C}, "BIT 7,H"},
+		{[]byte{0xCB, 0x37}, "SWAP A"},
+		{[]byte{0x10, 0x00}, "STOP"},
+		{[]byte{0xFF}, "RST $38"},
+		{[]byte{0xD3}, "DB $D3"},
+	}
+	for _, c := range cases {
+		inst, ok := Decode(c.data, 0x0150)
+		require.True(t, ok, c.text)
+		assert.Equal(t, c.text, inst.Text)
+		assert.Equal(t, c.data, inst.Bytes, c.text)
+	}
+
+	inst, _ := Decode([]byte{0xC4, 0x00, 0x40}, 0x0150)
+	assert.True(t, inst.Call && inst.HasTarget && !inst.Ends)
+	assert.Equal(t, uint16(0x4000), inst.Target)
+	inst, _ = Decode([]byte{0xC3, 0x50, 0x01}, 0x0100)
+	assert.True(t, inst.Ends && inst.HasTarget && !inst.Call)
+	inst, _ = Decode([]byte{0xE9}, 0x0100)
+	assert.True(t, inst.Ends && !inst.HasTarget)
+
+	_, ok := Decode([]byte{0xCD, 0x00}, 0)
+	assert.False(t, ok)
+	assert.Len(t, Range([]byte{0x00, 0x3E, 0x01, 0xCD, 0x00}, 0), 2)
+}
+
+func TestDisassemble(t *testing.T) {
+	rom := make([]byte, 4*bankSize)
+	for i := range rom {
+		rom[i] = 0xC9
+	}
+	copy(rom[0x0100:], []byte{0xC3, 0x50, 0x01}) // JP $0150
+	copy(rom[0x0150:], []byte{
+		0x3E, 0x02, // LD A,$02
+		0xEA, 0x00, 0x20, // LD ($2000),A
+		0xCD, 0x00, 0x40, // CALL $4000
+		0x18, 0xF6, // JR $0150
+	})
+	copy(rom[2*bankSize:], []byte{0x3C, 0xC9}) // INC A, RET
+
+	l := Disassemble(rom)
+	assert.Equal(t, "INC A", l.code[2*bankSize].Text)
+	assert.Equal(t, "RET", l.code[2*bankSize+1].Text)
+	// bank 1 was never selected, and the padding after the JP is never run
+	assert.NotContains(t, l.code, bankSize)
+	assert.NotContains(t, l.code, 0x0103)
+
+	var out bytes.Buffer
+	_, err := l.WriteTo(&out)
+	require.NoError(t, err)
+	listing := out.String()
+	assert.Contains(t, listing, "00:0150  3e 02     LD A,$02\n")
+	assert.Contains(t, listing, "00:0158  18 f6     JR $0150\n00:015A            DB $C9,$C9")
+	assert.Contains(t, listing, "\n; bank 2\n02:4000  3c        INC A\n02:4001  c9        RET\n02:4002")
+	assert.Equal(t, 4, strings.Count(listing, "; bank"))
+
+	l.Symbols, err = symbols.Parse(strings.NewReader("00:0150 Main\n01:4000 Near\n02:4000 Far\n"))
+	require.NoError(t, err)
+	out.Reset()
+	_, err = l.WriteTo(&out)
+	require.NoError(t, err)
+	listing = out.String()
+	assert.Contains(t, listing, "Main:\n00:0150  3e 02     LD A,$02\n")
+	// bank 2 was switched in before the call
+	assert.Contains(t, listing, "00:0155  cd 00 40  CALL Far\n00:0158  18 f6     JR Main\n")
+	assert.Contains(t, listing, "\n; bank 1\nNear:\n01:4000            DB")
+}
@@ -0,0 +1,234 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/raidancampbell/goby/symbols"
+)
+
+const bankSize = 0x4000
+
+// entryPoints are where execution can start without being jumped to: the RSTs, the interrupts, and the cartridge entry
+var entryPoints = []uint16{0x00, 0x08, 0x10, 0x18, 0x20, 0x28, 0x30, 0x38, 0x40, 0x48, 0x50, 0x58, 0x60, 0x100}
+
+// Listing is a whole ROM, disassembled by following the code from its entry points.  Whatever isn't reached
+// is taken to be data
+type Listing struct {
+	// Symbols, if set, label the listing and name the addresses instructions refer to
+	Symbols *symbols.Table
+
+	rom    []byte
+	code   map[int]Instruction // by ROM offset
+	mapped map[int]int         // by ROM offset: the bank at 4000-7FFF when the instruction there ran
+}
+
+// path is somewhere execution reaches, and what's known on the way there
+type path struct {
+	bank int // the bank the code is in
+	addr uint16
+	// mapped is the bank switched in at 4000-7FFF, for following jumps out of bank 0
+	mapped int
+}
+
+//offset returns where in the ROM the path is
+func (p path) offset() int {
+	return p.bank*bankSize + int(p.addr)%bankSize
+}
+
+//Disassemble follows every path through the ROM from its entry points.  Jumps and calls within 4000-7FFF
+// stay in the current bank, and ones into 0000-3FFF go to bank 0.  From bank 0, the bank at 4000-7FFF is
+// whichever one the code selected last, found by spotting the usual LD A,n then LD ($2000),A, or bank 1 by default
+func Disassemble(rom []byte) *Listing {
+	l := &Listing{rom: rom, code: map[int]Instruction{}, mapped: map[int]int{}}
+	banks := len(rom) / bankSize
+	if banks == 0 {
+		return l
+	}
+
+	var queue []path
+	for _, addr := range entryPoints {
+		if int(addr) < len(rom) {
+			queue = append(queue, path{addr: addr, mapped: 1 % banks})
+		}
+	}
+	seen := map[path]bool{}
+	for len(queue) > 0 {
+		p := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		a := -1 // A, if it's known
+		for !seen[p] {
+			seen[p] = true
+			start, end := p.offset(), p.bank*bankSize+bankSize
+			if end > len(l.rom) {
+				end = len(l.rom)
+			}
+			inst, ok := Decode(l.rom[start:end], p.addr)
+			if !ok {
+				break
+			}
+			l.code[start] = inst
+			l.mapped[start] = p.mapped
+
+			switch op := inst.Bytes[0]; {
+			case op == 0x3E:
+				a = int(inst.Bytes[1])
+			case op == 0xEA && p.bank == 0 && a >= 0 && operand16(inst) >= 0x2000 && operand16(inst) < 0x4000:
+				p.mapped = a % banks
+				if p.mapped == 0 {
+					p.mapped = 1 % banks
+				}
+			default:
+				a = -1
+			}
+
+			if inst.HasTarget {
+				if target, ok := p.jump(inst.Target); ok {
+					queue = append(queue, target)
+				}
+			}
+			if inst.Ends {
+				break
+			}
+			next := inst.Addr + uint16(len(inst.Bytes))
+			if next == 0x4000 || next == 0x8000 || next < inst.Addr {
+				// running off the end of a bank
+				break
+			}
+			p.addr = next
+		}
+	}
+	return l
+}
+
+//jump returns the path to the given address, from this one.  ok is false for addresses outside ROM:
+// code copied to RAM can't be followed
+func (p path) jump(addr uint16) (target path, ok bool) {
+	switch {
+	case addr < bankSize:
+		return path{bank: 0, addr: addr, mapped: p.mapped}, true
+	case addr < 2*bankSize && p.bank == 0:
+		return path{bank: p.mapped, addr: addr, mapped: p.mapped}, true
+	case addr < 2*bankSize:
+		return path{bank: p.bank, addr: addr, mapped: p.bank}, true
+	}
+	return path{}, false
+}
+
+func operand16(inst Instruction) uint16 {
+	return uint16(inst.Bytes[1]) | uint16(inst.Bytes[2])<<8
+}
+
+//Coverage returns how many bytes of the ROM were found to be code
+func (l *Listing) Coverage() int {
+	n := 0
+	for _, inst := range l.code {
+		n += len(inst.Bytes)
+	}
+	return n
+}
+
+//WriteTo writes the listing, one instruction to a line, e.g. 00:0150  c3 50 01  JP $0150.
+// data is written 8 bytes to a line, as DB.  Labels from the symbols get a line of their own
+func (l *Listing) WriteTo(w io.Writer) (int64, error) {
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	var data []byte
+	dataStart := 0
+	flush := func() {
+		for len(data) > 0 {
+			n := 8
+			if n > len(data) {
+				n = len(data)
+			}
+			var values []string
+			for _, b := range data[:n] {
+				values = append(values, fmt.Sprintf("$%02X", b))
+			}
+			fmt.Fprintf(out, "%s  %-8s  DB %s\n", location(dataStart), "", strings.Join(values, ","))
+			data = data[n:]
+			dataStart += n
+		}
+	}
+	for offset := 0; offset < len(l.rom); {
+		if offset%bankSize == 0 {
+			flush()
+			fmt.Fprintf(out, "\n; bank %d\n", offset/bankSize)
+		}
+		if label := l.Symbols.At(offset/bankSize, romAddr(offset)); label != "" {
+			flush()
+			fmt.Fprintf(out, "%s:\n", label)
+		}
+		inst, ok := l.code[offset]
+		if !ok {
+			if len(data) == 0 {
+				dataStart = offset
+			}
+			data = append(data, l.rom[offset])
+			if len(data) == 8 {
+				flush()
+			}
+			offset++
+			continue
+		}
+		flush()
+		var raw []string
+		for _, b := range inst.Bytes {
+			raw = append(raw, fmt.Sprintf("%02x", b))
+		}
+		fmt.Fprintf(out, "%s  %-8s  %s\n", location(offset), strings.Join(raw, " "), inst.Format(l.names(offset)))
+		offset += len(inst.Bytes)
+	}
+	flush()
+	if out.err == nil {
+		out.err = out.w.Flush()
+	}
+	return out.n, out.err
+}
+
+//names looks up the addresses used by the instruction at offset in the symbols
+func (l *Listing) names(offset int) func(addr uint16) string {
+	return func(addr uint16) string {
+		bank := 0
+		if addr >= bankSize && addr < 2*bankSize {
+			// the current bank, or from bank 0, whichever was switched in
+			bank = offset / bankSize
+			if bank == 0 {
+				bank = l.mapped[offset]
+			}
+		}
+		return l.Symbols.Describe(bank, addr)
+	}
+}
+
+//romAddr returns where a ROM offset appears in memory: bank 0 at 0000-3FFF, and the rest at 4000-7FFF
+func romAddr(offset int) uint16 {
+	addr := uint16(offset % bankSize)
+	if offset >= bankSize {
+		addr += bankSize
+	}
+	return addr
+}
+
+//location formats a ROM offset as bank:address
+func location(offset int) string {
+	return fmt.Sprintf("%02X:%04X", offset/bankSize, romAddr(offset))
+}
+
+// countingWriter keeps the first error and how much was written, for WriteTo
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
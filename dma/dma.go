@@ -0,0 +1,176 @@
+package dma
+
+import (
+	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/state"
+)
+
+const (
+	RegDMA   = 0xFF46 // OAM DMA source, high byte
+	RegHDMA1 = 0xFF51 // VRAM DMA source, high byte
+	RegHDMA2 = 0xFF52 // VRAM DMA source, low byte
+	RegHDMA3 = 0xFF53 // VRAM DMA destination, high byte
+	RegHDMA4 = 0xFF54 // VRAM DMA destination, low byte
+	RegHDMA5 = 0xFF55 // VRAM DMA length, mode and start
+
+	oamStart  = 0xFE00
+	oamLength = 0xA0
+
+	hdmaHBlank = 0x80 // HDMA5 bit 7: transfer 16 bytes per HBlank rather than all at once
+	blockSize  = 0x10
+	// blockCycles is how long the CPU is stalled per 16 byte block, in 4MHz cycles.
+	// it takes the same real time in double speed mode, so that's twice as many CPU cycles
+	blockCycles = 32
+)
+
+// Controller runs OAM DMA, and on CGB, VRAM DMA.  It's clocked by the CPU, so in double speed mode
+// OAM DMA completes twice as fast.  VRAM DMA copies in blocks of 16 bytes, either all at once (general purpose),
+// or one block at the start of each HBlank.  The CPU doesn't run while a block is copied
+type Controller struct {
+	ram *mem.RAM
+	cgb bool
+	// DoubleSpeed reports whether the CPU runs at double speed. it may be nil on DMG
+	DoubleSpeed func() bool
+
+	oamSource    uint16
+	oamRemaining int // bytes left to copy to OAM
+	oamCycles    int // cycles towards the next OAM byte
+	dmaReg       byte
+
+	hdmaSource uint16
+	hdmaDest   uint16
+	hdmaBlocks int  // blocks left to copy
+	hdmaActive bool // an HBlank paced transfer is in progress
+	stall      int  // CPU cycles the CPU must sit out
+}
+
+//New creates a DMA controller and maps it into memory at FF46.  In CGB mode, FF51-FF55 are mapped too
+func New(ram *mem.RAM, cgb bool) *Controller {
+	d := &Controller{ram: ram, cgb: cgb}
+	ram.Map(RegDMA, RegDMA, d)
+	if cgb {
+		ram.Map(RegHDMA1, RegHDMA5, d)
+	}
+	return d
+}
+
+//Stall returns how many CPU cycles the CPU must sit out for VRAM DMA, and resets it
+func (d *Controller) Stall() int {
+	s := d.stall
+	d.stall = 0
+	return s
+}
+
+//Stalling returns whether the CPU has VRAM DMA cycles to sit out
+func (d *Controller) Stalling() bool {
+	return d.stall > 0
+}
+
+//Step advances OAM DMA by the given number of CPU cycles.  One byte is copied every 4 cycles
+func (d *Controller) Step(cycles int) {
+	if d.oamRemaining == 0 {
+		return
+	}
+	d.oamCycles += cycles
+	for d.oamCycles >= 4 && d.oamRemaining > 0 {
+		d.oamCycles -= 4
+		i := uint16(oamLength - d.oamRemaining)
+		d.ram.Poke(oamStart+i, d.ram.Peek(d.oamSource+i))
+		d.oamRemaining--
+	}
+}
+
+//HBlank is called by the PPU at the start of each visible line's HBlank, and copies one block of an HBlank DMA
+func (d *Controller) HBlank() {
+	if !d.hdmaActive {
+		return
+	}
+	d.copyBlock()
+	if d.hdmaBlocks == 0 {
+		d.hdmaActive = false
+	}
+}
+
+func (d *Controller) copyBlock() {
+	for i := uint16(0); i < blockSize; i++ {
+		d.ram.Poke(0x8000|(d.hdmaDest+i)&0x1FFF, d.ram.Peek(d.hdmaSource+i))
+	}
+	d.hdmaSource += blockSize
+	d.hdmaDest += blockSize
+	d.hdmaBlocks--
+
+	stall := blockCycles
+	if d.DoubleSpeed != nil && d.DoubleSpeed() {
+		stall *= 2
+	}
+	d.stall += stall
+}
+
+func (d *Controller) Read(addr uint16) byte {
+	switch addr {
+	case RegDMA:
+		return d.dmaReg
+	case RegHDMA5:
+		// the blocks remaining, minus one. bit 7 is clear while an HBlank transfer is still running.
+		// once finished this reads FF, and after a cancellation it's the blocks that were left
+		remaining := byte(d.hdmaBlocks-1) & 0x7F
+		if !d.hdmaActive {
+			return 0x80 | remaining
+		}
+		return remaining
+	default:
+		// the source and destination registers are write-only
+		return 0xFF
+	}
+}
+
+func (d *Controller) Write(addr uint16, val byte) {
+	switch addr {
+	case RegDMA:
+		d.dmaReg = val
+		d.oamSource = uint16(val) << 8
+		d.oamRemaining = oamLength
+		d.oamCycles = 0
+	case RegHDMA1:
+		d.hdmaSource = uint16(val)<<8 | d.hdmaSource&0xFF
+	case RegHDMA2:
+		// the low nibble is ignored: transfers are 16 byte aligned
+		d.hdmaSource = d.hdmaSource&0xFF00 | uint16(val&0xF0)
+	case RegHDMA3:
+		// the destination is always in VRAM
+		d.hdmaDest = uint16(val&0x1F)<<8 | d.hdmaDest&0xFF
+	case RegHDMA4:
+		d.hdmaDest = d.hdmaDest&0x1F00 | uint16(val&0xF0)
+	case RegHDMA5:
+		d.startVRAMTransfer(val)
+	}
+}
+
+func (d *Controller) startVRAMTransfer(val byte) {
+	if d.hdmaActive && val&hdmaHBlank == 0 {
+		// writing with bit 7 clear during an HBlank transfer cancels it
+		d.hdmaActive = false
+		return
+	}
+	d.hdmaBlocks = int(val&0x7F) + 1
+	if val&hdmaHBlank != 0 {
+		d.hdmaActive = true
+		return
+	}
+	// general purpose: everything at once, with the CPU stalled for the whole transfer
+	for d.hdmaBlocks > 0 {
+		d.copyBlock()
+	}
+}
+
+func (d *Controller) Serialize(s *state.Serializer) {
+	s.Uint16(&d.oamSource)
+	s.Int(&d.oamRemaining)
+	s.Int(&d.oamCycles)
+	s.Uint8(&d.dmaReg)
+	s.Uint16(&d.hdmaSource)
+	s.Uint16(&d.hdmaDest)
+	s.Int(&d.hdmaBlocks)
+	s.Bool(&d.hdmaActive)
+	s.Int(&d.stall)
+}
@@ -0,0 +1,67 @@
+package dma
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+type vram [0x2000]byte
+
+func (v *vram) Read(addr uint16) byte       { return v[addr-0x8000] }
+func (v *vram) Write(addr uint16, val byte) { v[addr-0x8000] = val }
+
+func setup(t *testing.T) (*mem.RAM, *vram, *Controller) {
+	ram := &mem.RAM{}
+	v := &vram{}
+	ram.Map(0x8000, 0x9FFF, v)
+	for i := uint16(0); i < 0x100; i++ {
+		ram.WriteByte(0xC000+i, byte(i))
+	}
+	d := New(ram, true)
+	ram.WriteByte(RegHDMA1, 0xC0)
+	ram.WriteByte(RegHDMA2, 0x00)
+	ram.WriteByte(RegHDMA3, 0x01)
+	ram.WriteByte(RegHDMA4, 0x00)
+	return ram, v, d
+}
+
+func TestOAMDMA(t *testing.T) {
+	ram, _, d := setup(t)
+	ram.WriteByte(RegDMA, 0xC0)
+	d.Step(4 * 10)
+	assert.Equal(t, byte(9), ram.ReadByte(oamStart+9))
+	assert.Equal(t, byte(0), ram.ReadByte(oamStart+10))
+	d.Step(4 * oamLength)
+	assert.Equal(t, byte(0x9F), ram.ReadByte(oamStart+0x9F))
+}
+
+func TestGeneralPurposeDMA(t *testing.T) {
+	ram, v, d := setup(t)
+	ram.WriteByte(RegHDMA5, 0x01) // two blocks
+	assert.Equal(t, byte(0x1F), v[0x11F])
+	assert.Equal(t, byte(0), v[0x120])
+	assert.Equal(t, 2*blockCycles, d.Stall())
+	assert.Equal(t, 0, d.Stall())
+	assert.Equal(t, byte(0xFF), ram.ReadByte(RegHDMA5))
+}
+
+func TestHBlankDMA(t *testing.T) {
+	ram, v, d := setup(t)
+	d.DoubleSpeed = func() bool { return true }
+	ram.WriteByte(RegHDMA5, 0x82) // three blocks, one per HBlank
+	assert.Equal(t, byte(0x02), ram.ReadByte(RegHDMA5))
+
+	d.HBlank()
+	assert.Equal(t, byte(0x0F), v[0x10F])
+	assert.Equal(t, byte(0), v[0x110])
+	assert.Equal(t, 2*blockCycles, d.Stall())
+	assert.Equal(t, byte(0x01), ram.ReadByte(RegHDMA5))
+
+	// cancelling leaves the remaining length readable, with bit 7 set
+	ram.WriteByte(RegHDMA5, 0x00)
+	assert.Equal(t, byte(0x81), ram.ReadByte(RegHDMA5))
+	d.HBlank()
+	assert.Equal(t, byte(0), v[0x110])
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/raidancampbell/goby/joypad"
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/sched"
+)
+
+// frontend is whatever the emulator is presented through, e.g. an SDL window
+type frontend struct {
+	display render.Display
+	audio   sched.AudioQueue
+	inputs  []joypad.Source
+	mode    sched.Mode
+	// pollEvents services the frontend, and returns false once it has been closed
+	pollEvents func() bool
+	// fastForward reports whether the fast-forward key is held
+	fastForward func() bool
+	// rewind reports whether the rewind key is held
+	rewind func() bool
+	// stateRequests returns the save state hotkeys pressed since it was last called
+	stateRequests func() []stateRequest
+	// debugBreak reports whether the debugger hotkey was pressed since it was last called
+	debugBreak func() bool
+	// screenshot reports whether the screenshot hotkey was pressed since it was last called
+	screenshot func() bool
+}
+
+// frontendOptions are how the frontend is set up, from the command line and settings file
+type frontendOptions struct {
+	scale      int
+	sampleRate int
+	volume     float64
+	sync       sched.Mode
+	// keys and gamepad bind Game Boy buttons by name to SDL key and controller button names
+	keys, gamepad map[string]string
+}
+
+// stateRequest asks for the machine to be saved to, or loaded from, a numbered slot
+type stateRequest struct {
+	slot int
+	save bool
+}
+
+//headlessFrontend shows nothing and reads no input.  Frames are kept in memory at their native size, there is no window
+// to close, and with nothing to watch or listen to, emulation runs unthrottled
+func headlessFrontend() frontend {
+	return frontend{
+		display:       &render.Headless{},
+		mode:          sched.Unlimited,
+		pollEvents:    func() bool { return true },
+		fastForward:   func() bool { return false },
+		rewind:        func() bool { return false },
+		stateRequests: func() []stateRequest { return nil },
+		debugBreak:    func() bool { return false },
+		screenshot:    func() bool { return false },
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !sdl
+// +build !sdl
+
+package main
+
+//openFrontend is used when built without SDL, which leaves nothing to open a window with
+func openFrontend(opts frontendOptions) (frontend, error) {
+	return headlessFrontend(), nil
+}
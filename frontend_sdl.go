@@ -0,0 +1,127 @@
+//go:build sdl
+// +build sdl
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/raidancampbell/goby/apu"
+	"github.com/raidancampbell/goby/joypad"
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/sched"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// fastForwardKey runs the emulator faster than real time while held
+const fastForwardKey = sdl.SCANCODE_TAB
+
+// rewindKey steps backwards a frame at a time while held
+const rewindKey = sdl.SCANCODE_R
+
+// debugKey pauses emulation and opens the debugger in the terminal
+const debugKey = sdl.K_F12
+
+// screenshotKey saves what's on screen as a PNG
+const screenshotKey = sdl.K_F10
+
+// slotKeys load save state slots 1-9, or save them with shift held
+var slotKeys = map[sdl.Keycode]int{
+	sdl.K_F1: 1, sdl.K_F2: 2, sdl.K_F3: 3, sdl.K_F4: 4, sdl.K_F5: 5,
+	sdl.K_F6: 6, sdl.K_F7: 7, sdl.K_F8: 8, sdl.K_F9: 9,
+}
+
+//openFrontend creates the SDL window, with keyboard and gamepad input and audio output.
+// vsync is only turned on when emulation is paced against it, to avoid pacing against two clocks
+func openFrontend(opts frontendOptions) (frontend, error) {
+	keyboard, gamepads, err := bindings(opts)
+	if err != nil {
+		return frontend{}, err
+	}
+	mode := opts.sync
+	lcd := &render.LCD{}
+	if err := lcd.Init(render.LCDOptions{Scale: opts.scale, VSync: mode == sched.SyncVideo}); err != nil {
+		return frontend{}, err
+	}
+	audio, err := apu.OpenSDL(opts.sampleRate)
+	if err != nil {
+		lcd.Close()
+		return frontend{}, err
+	}
+	audio.Volume = opts.volume
+	lcd.Subscribe(gamepads.HandleEvent)
+	var requests []stateRequest
+	debugPressed, screenshotPressed := false, false
+	lcd.Subscribe(func(event sdl.Event) {
+		e, ok := event.(*sdl.KeyboardEvent)
+		if !ok || e.Type != sdl.KEYDOWN || e.Repeat != 0 {
+			return
+		}
+		if slot, ok := slotKeys[e.Keysym.Sym]; ok {
+			requests = append(requests, stateRequest{slot: slot, save: e.Keysym.Mod&sdl.KMOD_SHIFT != 0})
+		}
+		switch e.Keysym.Sym {
+		case debugKey:
+			debugPressed = true
+		case screenshotKey:
+			screenshotPressed = true
+		}
+	})
+	return frontend{
+		display:    lcd,
+		audio:      audio,
+		inputs:     []joypad.Source{keyboard, gamepads},
+		mode:       mode,
+		pollEvents: lcd.PollEvents,
+		fastForward: func() bool {
+			return sdl.GetKeyboardState()[fastForwardKey] != 0
+		},
+		rewind: func() bool {
+			return sdl.GetKeyboardState()[rewindKey] != 0
+		},
+		stateRequests: func() []stateRequest {
+			r := requests
+			requests = nil
+			return r
+		},
+		debugBreak: func() bool {
+			pressed := debugPressed
+			debugPressed = false
+			return pressed
+		},
+		screenshot: func() bool {
+			pressed := screenshotPressed
+			screenshotPressed = false
+			return pressed
+		},
+	}, nil
+}
+
+//bindings creates the keyboard and gamepads, with the default bindings changed as the options say
+func bindings(opts frontendOptions) (*joypad.Keyboard, *joypad.Gamepads, error) {
+	keyboard := joypad.NewKeyboard()
+	for name, key := range opts.keys {
+		b, err := joypad.ParseButton(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		sc := sdl.GetScancodeFromName(key)
+		if sc == sdl.SCANCODE_UNKNOWN {
+			return nil, nil, fmt.Errorf("unknown key %q for %s", key, name)
+		}
+		keyboard.Bind(sc, b)
+	}
+	gamepads := joypad.NewGamepads()
+	for name, button := range opts.gamepad {
+		b, err := joypad.ParseButton(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		btn := sdl.GameControllerGetButtonFromString(button)
+		if btn == sdl.CONTROLLER_BUTTON_INVALID {
+			return nil, nil, fmt.Errorf("unknown controller button %q for %s", button, name)
+		}
+		gamepads.Bind(btn, b)
+	}
+	return keyboard, gamepads, nil
+}
@@ -0,0 +1,98 @@
+package gameboy
+
+import (
+	"crypto/md5"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/cpu"
+)
+
+// BootROM is a boot ROM, and the hardware it's for
+type BootROM struct {
+	// Name is the hardware revision, e.g. DMG0 or SGB2
+	Name  string
+	Model Model // ModelAuto for a DMG-sized boot ROM that could be for the DMG, MGB or SGB
+	Size  int
+	// Known is set for Nintendo's boot ROMs, recognized by their hash.  Others, e.g. SameBoy's, are told apart by
+	// their file name and size
+	Known bool
+}
+
+// knownBootROMs are Nintendo's boot ROMs, by MD5.  The CGB0 and AGB ones are told apart by their file name instead
+var knownBootROMs = map[string]BootROM{
+	"a8f84a0ac44da5d3f0ee19f9cea80a8c": {Name: "DMG0", Model: ModelDMG, Size: cpu.DMGBootROMSize},
+	"32fbbd84168d3482956eb3c5051637f5": {Name: "DMG", Model: ModelDMG, Size: cpu.DMGBootROMSize},
+	"71a378e71ff30b2d8a1f02bf5c7896aa": {Name: "MGB", Model: ModelMGB, Size: cpu.DMGBootROMSize},
+	"d574d4f9c12f305074798f54c091a8b4": {Name: "SGB", Model: ModelSGB, Size: cpu.DMGBootROMSize},
+	"e0430bca9925fb9882148fd2dc2418c1": {Name: "SGB2", Model: ModelSGB, Size: cpu.DMGBootROMSize},
+	"dbfce9db9deaa2567f6a84fde55f9680": {Name: "CGB", Model: ModelCGB, Size: cpu.CGBBootROMSize},
+}
+
+// bootROMPrefixes pick the hardware of boot ROMs that aren't known from their file name, e.g. sgb2_boot.bin, as
+// SameBoy and most other emulators name them.  Longer prefixes come first, so sgb2 isn't taken for sgb
+var bootROMPrefixes = []struct {
+	prefix string
+	model  Model
+}{
+	{"dmg0", ModelDMG}, {"dmg", ModelDMG}, {"mgb", ModelMGB}, {"sgb2", ModelSGB}, {"sgb", ModelSGB},
+	{"cgb0", ModelCGB}, {"cgb", ModelCGB}, {"agb", ModelAGB},
+}
+
+//IdentifyBootROM works out which boot ROM b, read from path, is.  Nintendo's are known by their hash.  Any other
+// boot ROM is accepted if it's the right size, with the hardware taken from its file name if that's a known prefix
+func IdentifyBootROM(path string, b []byte) (BootROM, error) {
+	if known, ok := knownBootROMs[fmt.Sprintf("%x", md5.Sum(b))]; ok {
+		known.Known = true
+		return known, nil
+	}
+	boot := BootROM{Name: "unknown", Size: len(b)}
+	switch len(b) {
+	case cpu.DMGBootROMSize:
+		boot.Model = ModelAuto
+	case cpu.CGBBootROMSize:
+		boot.Model = ModelCGB
+	default:
+		return BootROM{}, fmt.Errorf("boot ROMs are %d or %d bytes, got %d", cpu.DMGBootROMSize, cpu.CGBBootROMSize, len(b))
+	}
+	name := strings.ToLower(filepath.Base(path))
+	for _, p := range bootROMPrefixes {
+		if !strings.HasPrefix(name, p.prefix) || bootROMSize(p.model) != len(b) {
+			continue
+		}
+		boot.Name, boot.Model = strings.ToUpper(p.prefix), p.model
+		break
+	}
+	return boot, nil
+}
+
+//bootROMSize returns how big the boot ROM of a model is
+func bootROMSize(model Model) int {
+	if model.isCGB() {
+		return cpu.CGBBootROMSize
+	}
+	return cpu.DMGBootROMSize
+}
+
+//ModelFor returns the model to run a cartridge on with this boot ROM.  If the model asked for is auto, it's the boot
+// ROM's, or for a DMG-sized boot ROM of unknown hardware, whatever the cartridge would pick short of the CGB.
+// it fails if the boot ROM is for other hardware than the model asked for
+func (b BootROM) ModelFor(model Model, cart *cartridge.ROM) (Model, error) {
+	if model == ModelAuto {
+		model = b.Model
+	}
+	if model == ModelAuto {
+		if model = PickModel(cart); model.isCGB() {
+			model = ModelDMG
+		}
+	}
+	if b.Model != ModelAuto && b.Model != model {
+		return model, fmt.Errorf("the %s boot ROM is for the %s, not the %s", b.Name, b.Model, model)
+	}
+	if bootROMSize(model) != b.Size {
+		return model, fmt.Errorf("the %s's boot ROM is %d bytes, got %d", model, bootROMSize(model), b.Size)
+	}
+	return model, nil
+}
@@ -0,0 +1,90 @@
+package gameboy
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/cpu"
+	"github.com/raidancampbell/goby/internal/testrom"
+	"github.com/raidancampbell/goby/render"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentifyBootROM(t *testing.T) {
+	dmg := make([]byte, cpu.DMGBootROMSize)
+	cgb := make([]byte, cpu.CGBBootROMSize)
+	for _, tc := range []struct {
+		path  string
+		b     []byte
+		name  string
+		model Model
+	}{
+		{"boot.bin", dmg, "unknown", ModelAuto},
+		{"boot.bin", cgb, "unknown", ModelCGB},
+		{"roms/sgb2_boot.bin", dmg, "SGB2", ModelSGB},
+		{"MGB_boot.bin", dmg, "MGB", ModelMGB},
+		{"agb_boot.bin", cgb, "AGB", ModelAGB},
+		// the name is ignored if the size is wrong for it
+		{"cgb_boot.bin", dmg, "unknown", ModelAuto},
+	} {
+		boot, err := IdentifyBootROM(tc.path, tc.b)
+		require.NoError(t, err, tc.path)
+		assert.Equal(t, tc.name, boot.Name, tc.path)
+		assert.Equal(t, tc.model, boot.Model, tc.path)
+		assert.False(t, boot.Known, tc.path)
+	}
+
+	_, err := IdentifyBootROM("dmg_boot.bin", make([]byte, 300))
+	assert.Error(t, err)
+}
+
+func TestBootROM_ModelFor(t *testing.T) {
+	cart := testrom.New()
+	dmg := BootROM{Name: "DMG", Model: ModelDMG, Size: cpu.DMGBootROMSize}
+	unknown := BootROM{Name: "unknown", Model: ModelAuto, Size: cpu.DMGBootROMSize}
+
+	model, err := dmg.ModelFor(ModelAuto, cart)
+	require.NoError(t, err)
+	assert.Equal(t, ModelDMG, model)
+	_, err = dmg.ModelFor(ModelMGB, cart)
+	assert.EqualError(t, err, "the DMG boot ROM is for the DMG, not the MGB")
+
+	model, err = unknown.ModelFor(ModelMGB, cart)
+	require.NoError(t, err)
+	assert.Equal(t, ModelMGB, model)
+	_, err = unknown.ModelFor(ModelCGB, cart)
+	assert.EqualError(t, err, "the CGB's boot ROM is 2304 bytes, got 256")
+
+	// a CGB cartridge runs on the DMG when the boot ROM can't be a CGB one
+	(*cart)[0x0143] = 0x80
+	model, err = unknown.ModelFor(ModelAuto, cart)
+	require.NoError(t, err)
+	assert.Equal(t, ModelDMG, model)
+}
+
+func TestLoadBootrom_cgbOverlay(t *testing.T) {
+	boot := make([]byte, cpu.CGBBootROMSize)
+	for i := range boot {
+		boot[i] = 0xAA
+	}
+	gb := New(testrom.New(), ModelCGB, &render.Headless{})
+	require.NoError(t, gb.LoadBootROM(boot))
+	assert.Equal(t, uint16(0x0000), gb.CPU.Regs().PC)
+
+	assert.Equal(t, byte(0xAA), gb.RAM.Peek(0x0000))
+	assert.Equal(t, byte(0x76), gb.RAM.Peek(0x0150), "the cartridge header shows through")
+	assert.Equal(t, byte(0xAA), gb.RAM.Peek(0x0200))
+	assert.Equal(t, byte(0xAA), gb.RAM.Peek(0x08FF))
+	assert.Equal(t, byte(0x76), gb.RAM.Peek(0x0900))
+
+	gb.RAM.WriteByte(0xFF50, 0x11)
+	assert.Equal(t, byte(0x76), gb.RAM.Peek(0x0000))
+	assert.Equal(t, byte(0x76), gb.RAM.Peek(0x0200))
+}
+
+func TestCreate_agb(t *testing.T) {
+	gb := New(testrom.New(), ModelAGB, &render.Headless{})
+	assert.Equal(t, byte(0x01), gb.CPU.Regs().B, "bit 0 of B tells games it's a GBA")
+	gb = New(testrom.New(), ModelCGB, &render.Headless{})
+	assert.Equal(t, byte(0x00), gb.CPU.Regs().B)
+}
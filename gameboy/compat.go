@@ -0,0 +1,141 @@
+package gameboy
+
+import (
+	"fmt"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/joypad"
+	"github.com/raidancampbell/goby/render"
+)
+
+// manualPalettes are chosen by holding a direction, optionally with A or B, as the game boots
+var manualPalettes = map[joypad.Button]render.CompatPalette{
+	joypad.Up:               render.PaletteBrown,
+	joypad.Up | joypad.A:    render.PaletteRed,
+	joypad.Up | joypad.B:    render.PaletteDarkBrown,
+	joypad.Down:             render.PalettePastel,
+	joypad.Down | joypad.A:  render.PaletteOrange,
+	joypad.Down | joypad.B:  render.PaletteYellow,
+	joypad.Left:             render.PaletteBlue,
+	joypad.Left | joypad.A:  render.PaletteDarkBlue,
+	joypad.Left | joypad.B:  render.PaletteGray,
+	joypad.Right:            render.PaletteGreen,
+	joypad.Right | joypad.A: render.PaletteDarkGreen,
+	joypad.Right | joypad.B: render.PaletteInverted,
+}
+
+// defaultPalette is used for games the boot ROM doesn't recognize. it's the same as Right+A
+var defaultPalette = render.PaletteDarkGreen
+
+// the CGB boot ROM's colorization tables.  Nintendo's games are looked up by title checksum in titleChecksums.
+// the first 65 checksums are unique.  The rest are shared by more than one game, so the fourth letter of the title,
+// from titleLetters, has to match too.  Each entry picks one of compatCombos, the colors for OBJ0, OBJ1 and BG,
+// given as offsets into compatColors.  A few offsets aren't on a palette boundary, as in the boot ROM
+const uniqueChecksums = 65
+
+var titleChecksums = []byte{
+	0x00, 0x88, 0x16, 0x36, 0xD1, 0xDB, 0xF2, 0x3C, 0x8C, 0x92, 0x3D, 0x5C, 0x58, 0xC9, 0x3E, 0x70,
+	0x1D, 0x59, 0x69, 0x19, 0x35, 0xA8, 0x14, 0xAA, 0x75, 0x95, 0x99, 0x34, 0x6F, 0x15, 0xFF, 0x97,
+	0x4B, 0x90, 0x17, 0x10, 0x39, 0xF7, 0xF6, 0xA2, 0x49, 0x4E, 0x43, 0x68, 0xE0, 0x8B, 0xF0, 0xCE,
+	0x0C, 0x29, 0xE8, 0xB7, 0x86, 0x9A, 0x52, 0x01, 0x9D, 0x71, 0x9C, 0xBD, 0x5D, 0x6D, 0x67, 0x3F,
+	0x6B,
+	// shared checksums, told apart by titleLetters
+	0xB3, 0x46, 0x28, 0xA5, 0xC6, 0xD3, 0x27, 0x61, 0x18, 0x66, 0x6A, 0xBF, 0x0D, 0xF4,
+	0xB3, 0x46, 0x28, 0xA5, 0xC6, 0xD3, 0x27, 0x61, 0x18, 0x66, 0x6A, 0xBF, 0x0D, 0xF4,
+	0xB3,
+}
+
+var titleLetters = "BEFAARBEKEK R-URAR INAILICE R"
+
+// titleCombos is the entry in compatCombos for each title checksum
+var titleCombos = []byte{
+	0, 4, 5, 35, 34, 3, 31, 15, 10, 5, 19, 36, 7, 37, 30, 44,
+	21, 32, 31, 20, 5, 33, 13, 14, 5, 29, 5, 18, 9, 3, 2, 26,
+	25, 25, 41, 42, 26, 45, 42, 45, 36, 38, 26, 42, 30, 41, 34, 34,
+	5, 42, 6, 5, 33, 25, 42, 42, 40, 2, 16, 25, 42, 42, 5, 0,
+	39,
+	36, 22, 25, 6, 32, 12, 36, 11, 39, 18, 39, 24, 31, 50,
+	17, 46, 6, 27, 0, 47, 41, 41, 0, 0, 19, 34, 23, 18,
+	29,
+}
+
+// compatCombos are offsets into compatColors for OBJ0, OBJ1 and BG
+var compatCombos = [][3]int{
+	{4 * 4, 4 * 4, 29 * 4}, {18 * 4, 18 * 4, 18 * 4}, {20 * 4, 20 * 4, 20 * 4}, {24 * 4, 24 * 4, 24 * 4},
+	{9 * 4, 9 * 4, 9 * 4}, {0 * 4, 0 * 4, 0 * 4}, {27 * 4, 27 * 4, 27 * 4}, {5 * 4, 5 * 4, 5 * 4},
+	{12 * 4, 12 * 4, 12 * 4}, {26 * 4, 26 * 4, 26 * 4}, {16 * 4, 8 * 4, 8 * 4}, {4 * 4, 28 * 4, 28 * 4},
+	{4 * 4, 2 * 4, 2 * 4}, {3 * 4, 4 * 4, 4 * 4}, {4 * 4, 29 * 4, 29 * 4}, {28 * 4, 4 * 4, 28 * 4},
+	{2 * 4, 17 * 4, 2 * 4}, {16 * 4, 16 * 4, 8 * 4}, {4 * 4, 4 * 4, 7 * 4}, {4 * 4, 4 * 4, 18 * 4},
+	{4 * 4, 4 * 4, 20 * 4}, {19 * 4, 19 * 4, 9 * 4}, {4*4 - 1, 4*4 - 1, 11 * 4}, {17 * 4, 17 * 4, 2 * 4},
+	{4 * 4, 4 * 4, 2 * 4}, {4 * 4, 4 * 4, 3 * 4}, {28 * 4, 28 * 4, 0 * 4}, {3 * 4, 3 * 4, 0 * 4},
+	{0 * 4, 0 * 4, 1 * 4}, {18 * 4, 22 * 4, 18 * 4}, {20 * 4, 22 * 4, 20 * 4}, {24 * 4, 22 * 4, 24 * 4},
+	{16 * 4, 22 * 4, 8 * 4}, {17 * 4, 4 * 4, 13 * 4}, {28*4 - 1, 0 * 4, 14 * 4}, {28*4 - 1, 4 * 4, 15 * 4},
+	{19 * 4, 22 * 4, 9 * 4}, {16 * 4, 28 * 4, 10 * 4}, {4 * 4, 23 * 4, 28 * 4}, {17 * 4, 22 * 4, 2 * 4},
+	{4 * 4, 0 * 4, 2 * 4}, {4 * 4, 28 * 4, 3 * 4}, {28 * 4, 3 * 4, 0 * 4}, {3 * 4, 28 * 4, 4 * 4},
+	{21 * 4, 28 * 4, 4 * 4}, {3 * 4, 28 * 4, 0 * 4}, {25 * 4, 3 * 4, 28 * 4}, {0 * 4, 28 * 4, 8 * 4},
+	{4 * 4, 3 * 4, 28 * 4}, {28 * 4, 3 * 4, 6 * 4}, {4 * 4, 28 * 4, 29 * 4},
+}
+
+// compatColors are the boot ROM's palettes, four RGB555 colors each
+var compatColors = []uint16{
+	0x7FFF, 0x32BF, 0x00D0, 0x0000, 0x639F, 0x4279, 0x15B0, 0x04CB,
+	0x7FFF, 0x6E31, 0x454A, 0x0000, 0x7FFF, 0x1BEF, 0x0200, 0x0000,
+	0x7FFF, 0x421F, 0x1CF2, 0x0000, 0x7FFF, 0x5294, 0x294A, 0x0000,
+	0x7FFF, 0x03FF, 0x012F, 0x0000, 0x7FFF, 0x03EF, 0x01D6, 0x0000,
+	0x7FFF, 0x42B5, 0x3DC8, 0x0000, 0x7E74, 0x03FF, 0x0180, 0x0000,
+	0x67FF, 0x77AC, 0x1A13, 0x2D6B, 0x7ED6, 0x4BFF, 0x2175, 0x0000,
+	0x53FF, 0x4A5F, 0x7E52, 0x0000, 0x4FFF, 0x7ED2, 0x3A4C, 0x1CE0,
+	0x03ED, 0x7FFF, 0x255F, 0x0000, 0x036A, 0x021F, 0x03FF, 0x7FFF,
+	0x7FFF, 0x01DF, 0x0112, 0x0000, 0x231F, 0x035F, 0x00F2, 0x0009,
+	0x7FFF, 0x03EA, 0x011F, 0x0000, 0x299F, 0x001A, 0x000C, 0x0000,
+	0x7FFF, 0x027F, 0x001F, 0x0000, 0x7FFF, 0x03E0, 0x0206, 0x0120,
+	0x7FFF, 0x7EEB, 0x001F, 0x7C00, 0x7FFF, 0x3FFF, 0x7E00, 0x001F,
+	0x7FFF, 0x03FF, 0x001F, 0x0000, 0x03FF, 0x001F, 0x000C, 0x0000,
+	0x7FFF, 0x033F, 0x0193, 0x0000, 0x0000, 0x4200, 0x037F, 0x7FFF,
+	0x7FFF, 0x7E8C, 0x7C00, 0x0000, 0x7FFF, 0x1BEF, 0x6180, 0x0000,
+}
+
+//titleCombo finds a game in the title table the way the boot ROM does, returning its entry in compatCombos.
+// games that aren't there get the first entry, the default
+func titleCombo(checksum, letter byte) byte {
+	for i, c := range titleChecksums {
+		if c == checksum && (i < uniqueChecksums || titleLetters[i-uniqueChecksums] == letter) {
+			return titleCombos[i]
+		}
+	}
+	return titleCombos[0]
+}
+
+//comboPalette returns the colors of an entry in compatCombos
+func comboPalette(combo byte) render.CompatPalette {
+	colors := func(offset int) [4]uint32 {
+		var pal [4]uint32
+		for i := range pal {
+			pal[i] = render.RGB15ToARGB(compatColors[offset+i]) & 0xFFFFFF
+		}
+		return pal
+	}
+	c := compatCombos[combo]
+	return render.CompatPalette{
+		Name: fmt.Sprintf("boot ROM palette %d", combo),
+		OBJ0: colors(c[0]),
+		OBJ1: colors(c[1]),
+		BG:   colors(c[2]),
+	}
+}
+
+//compatPalette picks the colors for a DMG game on CGB hardware.
+// a manual selection wins, then the title lookup, which only applies to Nintendo's games
+func compatPalette(cart *cartridge.ROM, held joypad.Button) render.CompatPalette {
+	if pal, ok := manualPalettes[held&^(joypad.Select|joypad.Start)]; ok {
+		return pal
+	}
+	if !cart.IsNintendo() {
+		return defaultPalette
+	}
+	combo := titleCombo(cart.TitleChecksum(), (*cart)[0x0137])
+	if combo == titleCombos[0] {
+		return defaultPalette
+	}
+	return comboPalette(combo)
+}
@@ -0,0 +1,69 @@
+package gameboy
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/internal/testrom"
+	"github.com/raidancampbell/goby/joypad"
+	"github.com/raidancampbell/goby/render"
+	"github.com/stretchr/testify/assert"
+)
+
+//nintendoROM is a Nintendo published DMG cartridge with the given title
+func nintendoROM(title string) *cartridge.ROM {
+	rom := testrom.New()
+	copy((*rom)[0x0134:0x0144], make([]byte, 16))
+	copy((*rom)[0x0134:], title)
+	(*rom)[0x014B] = 0x01
+	return rom
+}
+
+//rgb15 converts a palette to palette RAM's colors.  References round some 24-bit colors differently, e.g. 943A3A
+// for what converts back to 943939, but they're the same once loaded
+func rgb15(pal [4]uint32) [4]uint16 {
+	var out [4]uint16
+	for i, c := range pal {
+		out[i] = render.RGB24ToRGB15(c)
+	}
+	return out
+}
+
+func TestCompatPalette_title(t *testing.T) {
+	blue := compatPalette(nintendoROM("POKEMON BLUE"), 0)
+	assert.Equal(t, rgb15(render.PaletteBlue.BG), rgb15(blue.BG))
+	assert.Equal(t, rgb15(render.PaletteRed.BG), rgb15(blue.OBJ0))
+
+	// Tetris is yellow and red
+	tetris := compatPalette(nintendoROM("TETRIS"), 0)
+	assert.Equal(t, [4]uint32{0xFFFFFF, 0xFFFF00, 0xFF0000, 0x000000}, tetris.BG)
+
+	// the shared checksums need the fourth letter to match too
+	assert.Equal(t, byte(22), titleCombo(0x46, 'E'))
+	assert.Equal(t, byte(0), titleCombo(0x46, 'X'))
+	assert.Equal(t, byte(24), titleCombo(0xBF, ' '))
+	assert.Equal(t, byte(50), titleCombo(0xF4, '-'))
+	assert.Equal(t, byte(29), titleCombo(0xB3, 'R'))
+
+	assert.Equal(t, defaultPalette, compatPalette(nintendoROM("NOT IN THE TABLE"), 0))
+	other := nintendoROM("TETRIS")
+	(*other)[0x014B] = 0x08
+	assert.Equal(t, defaultPalette, compatPalette(other, 0))
+	assert.Equal(t, render.PaletteGray, compatPalette(nintendoROM("TETRIS"), joypad.Left|joypad.B))
+}
+
+// the manual palettes are entries in the same tables as the title lookup
+func TestCompatCombos_manual(t *testing.T) {
+	for combo, pal := range map[byte]render.CompatPalette{
+		0: render.PaletteDarkGreen, 1: render.PaletteGreen, 3: render.PaletteOrange, 5: render.PaletteBrown,
+		6: render.PaletteInverted, 7: render.PaletteGray, 8: render.PalettePastel, 28: render.PaletteDarkBrown,
+		40: render.PaletteDarkBlue, 43: render.PaletteRed, 48: render.PaletteBlue, 49: render.PaletteYellow,
+	} {
+		got := comboPalette(combo)
+		assert.Equal(t, rgb15(pal.BG), rgb15(got.BG), pal.Name)
+		assert.Equal(t, rgb15(pal.OBJ0), rgb15(got.OBJ0), pal.Name)
+		assert.Equal(t, rgb15(pal.OBJ1), rgb15(got.OBJ1), pal.Name)
+	}
+	assert.Len(t, titleChecksums, len(titleCombos))
+	assert.Len(t, titleLetters, len(titleChecksums)-uniqueChecksums)
+}
@@ -0,0 +1,238 @@
+package gameboy
+
+import (
+	"errors"
+
+	"github.com/raidancampbell/goby/apu"
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/cpu"
+	"github.com/raidancampbell/goby/dma"
+	"github.com/raidancampbell/goby/joypad"
+	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/serial"
+	"github.com/raidancampbell/goby/sgb"
+	"github.com/raidancampbell/goby/timer"
+)
+
+// Model is the hardware being emulated
+type Model int
+
+const (
+	// ModelAuto picks CGB hardware for cartridges with CGB support, and DMG otherwise
+	ModelAuto Model = iota
+	ModelDMG
+	// ModelCGB runs DMG cartridges too, colorized the way the CGB boot ROM does
+	ModelCGB
+	// ModelSGB is a DMG in a Super Game Boy, which colorizes the game and frames it in a border
+	ModelSGB
+	// ModelMGB is the Game Boy Pocket, a DMG that games can tell apart by A being FF at startup
+	ModelMGB
+	// ModelAGB is the Game Boy Advance, a CGB that games can tell apart by bit 0 of B being set at startup
+	ModelAGB
+)
+
+var modelNames = map[Model]string{
+	ModelAuto: "auto",
+	ModelDMG:  "DMG",
+	ModelCGB:  "CGB",
+	ModelSGB:  "SGB",
+	ModelMGB:  "MGB",
+	ModelAGB:  "AGB",
+}
+
+func (m Model) String() string {
+	if name, ok := modelNames[m]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+//isCGB returns whether the model is CGB hardware, which the AGB is as far as Game Boy games can tell
+func (m Model) isCGB() bool {
+	return m == ModelCGB || m == ModelAGB
+}
+
+// ErrBreak is returned by RunFrame when BeforeStep stops it partway through a frame
+var ErrBreak = errors.New("stopped by debugger")
+
+// GameBoy wires the CPU, memory and PPU together, and hands every finished frame to a Display.
+// it has no dependency on SDL, so it can run anywhere, including CI
+type GameBoy struct {
+	CPU     *cpu.CPU
+	Cart    *cartridge.ROM
+	RAM     *mem.RAM
+	MBC     mem.MBC
+	WRAM    *mem.WRAMBanks // nil unless in CGB mode
+	PPU     render.PPU
+	Joypad  *joypad.Joypad
+	APU     *apu.APU
+	Serial  *serial.Port // nothing is plugged in until a peer is connected
+	Timer   *timer.Timer
+	DMA     *dma.Controller
+	SGB     *sgb.SGB // nil unless the model is ModelSGB
+	Display render.Display
+	Audio   apu.Sink // may be nil, in which case sound is discarded
+
+	Model     Model
+	CGB       bool // running in CGB mode, with color and the extra VRAM and WRAM banks
+	ShowBGMap bool // debug: present the whole 256x256 background map instead of the visible 160x144 area
+	// BeforeStep, if set, is called before each instruction the CPU runs.  Returning false stops RunFrame
+	// with ErrBreak, before the instruction; the next RunFrame picks up where it left off
+	BeforeStep func() bool
+
+	elapsed int // cycles into the current frame. a frame can run past its budget, which is taken out of the next
+	// colorize is set when a DMG game runs on CGB hardware, until the first frame picks its palette
+	colorize bool
+}
+
+//PickModel returns the model ModelAuto runs a cartridge on: CGB hardware for cartridges with CGB support,
+// an SGB for those with SGB support, and DMG otherwise
+func PickModel(cart *cartridge.ROM) Model {
+	switch {
+	case cart.IsGBC():
+		return ModelCGB
+	case cart.IsSuperGB():
+		return ModelSGB
+	}
+	return ModelDMG
+}
+
+//New builds a GameBoy like Create, but panics if the cartridge can't be run.  It's for tests, and ROMs known to work
+func New(cart *cartridge.ROM, model Model, display render.Display) *GameBoy {
+	gb, err := Create(cart, model, display)
+	if err != nil {
+		panic(err)
+	}
+	return gb
+}
+
+//Create builds a GameBoy of the given model around the given cartridge, presenting frames to the given display.
+// it fails if the cartridge's hardware isn't supported.  Every GameBoy has its own CPU and memory, so several can
+// run in the same process, e.g. joined by serial.Link
+func Create(cart *cartridge.ROM, model Model, display render.Display) (*GameBoy, error) {
+	if model == ModelAuto {
+		model = PickModel(cart)
+	}
+	c := cpu.New()
+	gb := &GameBoy{
+		CPU:     c,
+		Cart:    cart,
+		RAM:     c.GetRAM(),
+		Display: display,
+		Model:   model,
+	}
+	mbc, err := gb.RAM.LoadCartridge(cart)
+	if err != nil {
+		return nil, err
+	}
+	gb.MBC = mbc
+	gb.CGB = model.isCGB() && cart.IsGBC()
+	gb.colorize = model.isCGB() && !cart.IsGBC()
+	gb.PPU.Init(gb.RAM, gb.CGB)
+	if gb.CGB {
+		gb.WRAM = gb.RAM.MapWRAMBanks()
+		c.InitForCGB()
+	}
+	switch model {
+	case ModelMGB:
+		c.InitForMGB()
+	case ModelAGB:
+		c.InitForAGB()
+	}
+	gb.Joypad = joypad.New(gb.RAM)
+	gb.APU = apu.New(gb.RAM, apu.DefaultSampleRate)
+	gb.Serial = serial.New(gb.RAM, nil)
+	gb.Timer = timer.New(gb.RAM)
+	gb.DMA = dma.New(gb.RAM, gb.CGB)
+	gb.DMA.DoubleSpeed = c.DoubleSpeed
+	gb.PPU.OnHBlank = gb.DMA.HBlank
+	if model == ModelSGB {
+		gb.SGB = sgb.New()
+		gb.SGB.Tiles = gb.PPU.TransferTiles
+		gb.SGB.OnPlayers = gb.Joypad.SetPlayers
+		gb.Joypad.OnWrite = gb.SGB.WriteP1
+	}
+	return gb, nil
+}
+
+//LoadBootROM overlays a boot ROM on the cartridge, and starts the CPU at 0000 to run it
+func (gb *GameBoy) LoadBootROM(b []byte) error {
+	if err := gb.CPU.LoadBootrom(b); err != nil {
+		return err
+	}
+	gb.CPU.InitPCForBootrom()
+	return nil
+}
+
+//RunFrame runs the CPU, PPU and APU in lockstep for one frame's worth of cycles.
+// if the PPU finishes a frame during that time, it's presented to the display.
+// the timer, serial port and DMA are clocked by the CPU, so they speed up with it in double speed mode.
+// the PPU and APU aren't, so they see half as many cycles
+func (gb *GameBoy) RunFrame() error {
+	gb.Joypad.Update()
+	if gb.colorize {
+		// the boot ROM reads the buttons as the logo scrolls. without it, they're read as the first frame starts
+		gb.PPU.SetCompatPalette(compatPalette(gb.Cart, gb.Joypad.Held()))
+		gb.colorize = false
+	}
+	for gb.elapsed < render.CyclesPerFrame {
+		if gb.BeforeStep != nil && !gb.DMA.Stalling() && !gb.BeforeStep() {
+			return ErrBreak
+		}
+		if err := gb.Step(); err != nil {
+			return err
+		}
+	}
+	gb.elapsed -= render.CyclesPerFrame
+
+	samples := gb.APU.Samples()
+	if gb.Audio == nil {
+		return nil
+	}
+	return gb.Audio.Queue(samples)
+}
+
+//Step runs one CPU instruction, or sits out a VRAM DMA, and clocks everything else for as long as that took
+func (gb *GameBoy) Step() error {
+	// the CPU sits out any VRAM DMA, but everything else keeps running
+	cpuCycles := gb.DMA.Stall()
+	if cpuCycles == 0 {
+		cpuCycles = gb.CPU.Step()
+	}
+	gb.Timer.Step(cpuCycles)
+	gb.Serial.Step(cpuCycles)
+	gb.DMA.Step(cpuCycles)
+
+	cycles := cpuCycles
+	if gb.CPU.DoubleSpeed() {
+		cycles /= 2
+	}
+	gb.elapsed += cycles
+	gb.APU.Step(cycles)
+	if gb.PPU.Step(cycles) {
+		if gb.SGB != nil {
+			gb.SGB.VBlank()
+		}
+		return gb.Redraw()
+	}
+	return nil
+}
+
+//Screen returns the last finished frame, as the display was given it, e.g. for a screenshot
+func (gb *GameBoy) Screen() *render.Frame {
+	return gb.frame()
+}
+
+//Redraw presents the last finished frame again, e.g. after loading a state
+func (gb *GameBoy) Redraw() error {
+	return gb.Display.Present(gb.frame())
+}
+
+//frame returns the finished frame to present: the screen as the PPU drew it, or as the SGB shows it
+func (gb *GameBoy) frame() *render.Frame {
+	if gb.SGB == nil || gb.ShowBGMap {
+		return gb.PPU.Frame(gb.ShowBGMap)
+	}
+	return gb.SGB.Frame(&gb.PPU.Screen)
+}
@@ -0,0 +1,41 @@
+package gameboy
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/internal/testrom"
+	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/serial"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//transferROM puts a byte in SB and starts a transfer with the given SC, then halts
+func transferROM(sb, sc byte) *cartridge.ROM {
+	return testrom.New(
+		0x3E, sb, // LD A,sb
+		0xE0, 0x01, // LDH (SB),A
+		0x3E, sc, // LD A,sc
+		0xE0, 0x02, // LDH (SC),A
+	)
+}
+
+func TestGameBoy_serialLink(t *testing.T) {
+	// each GameBoy has its own CPU and memory, so two can be linked in one process
+	slave := New(transferROM(0x22, 0x80), ModelDMG, &render.Headless{})
+	master := New(transferROM(0x11, 0x81), ModelDMG, &render.Headless{})
+	serial.Link(master.Serial, slave.Serial)
+
+	// the slave waits on the master's clock, so it's ready by the time the master sends
+	require.NoError(t, slave.RunFrame())
+	require.NoError(t, master.RunFrame())
+
+	assert.Equal(t, byte(0x22), master.RAM.Peek(serial.RegSB))
+	assert.Equal(t, byte(0x11), slave.RAM.Peek(serial.RegSB))
+	for _, gb := range []*GameBoy{master, slave} {
+		assert.Zero(t, gb.RAM.Peek(serial.RegSC)&0x80, "the transfer is done")
+		assert.NotZero(t, gb.RAM.Peek(mem.RegIF)&(1<<mem.IntSerial))
+	}
+}
@@ -0,0 +1,99 @@
+package gameboy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+
+	"github.com/raidancampbell/goby/state"
+)
+
+const (
+	stateMagic = "GOBYSAVE"
+	// StateVersion is bumped whenever the layout of a save state changes. older states are refused
+	StateVersion = 1
+)
+
+// ErrWrongROM is returned when loading a save state made with a different cartridge
+var ErrWrongROM = errors.New("save state is for a different ROM")
+
+//serialize saves or restores every component, in a fixed order
+func (gb *GameBoy) serialize(s *state.Serializer) {
+	parts := []state.Serializable{gb.CPU, gb.RAM, gb.MBC, &gb.PPU, gb.Timer, gb.DMA, gb.APU, gb.Serial, gb.Joypad}
+	if gb.WRAM != nil {
+		parts = append(parts, gb.WRAM)
+	}
+	if gb.SGB != nil {
+		parts = append(parts, gb.SGB)
+	}
+	for _, p := range parts {
+		p.Serialize(s)
+	}
+	s.Int(&gb.elapsed)
+	s.Bool(&gb.colorize)
+}
+
+//header identifies what a save state can be loaded into: the format version, the model, and the ROM's CRC-32
+func (gb *GameBoy) header(s *state.Serializer) {
+	magic := []byte(stateMagic)
+	s.Bytes(magic)
+	if s.Loading() && s.Err() == nil && string(magic) != stateMagic {
+		s.Fail(errors.New("not a save state"))
+	}
+
+	version := uint16(StateVersion)
+	s.Uint16(&version)
+	if s.Loading() && s.Err() == nil && version != StateVersion {
+		s.Fail(fmt.Errorf("save state is version %d, but only version %d is supported", version, StateVersion))
+	}
+
+	model := uint8(gb.Model)
+	s.Uint8(&model)
+	if s.Loading() && s.Err() == nil && Model(model) != gb.Model {
+		s.Fail(fmt.Errorf("save state is for model %d, but this is model %d", model, gb.Model))
+	}
+
+	checksum := crc32.ChecksumIEEE(*gb.Cart)
+	s.Uint32(&checksum)
+	if s.Loading() && s.Err() == nil && checksum != crc32.ChecksumIEEE(*gb.Cart) {
+		s.Fail(ErrWrongROM)
+	}
+}
+
+//SaveState writes the whole machine to w
+func (gb *GameBoy) SaveState(w io.Writer) error {
+	s := state.NewWriter(w)
+	gb.header(s)
+	gb.serialize(s)
+	return s.Err()
+}
+
+//LoadState restores the whole machine from a save state.  If it can't be loaded, the machine is left as it was
+func (gb *GameBoy) LoadState(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s := state.NewReader(bytes.NewReader(data))
+	gb.header(s)
+	if s.Err() != nil {
+		return s.Err()
+	}
+
+	// a truncated state only fails part way through restoring, so keep a copy to go back to
+	var backup bytes.Buffer
+	if err := gb.SaveState(&backup); err != nil {
+		return err
+	}
+	gb.serialize(s)
+	if err := s.Err(); err != nil {
+		restore := state.NewReader(&backup)
+		gb.header(restore)
+		gb.serialize(restore)
+		return fmt.Errorf("loading save state: %v", err)
+	}
+	return nil
+}
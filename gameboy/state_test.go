@@ -0,0 +1,47 @@
+package gameboy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/raidancampbell/goby/internal/testrom"
+	"github.com/raidancampbell/goby/render"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveState_roundTrip(t *testing.T) {
+	gb := New(testrom.New(), ModelDMG, &render.Headless{})
+	require.NoError(t, gb.RunFrame())
+	gb.RAM.WriteByte(0xC000, 0x42)
+
+	var saved bytes.Buffer
+	require.NoError(t, gb.SaveState(&saved))
+
+	require.NoError(t, gb.RunFrame())
+	gb.RAM.WriteByte(0xC000, 0x99)
+	require.NoError(t, gb.LoadState(bytes.NewReader(saved.Bytes())))
+	assert.Equal(t, byte(0x42), gb.RAM.ReadByte(0xC000))
+
+	var again bytes.Buffer
+	require.NoError(t, gb.SaveState(&again))
+	assert.Equal(t, saved.Bytes(), again.Bytes())
+}
+
+func TestLoadState_refusesOtherROMs(t *testing.T) {
+	gb := New(testrom.New(), ModelDMG, &render.Headless{})
+	var saved bytes.Buffer
+	require.NoError(t, gb.SaveState(&saved))
+
+	other := testrom.New()
+	(*other)[0x0134] = 'X'
+	gb = New(other, ModelDMG, &render.Headless{})
+	gb.RAM.WriteByte(0xC000, 0x42)
+	assert.Equal(t, ErrWrongROM, gb.LoadState(bytes.NewReader(saved.Bytes())))
+
+	// a truncated state leaves the machine untouched
+	gb = New(testrom.New(), ModelDMG, &render.Headless{})
+	gb.RAM.WriteByte(0xC000, 0x42)
+	assert.Error(t, gb.LoadState(bytes.NewReader(saved.Bytes()[:saved.Len()/2])))
+	assert.Equal(t, byte(0x42), gb.RAM.ReadByte(0xC000))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/raidancampbell/goby/cartridge"
+)
+
+//info prints a ROM's header: goby info rom.gb
+func info(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: goby info rom.gb\n")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("info takes one ROM")
+	}
+	rom, err := cartridge.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	printHeader(w, rom)
+	return nil
+}
+
+//printHeader writes out what a ROM's header says about it, one field a line
+func printHeader(w io.Writer, rom *cartridge.ROM) {
+	cgb := "no"
+	switch {
+	case rom.IsGBCOnly():
+		cgb = "required"
+	case rom.IsGBC():
+		cgb = "supported"
+	}
+	ram := "unknown"
+	if size, ok := rom.RAMSize(); ok && size == 0 {
+		ram = "none"
+	} else if ok {
+		ram = fmt.Sprintf("%dKB", size/1024)
+	}
+	licensee := fmt.Sprintf("%02X", rom.OldLicenseeCode())
+	if rom.OldLicenseeCode() == 0x33 {
+		code := rom.LicenseeCode()
+		licensee = string(code[:])
+	}
+	destination := "overseas"
+	if rom.IsJapanese() {
+		destination = "Japan"
+	}
+	headerStored, headerComputed := rom.HeaderChecksum()
+	globalStored, globalComputed := rom.GlobalChecksum()
+
+	fmt.Fprintf(w, "title:           %s\n", rom.Name())
+	fmt.Fprintf(w, "type:            %02X %s\n", byte(rom.Type()), rom.Type())
+	if banks := rom.ROMBanks(); banks > 0 {
+		fmt.Fprintf(w, "ROM:             %dKB, %d banks (file is %dKB)\n", banks*16, banks, len(*rom)/1024)
+	} else {
+		fmt.Fprintf(w, "ROM:             unknown (file is %dKB)\n", len(*rom)/1024)
+	}
+	fmt.Fprintf(w, "RAM:             %s\n", ram)
+	fmt.Fprintf(w, "CGB:             %s\n", cgb)
+	fmt.Fprintf(w, "SGB:             %s\n", yesNo(rom.IsSuperGB()))
+	fmt.Fprintf(w, "licensee:        %s\n", licensee)
+	fmt.Fprintf(w, "destination:     %s\n", destination)
+	fmt.Fprintf(w, "version:         %d\n", rom.Version())
+	fmt.Fprintf(w, "header checksum: %02X%s\n", headerStored, checksumNote(headerStored == headerComputed, fmt.Sprintf("%02X", headerComputed)))
+	fmt.Fprintf(w, "global checksum: %04X%s\n", globalStored, checksumNote(globalStored == globalComputed, fmt.Sprintf("%04X", globalComputed)))
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+//checksumNote says whether a checksum is right, and if not, what it should be
+func checksumNote(ok bool, computed string) string {
+	if ok {
+		return " (ok)"
+	}
+	return fmt.Sprintf(" (wrong, should be %s)", computed)
+}
@@ -0,0 +1,17 @@
+// Package testrom builds cartridges for tests, from code to run at 0100
+package testrom
+
+import "github.com/raidancampbell/goby/cartridge"
+
+//New returns a plain 32KB cartridge that runs the given code from 0100, the entry point, and halts once it runs out.
+// everything else is HALT too, apart from the header bytes that would ask for CGB support, an MBC or RAM.
+// interrupts are left off
+func New(code ...byte) *cartridge.ROM {
+	rom := make(cartridge.ROM, 0x8000)
+	for i := range rom {
+		rom[i] = 0x76
+	}
+	rom[0x0143], rom[0x0146], rom[0x0147], rom[0x0149] = 0, 0, 0, 0
+	copy(rom[0x0100:], code)
+	return &rom
+}
@@ -0,0 +1,204 @@
+package joypad
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/state"
+)
+
+// Button is a bitmask of joypad buttons.
+// the low nibble is the direction pad and the high nibble is the action buttons,
+// each in the same bit order that the P1 register reports them
+type Button uint8
+
+const (
+	Right Button = 1 << iota
+	Left
+	Up
+	Down
+	A
+	B
+	Select
+	Start
+)
+
+// ButtonNames are the buttons by name, e.g. for binding them in a config file
+var ButtonNames = map[string]Button{
+	"right": Right, "left": Left, "up": Up, "down": Down,
+	"a": A, "b": B, "select": Select, "start": Start,
+}
+
+//ParseButton returns the button with the given name, e.g. start, in any case
+func ParseButton(name string) (Button, error) {
+	if b, ok := ButtonNames[strings.ToLower(name)]; ok {
+		return b, nil
+	}
+	return 0, fmt.Errorf("unknown button %q: want up, down, left, right, a, b, select or start", name)
+}
+
+const (
+	RegP1 = 0xFF00 // P1/JOYP
+
+	selectDirections = 0x10 // P14, active low
+	selectActions    = 0x20 // P15, active low
+
+	// MaxPlayers is how many controllers an SGB can multiplex, with a multitap
+	MaxPlayers = 4
+)
+
+// Source reports which buttons are currently held
+// e.g. a keyboard, a gamepad, or a bot
+type Source interface {
+	Buttons() Button
+}
+
+// Joypad is the P1/JOYP register.  The game selects the direction pad and/or the action buttons
+// by writing bits 4 and 5, then reads the selected buttons from the low nibble. everything is active low
+type Joypad struct {
+	ram     *mem.RAM
+	sources [MaxPlayers][]Source
+	selects byte               // bits 4 and 5 of P1 as last written
+	held    [MaxPlayers]Button // union of each player's sources as of the last Update
+
+	// an SGB can ask for up to 4 controllers. The game then cycles through them, and reads which one is
+	// selected from the low nibble while neither button group is selected
+	players int
+	player  int
+
+	// OnWrite, if set, sees every write to P1.  The SGB receives its command packets this way
+	OnWrite func(val byte)
+}
+
+//New creates a joypad and maps it into memory at FF00
+func New(ram *mem.RAM, sources ...Source) *Joypad {
+	j := &Joypad{
+		ram:     ram,
+		selects: selectDirections | selectActions,
+		players: 1,
+	}
+	j.sources[0] = sources
+	ram.Map(RegP1, RegP1, j)
+	return j
+}
+
+//AddSource adds another input for the first player. All sources are OR'd together
+func (j *Joypad) AddSource(s Source) {
+	j.AddPlayerSource(0, s)
+}
+
+//AddPlayerSource adds an input for the given player, from 0 to 3.  Only the first player is read
+// unless an SGB game asks for more controllers
+func (j *Joypad) AddPlayerSource(player int, s Source) {
+	j.sources[player] = append(j.sources[player], s)
+}
+
+//SetPlayers sets how many controllers the game cycles through: 1, 2 or 4
+func (j *Joypad) SetPlayers(n int) {
+	j.players = n
+	j.player = 0
+}
+
+//Update polls every source.  This is called once per frame, which is as often as games read the joypad
+func (j *Joypad) Update() {
+	before := j.lines()
+	for p, sources := range j.sources {
+		var held Button
+		for _, s := range sources {
+			held |= s.Buttons()
+		}
+		j.held[p] = held
+	}
+	j.checkFallingEdge(before)
+}
+
+//Held returns the buttons the first player held as of the last Update
+func (j *Joypad) Held() Button {
+	return j.held[0]
+}
+
+//lines returns the active-low state of P10-P13 for the currently selected button groups
+func (j *Joypad) lines() byte {
+	if j.players > 1 && j.selects == selectDirections|selectActions {
+		return 0x0F - byte(j.player)
+	}
+	var pressed byte
+	held := j.held[j.player]
+	if j.selects&selectDirections == 0 {
+		pressed |= byte(held) & 0x0F
+	}
+	if j.selects&selectActions == 0 {
+		pressed |= byte(held>>4) & 0x0F
+	}
+	return ^pressed & 0x0F
+}
+
+//checkFallingEdge requests the joypad interrupt if any of P10-P13 went from high to low
+func (j *Joypad) checkFallingEdge(before byte) {
+	if before&^j.lines() != 0 {
+		j.ram.RequestInterrupt(mem.IntJoypad)
+	}
+}
+
+func (j *Joypad) Read(addr uint16) byte {
+	// bits 6 and 7 are unused and read back as set
+	return 0xC0 | j.selects | j.lines()
+}
+
+func (j *Joypad) Write(addr uint16, val byte) {
+	// only the select bits are writable
+	before := j.lines()
+	selects := val & (selectDirections | selectActions)
+	if j.players > 1 && j.selects&selectActions == 0 && selects&selectActions != 0 {
+		// P15 going high moves on to the next controller
+		j.player = (j.player + 1) % j.players
+	}
+	j.selects = selects
+	j.checkFallingEdge(before)
+	if j.OnWrite != nil {
+		j.OnWrite(val)
+	}
+}
+
+// Virtual is a Source driven by code rather than hardware, e.g. for bots and tests.
+// it is safe to press and release buttons from another goroutine
+type Virtual struct {
+	mu   sync.Mutex
+	held Button
+}
+
+//Press holds down the given buttons, in addition to anything already held
+func (v *Virtual) Press(b Button) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.held |= b
+}
+
+//Release lets go of the given buttons
+func (v *Virtual) Release(b Button) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.held &^= b
+}
+
+//Set replaces the held buttons with exactly the given buttons
+func (v *Virtual) Set(b Button) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.held = b
+}
+
+func (v *Virtual) Buttons() Button {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.held
+}
+
+//Serialize saves or restores the selected button groups and controller.  The buttons held come from the sources
+func (j *Joypad) Serialize(s *state.Serializer) {
+	s.Uint8(&j.selects)
+	s.Int(&j.players)
+	s.Int(&j.player)
+}
@@ -0,0 +1,70 @@
+package joypad
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoypad_selectsButtonGroups(t *testing.T) {
+	ram := &mem.RAM{}
+	bot := &Virtual{}
+	j := New(ram, bot)
+
+	bot.Press(Right | Start)
+	j.Update()
+
+	// nothing selected: all lines read high
+	assert.Equal(t, byte(0xFF), ram.ReadByte(RegP1))
+
+	ram.WriteByte(RegP1, 0x20) // select the direction pad
+	assert.Equal(t, byte(0xEE), ram.ReadByte(RegP1))
+
+	ram.WriteByte(RegP1, 0x10) // select the action buttons
+	assert.Equal(t, byte(0xD7), ram.ReadByte(RegP1))
+}
+
+func TestJoypad_interruptOnFallingEdge(t *testing.T) {
+	ram := &mem.RAM{}
+	bot := &Virtual{}
+	j := New(ram, bot)
+	ram.WriteByte(RegP1, 0x20)
+
+	// a button from the unselected group doesn't pull any line low
+	bot.Press(A)
+	j.Update()
+	assert.Zero(t, ram.Peek(mem.RegIF))
+
+	bot.Press(Down)
+	j.Update()
+	assert.Equal(t, byte(1<<mem.IntJoypad), ram.Peek(mem.RegIF))
+
+	// releasing is a rising edge
+	ram.WriteByte(mem.RegIF, 0)
+	bot.Release(Down)
+	j.Update()
+	assert.Zero(t, ram.Peek(mem.RegIF))
+}
+
+func TestJoypad_multiplayer(t *testing.T) {
+	ram := &mem.RAM{}
+	one, two := &Virtual{}, &Virtual{}
+	j := New(ram, one)
+	j.AddPlayerSource(1, two)
+	j.SetPlayers(2)
+	two.Press(A)
+	j.Update()
+
+	// with nothing selected, the low nibble is the selected controller
+	ram.WriteByte(RegP1, 0x30)
+	assert.Equal(t, byte(0xFF), ram.ReadByte(RegP1))
+
+	// P15 going high moves on to the second controller
+	ram.WriteByte(RegP1, 0x10)
+	assert.Equal(t, byte(0xDF), ram.ReadByte(RegP1))
+	ram.WriteByte(RegP1, 0x30)
+	assert.Equal(t, byte(0xFE), ram.ReadByte(RegP1))
+	ram.WriteByte(RegP1, 0x10)
+	assert.Equal(t, byte(0xDE), ram.ReadByte(RegP1))
+}
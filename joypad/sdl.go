@@ -0,0 +1,145 @@
+//go:build sdl
+// +build sdl
+
+package joypad
+
+import "github.com/veandco/go-sdl2/sdl"
+
+// stickDeadzone is how far an analog stick must be pushed before it counts as a direction
+const stickDeadzone = 16000
+
+// DefaultKeyBindings are the arrow keys for the direction pad, X/Z for A/B, and Enter/Backspace for Start/Select
+var DefaultKeyBindings = map[sdl.Scancode]Button{
+	sdl.SCANCODE_RIGHT:     Right,
+	sdl.SCANCODE_LEFT:      Left,
+	sdl.SCANCODE_UP:        Up,
+	sdl.SCANCODE_DOWN:      Down,
+	sdl.SCANCODE_X:         A,
+	sdl.SCANCODE_Z:         B,
+	sdl.SCANCODE_BACKSPACE: Select,
+	sdl.SCANCODE_RETURN:    Start,
+}
+
+// DefaultGamepadBindings follow the physical layout of the Game Boy: the face button on the right is A
+var DefaultGamepadBindings = map[sdl.GameControllerButton]Button{
+	sdl.CONTROLLER_BUTTON_DPAD_RIGHT: Right,
+	sdl.CONTROLLER_BUTTON_DPAD_LEFT:  Left,
+	sdl.CONTROLLER_BUTTON_DPAD_UP:    Up,
+	sdl.CONTROLLER_BUTTON_DPAD_DOWN:  Down,
+	sdl.CONTROLLER_BUTTON_B:          A,
+	sdl.CONTROLLER_BUTTON_A:          B,
+	sdl.CONTROLLER_BUTTON_BACK:       Select,
+	sdl.CONTROLLER_BUTTON_START:      Start,
+}
+
+// Keyboard is a Source reading the SDL keyboard state
+type Keyboard struct {
+	Bindings map[sdl.Scancode]Button
+}
+
+//NewKeyboard creates a keyboard source with a copy of the default bindings
+func NewKeyboard() *Keyboard {
+	k := &Keyboard{Bindings: map[sdl.Scancode]Button{}}
+	for sc, b := range DefaultKeyBindings {
+		k.Bindings[sc] = b
+	}
+	return k
+}
+
+//Bind makes the key press the button, in place of whatever keys pressed it before
+func (k *Keyboard) Bind(key sdl.Scancode, b Button) {
+	for sc, bound := range k.Bindings {
+		if bound == b {
+			delete(k.Bindings, sc)
+		}
+	}
+	k.Bindings[key] = b
+}
+
+func (k *Keyboard) Buttons() Button {
+	var held Button
+	state := sdl.GetKeyboardState()
+	for sc, b := range k.Bindings {
+		if int(sc) < len(state) && state[sc] != 0 {
+			held |= b
+		}
+	}
+	return held
+}
+
+// Gamepads is a Source combining every connected SDL game controller.
+// controllers are opened and closed as they're plugged in and removed, which SDL reports through events.
+// controllers that are already connected at startup are reported the same way
+type Gamepads struct {
+	Bindings    map[sdl.GameControllerButton]Button
+	controllers map[sdl.JoystickID]*sdl.GameController
+}
+
+//NewGamepads creates a gamepad source with a copy of the default bindings
+func NewGamepads() *Gamepads {
+	g := &Gamepads{
+		Bindings:    map[sdl.GameControllerButton]Button{},
+		controllers: map[sdl.JoystickID]*sdl.GameController{},
+	}
+	for btn, b := range DefaultGamepadBindings {
+		g.Bindings[btn] = b
+	}
+	return g
+}
+
+//Bind makes the controller button press the Game Boy button, in place of whatever pressed it before
+func (g *Gamepads) Bind(btn sdl.GameControllerButton, b Button) {
+	for bound, was := range g.Bindings {
+		if was == b {
+			delete(g.Bindings, bound)
+		}
+	}
+	g.Bindings[btn] = b
+}
+
+//HandleEvent opens newly attached controllers and closes removed ones
+func (g *Gamepads) HandleEvent(event sdl.Event) {
+	e, ok := event.(*sdl.ControllerDeviceEvent)
+	if !ok {
+		return
+	}
+	switch e.Type {
+	case sdl.CONTROLLERDEVICEADDED:
+		// for added devices, Which is the device index rather than the instance ID
+		gc := sdl.GameControllerOpen(int(e.Which))
+		if gc != nil {
+			g.controllers[gc.Joystick().InstanceID()] = gc
+		}
+	case sdl.CONTROLLERDEVICEREMOVED:
+		if gc, ok := g.controllers[e.Which]; ok {
+			gc.Close()
+			delete(g.controllers, e.Which)
+		}
+	}
+}
+
+func (g *Gamepads) Buttons() Button {
+	var held Button
+	for _, gc := range g.controllers {
+		for btn, b := range g.Bindings {
+			if gc.Button(btn) != 0 {
+				held |= b
+			}
+		}
+		// the left stick doubles as the direction pad
+		x, y := gc.Axis(sdl.CONTROLLER_AXIS_LEFTX), gc.Axis(sdl.CONTROLLER_AXIS_LEFTY)
+		switch {
+		case x > stickDeadzone:
+			held |= Right
+		case x < -stickDeadzone:
+			held |= Left
+		}
+		switch {
+		case y > stickDeadzone:
+			held |= Down
+		case y < -stickDeadzone:
+			held |= Up
+		}
+	}
+	return held
+}
@@ -1,45 +1,574 @@
 package main
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/raidancampbell/goby/apu"
 	"github.com/raidancampbell/goby/cartridge"
-	"github.com/raidancampbell/goby/cpu"
-	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/config"
+	"github.com/raidancampbell/goby/debugger"
+	"github.com/raidancampbell/goby/gameboy"
+	"github.com/raidancampbell/goby/printer"
 	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/rewind"
+	"github.com/raidancampbell/goby/sched"
+	"github.com/raidancampbell/goby/serial"
+	"github.com/raidancampbell/goby/symbols"
+	"github.com/raidancampbell/goby/trace"
+	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type dmg struct {
-	cpu  *cpu.CPU
-	cart *cartridge.ROM
-	ram  *mem.RAM
-	lcd  render.LCD
-	ppu  render.PPU
+// models are the values -model takes
+var models = map[string]gameboy.Model{
+	"auto": gameboy.ModelAuto,
+	"dmg":  gameboy.ModelDMG,
+	"mgb":  gameboy.ModelMGB,
+	"sgb":  gameboy.ModelSGB,
+	"cgb":  gameboy.ModelCGB,
+	"agb":  gameboy.ModelAGB,
 }
 
+//nameOfModel returns the name -model takes for a model, picking one for the cartridge if it's auto
+func nameOfModel(model gameboy.Model, cart *cartridge.ROM) string {
+	if model == gameboy.ModelAuto {
+		model = gameboy.PickModel(cart)
+	}
+	for name, m := range models {
+		if m == model {
+			return name
+		}
+	}
+	return ""
+}
+
+// syncModes are the values -sync takes
+var syncModes = map[string]sched.Mode{
+	"audio": sched.SyncAudio,
+	"video": sched.SyncVideo,
+	"timer": sched.SyncTimer,
+	"off":   sched.Unlimited,
+}
+
+//parseSync reads a -sync value
+func parseSync(s string) (sched.Mode, error) {
+	if m, ok := syncModes[strings.ToLower(s)]; ok {
+		return m, nil
+	}
+	return 0, fmt.Errorf("unknown -sync %q: want audio, video, timer or off", s)
+}
+
+//parseModel reads a -model value
+func parseModel(s string) (gameboy.Model, error) {
+	if m, ok := models[strings.ToLower(s)]; ok {
+		return m, nil
+	}
+	return 0, fmt.Errorf("unknown model %q: want auto, dmg, mgb, sgb, cgb or agb", s)
+}
+
+//slotPath is where a save state slot is kept, e.g. tetris.ss1: next to the ROM, or in the state directory if there is one
+func slotPath(stateDir, romPath string, slot int) string {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	if stateDir != "" {
+		base = filepath.Join(stateDir, filepath.Base(base))
+	}
+	return fmt.Sprintf("%s.ss%d", base, slot)
+}
+
+//savePath is where a cartridge's battery-backed RAM is kept, e.g. tetris.sav: next to the ROM, or in the save
+// directory if there is one
+func savePath(saveDir, romPath string) string {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	if saveDir != "" {
+		base = filepath.Join(saveDir, filepath.Base(base))
+	}
+	return base + ".sav"
+}
+
+//loadSave restores a cartridge's battery-backed RAM from the save at path, if there is one
+func loadSave(gb *gameboy.GameBoy, path string) error {
+	ram := gb.MBC.RAM()
+	if !gb.Cart.Type().HasBattery() || len(ram) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(b) != len(ram) {
+		// it's refused rather than partly loaded, so it isn't overwritten when the game ends
+		return fmt.Errorf("%s: the cartridge has %d bytes of RAM, but the save is %d", path, len(ram), len(b))
+	}
+	copy(ram, b)
+	return nil
+}
+
+//writeSave writes a cartridge's battery-backed RAM to path, as the battery would keep it once the power is off
+func writeSave(gb *gameboy.GameBoy, path string) error {
+	ram := gb.MBC.RAM()
+	if !gb.Cart.Type().HasBattery() || len(ram) == 0 {
+		return nil
+	}
+	return ioutil.WriteFile(path, ram, 0644)
+}
+
+//record snapshots the machine after a frame, for rewinding
+func record(gb *gameboy.GameBoy, history *rewind.Buffer) error {
+	var buf bytes.Buffer
+	if err := gb.SaveState(&buf); err != nil {
+		return fmt.Errorf("recording for rewind: %v", err)
+	}
+	history.Push(buf.Bytes())
+	return nil
+}
+
+//stepBack restores the frame before the current one, and shows it.  Once history runs out, the picture holds still.
+// onScreen says the newest snapshot is of the frame being shown, so it's dropped rather than restored
+func stepBack(gb *gameboy.GameBoy, history *rewind.Buffer, onScreen bool) error {
+	if onScreen {
+		history.Pop()
+	}
+	snapshot, ok := history.Pop()
+	if !ok {
+		return nil
+	}
+	if err := gb.LoadState(bytes.NewReader(snapshot)); err != nil {
+		return fmt.Errorf("rewinding: %v", err)
+	}
+	return gb.Redraw()
+}
+
+//loadSettings reads the settings file, or the default one if path is empty, and picks the settings for the ROM.
+// the default file doesn't have to exist, but one that was asked for does
+func loadSettings(path string, cart *cartridge.ROM) (config.Settings, error) {
+	if path == "" {
+		var err error
+		if path, err = config.Path(); err != nil {
+			// without a user config directory, there's no default file
+			return config.Settings{}, nil
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return config.Settings{}, err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return config.Settings{}, err
+	}
+	return cfg.For(cart), nil
+}
+
+//applySettings gives flags that weren't on the command line the value from the settings file, if it has one
+func applySettings(flags *flag.FlagSet, s config.Settings) error {
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	values := map[string]string{
+		"palette":        s.Palette,
+		"model":          s.Model,
+		"save-dir":       s.SaveDir,
+		"state-dir":      s.StateDir,
+		"screenshot-dir": s.ScreenshotDir,
+		"sync":           s.Sync,
+	}
+	if s.Scale != 0 {
+		values["scale"] = strconv.Itoa(s.Scale)
+	}
+	if s.FastForward != 0 {
+		values["fast-forward"] = strconv.Itoa(s.FastForward)
+	}
+	for name, value := range values {
+		if given[name] || value == "" {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("settings file: %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+//saveScreenshot writes what's on screen to a PNG named after the ROM and the time, e.g. tetris-20240102-150405.png,
+// next to the ROM or in the given directory.  Failures are only logged, as they shouldn't end the game
+func saveScreenshot(gb *gameboy.GameBoy, dir, romPath string) {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	if dir != "" {
+		base = filepath.Join(dir, filepath.Base(base))
+	}
+	path := fmt.Sprintf("%s-%s.png", base, time.Now().Format("20060102-150405"))
+	f, err := os.Create(path)
+	if err == nil {
+		err = gb.Screen().WritePNG(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Printf("screenshot: %v", err)
+		return
+	}
+	log.Printf("saved %s", path)
+}
+
+//handleState saves or loads a slot.  Failures are only logged, as they shouldn't end the game
+func handleState(gb *gameboy.GameBoy, path string, req stateRequest) {
+	var err error
+	if req.save {
+		var f *os.File
+		if f, err = os.Create(path); err == nil {
+			err = gb.SaveState(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	} else {
+		var f *os.File
+		if f, err = os.Open(path); err == nil {
+			err = gb.LoadState(f)
+			f.Close()
+		}
+	}
+	if err != nil {
+		log.Printf("save state slot %d: %v", req.slot, err)
+		return
+	}
+	log.Printf("%s slot %d", map[bool]string{true: "saved", false: "loaded"}[req.save], req.slot)
+}
+
+//runFrame runs a frame.  The emulator panics on opcodes it doesn't implement yet, which is returned as an error
+func runFrame(gb *gameboy.GameBoy) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("emulator stopped: %v", r)
+		}
+	}()
+	return gb.RunFrame()
+}
+
+//startTrace logs every instruction the filter lets through to a file, until the returned function finishes it
+func startTrace(gb *gameboy.GameBoy, path string, filter trace.Filter, annotate bool, syms *symbols.Table) (stop func(), err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	tracer := trace.New(f, gb.RAM, gb.MBC)
+	tracer.Filter = filter
+	tracer.Annotate = annotate
+	tracer.Symbols = syms
+	gb.CPU.Trace = tracer.Instruction
+	return func() {
+		gb.CPU.Trace = nil
+		if err := tracer.Flush(); err != nil {
+			log.Printf("trace: %v", err)
+		}
+		f.Close()
+	}, nil
+}
+
+const usage = `usage: goby [flags] rom.gb
+       goby info rom.gb
+
+goby runs a Game Boy ROM.  info prints what the ROM's header says about it instead.
+
+flags:
+`
+
 func main() {
-	cwd, err := os.Getwd()
-	gamedir := filepath.Join(cwd, "omitted-assets/tetris.gb")
-	romFile, err := os.OpenFile(gamedir, os.O_RDONLY, 0)
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "info" {
+		err = info(os.Args[2:], os.Stdout)
+	} else {
+		err = run(os.Args[1:])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goby: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+//run runs a ROM, as the command line says, until the window is closed, the frames run out, or the debugger quits
+func run(args []string) error {
+	flags := flag.NewFlagSet("goby", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	bootromPath := flags.String("bootrom", "", "run this boot ROM before the game, on the hardware it's for when -model is auto. without one, the game starts straight away")
+	modelName := flags.String("model", "auto", "the hardware to emulate: dmg, mgb (Game Boy Pocket), sgb, cgb, agb (Game Boy Advance), or auto to pick from the ROM's header")
+	scale := flags.Int("scale", 3, "how many times bigger than the Game Boy's screen the window is")
+	headless := flags.Bool("headless", false, "run without a window, sound or input, as fast as possible")
+	syncName := flags.String("sync", "", "what to pace emulation against: audio, video (vsync), timer, or off to run as fast as possible. audio by default, or off with -headless")
+	fastForward := flags.Int("fast-forward", sched.DefaultMultiplier, "how many times real speed the fast-forward key runs at")
+	frames := flags.Int("frames", 0, "stop after this many frames. 0 runs until the window is closed")
+	saveDir := flags.String("save-dir", "", "keep the saves of cartridges with a battery, e.g. tetris.sav, in this directory, instead of next to the ROM")
+	stateDir := flags.String("state-dir", "", "keep save states in this directory, instead of next to the ROM")
+	linkListen := flags.String("link-listen", "", "wait for another goby to connect a link cable on this address, e.g. :5000")
+	linkConnect := flags.String("link-connect", "", "connect a link cable to the goby listening on this address, e.g. localhost:5000")
+	printerDir := flags.String("printer", "", "plug in a Game Boy Printer, saving each printed page as a PNG in this directory")
+	paletteName := flags.String("palette", "gray", "DMG colors: gray, classic, pocket, light, or 4 RGB hex colors from lightest to darkest")
+	ghosting := flags.Float64("ghosting", 0, "blend each frame with the last, from 0 to 1, for games that flicker objects")
+	grid := flags.Bool("grid", false, "show the gaps between the LCD's pixels")
+	colorCorrect := flags.Bool("color-correct", false, "mimic the CGB LCD's washed out colors in CGB games")
+	rewindSeconds := flags.Float64("rewind", 10, "how many seconds can be rewound. 0 turns rewinding off")
+	debugStart := flags.Bool("debug", false, "start in the debugger, before the first instruction. it reads commands from the terminal")
+	tracePath := flags.String("trace", "", "log every instruction to this file, in the A:01 F:B0 ... PC:0100 PCMEM:... format of other emulators' logs")
+	tracePCs := flags.String("trace-pc", "", "only trace instructions in these PC ranges, e.g. 0150-01FF,4000-7FFF")
+	traceBanks := flags.String("trace-bank", "", "only trace code in these ROM banks, e.g. 0,1")
+	traceOps := flags.String("trace-op", "", "only trace these opcodes, e.g. CD,C9")
+	traceAnnotate := flags.Bool("trace-annotate", false, "add the disassembled instruction and its symbol to each trace line")
+	screenshotDir := flags.String("screenshot-dir", "", "save screenshots (F10) in this directory, instead of next to the ROM")
+	configPath := flags.String("config", "", "read settings from this file, instead of goby/config.json in the user config directory")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one ROM, got %d arguments", flags.NArg())
+	}
+	romPath := flags.Arg(0)
+	cart, err := cartridge.Open(romPath)
+	if err != nil {
+		return err
+	}
+	settings, err := loadSettings(*configPath, cart)
+	if err != nil {
+		return err
+	}
+	if err := applySettings(flags, settings); err != nil {
+		return err
+	}
+
+	model, err := parseModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if *scale < render.MinScale || *scale > render.MaxScale {
+		// checked here, as -grid takes the scale over from the window, which would otherwise check it
+		return fmt.Errorf("-scale must be between %d and %d, got %d", render.MinScale, render.MaxScale, *scale)
+	}
+	if *frames < 0 {
+		return fmt.Errorf("-frames can't be negative, got %d", *frames)
+	}
+	if *fastForward < 1 {
+		return fmt.Errorf("-fast-forward must be at least 1, got %d", *fastForward)
+	}
+	syncMode := sched.SyncAudio
+	if *syncName != "" {
+		if syncMode, err = parseSync(*syncName); err != nil {
+			return err
+		}
+	}
+	palette, err := render.ParseDMGPalette(*paletteName)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	traceFilter, err := trace.ParseFilter(*tracePCs, *traceBanks, *traceOps)
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{*saveDir, *stateDir, *screenshotDir} {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	if *bootromPath == "" {
+		*bootromPath = settings.BootROMs[nameOfModel(model, cart)]
+	}
+	var bootROM []byte
+	if *bootromPath != "" {
+		b, err := ioutil.ReadFile(*bootromPath)
+		if err != nil {
+			return err
+		}
+		boot, err := gameboy.IdentifyBootROM(*bootromPath, b)
+		if err != nil {
+			return fmt.Errorf("%s: %v", *bootromPath, err)
+		}
+		if model, err = boot.ModelFor(model, cart); err != nil {
+			return fmt.Errorf("%s: %v", *bootromPath, err)
+		}
+		if !boot.Known {
+			log.Printf("%s isn't a Nintendo boot ROM goby knows, running it on the %s", *bootromPath, model)
+		}
+		bootROM = b
+	}
+
+	var effects []render.Effect
+	if *colorCorrect && cart.IsGBC() {
+		effects = append(effects, render.ColorCorrection{})
+	}
+	if *ghosting > 0 {
+		effects = append(effects, &render.Ghosting{Weight: *ghosting})
+	}
+	feOpts := frontendOptions{
+		scale:      *scale,
+		sampleRate: apu.DefaultSampleRate,
+		volume:     settings.Audio.Level(),
+		keys:       settings.Keys,
+		gamepad:    settings.Gamepad,
+		sync:       syncMode,
+	}
+	if settings.Audio.SampleRate != 0 {
+		feOpts.sampleRate = settings.Audio.SampleRate
+	}
+	if *grid {
+		// the grid does the scaling, so the window doesn't scale it again
+		effects = append(effects, render.PixelGrid{Scale: feOpts.scale, Strength: 0.3})
+		feOpts.scale = 1
+	}
+	fe := headlessFrontend()
+	if !*headless {
+		if fe, err = openFrontend(feOpts); err != nil {
+			return fmt.Errorf("opening window: %v", err)
+		}
+	}
+	defer fe.display.Close()
+	if *syncName != "" {
+		// e.g. -headless -sync timer, to run at real speed without a window
+		fe.mode = syncMode
 	}
 
-	gb := dmg{}
-	gb.cart = cartridge.Load(romFile)
-	gb.cpu = cpu.Get()
-	gb.ram = cpu.GetRAM()
-	gb.lcd = render.LCD{}
-	gb.lcd.Init()
-	gb.ppu = render.PPU{}
-	gb.ppu.Init()
+	gb, err := gameboy.Create(cart, model, render.Filter(fe.display, effects...))
+	if err != nil {
+		return fmt.Errorf("%s: %v", romPath, err)
+	}
+	if bootROM != nil {
+		if err := gb.LoadBootROM(bootROM); err != nil {
+			return fmt.Errorf("%s: %v", *bootromPath, err)
+		}
+	}
+	sav := savePath(*saveDir, romPath)
+	if err := loadSave(gb, sav); err != nil {
+		return err
+	}
+	defer func() {
+		if err := writeSave(gb, sav); err != nil {
+			log.Printf("saving the cartridge's RAM: %v", err)
+		}
+	}()
+	gb.PPU.Palette = palette
+	gb.APU.SetOutputRate(feOpts.sampleRate)
+	pacer := sched.New(fe.mode, gb.APU, fe.audio)
+	pacer.Multiplier = *fastForward
+	gb.Audio = pacer
 
-	bootrom, err := os.OpenFile(filepath.Join(cwd, "omitted-assets/dmg_boot.bin"), os.O_RDONLY, 0)
+	var link *serial.TCPLink
+	switch {
+	case *linkListen != "":
+		log.Printf("waiting for link cable on %s", *linkListen)
+		link, err = serial.Listen(*linkListen, gb.Serial, serial.DefaultQuantum)
+	case *linkConnect != "":
+		link, err = serial.Dial(*linkConnect, gb.Serial)
+	case *printerDir != "":
+		prn := &printer.Printer{Dir: *printerDir}
+		gb.Serial.Connect(prn)
+		// a page that couldn't be saved shouldn't stop the game, but shouldn't go unnoticed either
+		defer func() {
+			if err := prn.Err(); err != nil {
+				log.Printf("printer: %v", err)
+			}
+		}()
+	}
+	if err != nil {
+		return fmt.Errorf("link cable: %v", err)
+	}
+	if link != nil {
+		defer link.Close()
+	}
+	for _, in := range fe.inputs {
+		gb.Joypad.AddSource(in)
+	}
+	rewindFrames := int(*rewindSeconds * sched.FrameRate)
+	if *headless {
+		// there's no input to rewind with
+		rewindFrames = 0
+	}
+	history := rewind.New(rewindFrames, rewind.DefaultKeyframeInterval)
+	// whether the newest snapshot in history is of the frame on screen
+	onScreen := false
+	syms, err := symbols.ForROM(romPath)
 	if err != nil {
-		panic(err)
+		log.Printf("not using symbols: %v", err)
+	} else if syms != nil {
+		log.Printf("loaded %d symbols", syms.Len())
+	}
+	// the debugger hooks every instruction, so it's only attached once it's wanted
+	var dbg *debugger.Debugger
+	debugBreak := func() {
+		if dbg == nil {
+			dbg = debugger.New(gb, os.Stdin, os.Stdout)
+			dbg.Symbols = syms
+		}
+		dbg.Break()
+	}
+	if *tracePath != "" {
+		stop, err := startTrace(gb, *tracePath, traceFilter, *traceAnnotate, syms)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+	if *debugStart {
+		debugBreak()
+	}
+	ran := 0
+	for fe.pollEvents() {
+		for _, req := range fe.stateRequests() {
+			handleState(gb, slotPath(*stateDir, romPath, req.slot), req)
+			if !req.save {
+				onScreen = false
+			}
+		}
+		if fe.debugBreak() {
+			debugBreak()
+		}
+		if fe.screenshot() {
+			saveScreenshot(gb, *screenshotDir, romPath)
+		}
+		pacer.FastForward = fe.fastForward()
+		pacer.Rewinding = fe.rewind()
+		if pacer.Rewinding {
+			if err := stepBack(gb, history, onScreen); err != nil {
+				return err
+			}
+			onScreen = false
+			pacer.Wait()
+			continue
+		}
+		for i := 0; i < pacer.Frames(); i++ {
+			if err := runFrame(gb); err == gameboy.ErrBreak {
+				if !dbg.Prompt() {
+					return nil
+				}
+				// finish the frame once the debugger lets go
+				i--
+				continue
+			} else if err != nil {
+				return err
+			}
+			if history.Cap() > 0 {
+				if err := record(gb, history); err != nil {
+					return err
+				}
+				onScreen = true
+			}
+			ran++
+			if *frames > 0 && ran >= *frames {
+				return nil
+			}
+		}
+		pacer.Wait()
 	}
-	cpu.LoadBootrom(bootrom)
-	cpu.GetRAM().LoadCartridge(gb.cart)
-	cpu.InitPCForBootrom()
-	cpu.Run()
+	return nil
 }
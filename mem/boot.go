@@ -0,0 +1,45 @@
+package mem
+
+import "github.com/raidancampbell/goby/state"
+
+// RegBOOT unmaps the boot ROM when written
+const RegBOOT = 0xFF50
+
+// bootOverlay is the boot ROM, sitting over the start of the cartridge until it's done.  It's checked before any
+// handler, so it stays on top of whatever the cartridge maps there.  The CGB's is in two parts, 0000-00FF and
+// 0200-08FF, leaving the cartridge header at 0100-01FF showing through
+type bootOverlay struct {
+	rom     []byte
+	enabled bool
+}
+
+func (b *bootOverlay) Read(addr uint16) byte {
+	return 0xFF
+}
+
+func (b *bootOverlay) Write(addr uint16, val byte) {
+	if val != 0 {
+		b.enabled = false
+	}
+}
+
+//byteAt returns the boot ROM's byte at addr, and whether the boot ROM is mapped there
+func (b *bootOverlay) byteAt(addr uint16) (byte, bool) {
+	if !b.enabled || int(addr) >= len(b.rom) || (addr >= 0x0100 && addr < 0x0200) {
+		return 0, false
+	}
+	return b.rom[addr], true
+}
+
+//LoadBootROM overlays the boot ROM at 0000, until the boot ROM writes to FF50
+func (r *RAM) LoadBootROM(b []byte) {
+	r.boot = bootOverlay{rom: b, enabled: true}
+	r.Map(RegBOOT, RegBOOT, &r.boot)
+}
+
+//Serialize saves or restores all plain memory, e.g. work RAM, OAM and HRAM, and whether the boot ROM is mapped.
+// memory owned by handlers is left to them
+func (r *RAM) Serialize(s *state.Serializer) {
+	s.Bytes(r.mem[:])
+	s.Bool(&r.boot.enabled)
+}
@@ -0,0 +1,294 @@
+package mem
+
+import (
+	"fmt"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/state"
+)
+
+const (
+	romBankSize = 0x4000
+	ramBankSize = 0x2000
+
+	cartRAMStart = 0xA000
+	cartRAMEnd   = 0xBFFF
+)
+
+// MBC is a cartridge's memory bank controller.  It owns 0000-7FFF, where writes set its registers
+// rather than changing ROM, and A000-BFFF, where the cartridge's RAM is
+type MBC interface {
+	Handler
+	state.Serializable
+	//RAM returns the cartridge's RAM, which may be empty
+	RAM() []byte
+	//ROMBank returns the ROM bank switched in at 4000-7FFF
+	ROMBank() int
+}
+
+// cartMemory is what every MBC has in common: the ROM, the RAM, and whether the RAM is enabled
+type cartMemory struct {
+	rom        []byte
+	ram        []byte
+	ramEnabled bool
+}
+
+//romByte reads from the given 16KB ROM bank, wrapping around for banks past the end of the ROM
+func (c *cartMemory) romByte(bank int, addr uint16) byte {
+	return c.rom[c.wrapBank(bank)*romBankSize+int(addr)%romBankSize]
+}
+
+//wrapBank returns the bank that's really read for the given bank number: past the end of the ROM, it wraps around
+func (c *cartMemory) wrapBank(bank int) int {
+	return bank % (len(c.rom) / romBankSize)
+}
+
+//ramOffset returns the offset into RAM for the given 8KB bank, and false if there's nothing there
+func (c *cartMemory) ramOffset(bank int, addr uint16) (int, bool) {
+	if !c.ramEnabled || len(c.ram) == 0 {
+		return 0, false
+	}
+	return (bank*ramBankSize + int(addr-cartRAMStart)) % len(c.ram), true
+}
+
+func (c *cartMemory) readRAM(bank int, addr uint16) byte {
+	if i, ok := c.ramOffset(bank, addr); ok {
+		return c.ram[i]
+	}
+	return 0xFF
+}
+
+func (c *cartMemory) writeRAM(bank int, addr uint16, val byte) {
+	if i, ok := c.ramOffset(bank, addr); ok {
+		c.ram[i] = val
+	}
+}
+
+func (c *cartMemory) RAM() []byte {
+	return c.ram
+}
+
+func (c *cartMemory) serialize(s *state.Serializer) {
+	s.Bytes(c.ram)
+	s.Bool(&c.ramEnabled)
+}
+
+//LoadCartridge maps the cartridge's ROM and RAM into memory, behind the memory bank controller its header asks for
+func (r *RAM) LoadCartridge(rom *cartridge.ROM) (MBC, error) {
+	size, ok := rom.RAMSize()
+	if !ok {
+		return nil, fmt.Errorf("unknown cartridge RAM size code %x", rom.RAMSizeCode())
+	}
+	cart := cartMemory{rom: *rom, ram: make([]byte, size)}
+
+	var mbc MBC
+	switch t := rom.Type(); {
+	case t == 0x00 || t == 0x08 || t == 0x09:
+		mbc = &noMBC{cartMemory: cart}
+	case t >= 0x01 && t <= 0x03:
+		mbc = &mbc1{cartMemory: cart, romBank: 1}
+	case t >= 0x0F && t <= 0x13:
+		mbc = &mbc3{cartMemory: cart, romBank: 1}
+	case t >= 0x19 && t <= 0x1E:
+		mbc = &mbc5{cartMemory: cart, romBank: 1}
+	default:
+		return nil, fmt.Errorf("unsupported cartridge type %x", byte(t))
+	}
+	r.Map(0x0000, 0x7FFF, mbc)
+	r.Map(cartRAMStart, cartRAMEnd, mbc)
+	return mbc, nil
+}
+
+// noMBC is a 32KB ROM, optionally with up to 8KB of RAM, and nothing to switch
+type noMBC struct {
+	cartMemory
+}
+
+func (m *noMBC) Read(addr uint16) byte {
+	if addr < 0x8000 {
+		return m.romByte(int(addr/romBankSize), addr)
+	}
+	if len(m.ram) == 0 {
+		return 0xFF
+	}
+	return m.ram[int(addr-cartRAMStart)%len(m.ram)]
+}
+
+func (m *noMBC) Write(addr uint16, val byte) {
+	if addr >= cartRAMStart && len(m.ram) > 0 {
+		m.ram[int(addr-cartRAMStart)%len(m.ram)] = val
+	}
+}
+
+func (m *noMBC) ROMBank() int {
+	return m.wrapBank(1)
+}
+
+func (m *noMBC) Serialize(s *state.Serializer) {
+	m.serialize(s)
+}
+
+// mbc1 switches up to 2MB of ROM and 32KB of RAM.  The 2-bit upper register either extends the ROM bank,
+// or in mode 1, selects the RAM bank and the bank at 0000-3FFF too
+type mbc1 struct {
+	cartMemory
+	romBank byte // 5 bits. 0 is treated as 1
+	upper   byte // 2 bits
+	mode    byte
+}
+
+func (m *mbc1) Read(addr uint16) byte {
+	switch {
+	case addr < romBankSize:
+		bank := 0
+		if m.mode == 1 {
+			bank = int(m.upper) << 5
+		}
+		return m.romByte(bank, addr)
+	case addr < 0x8000:
+		return m.romByte(int(m.upper)<<5|int(m.romBank), addr)
+	default:
+		return m.readRAM(m.ramBank(), addr)
+	}
+}
+
+func (m *mbc1) ramBank() int {
+	if m.mode == 1 {
+		return int(m.upper)
+	}
+	return 0
+}
+
+func (m *mbc1) Write(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = val&0x0F == 0x0A
+	case addr < 0x4000:
+		m.romBank = val & 0x1F
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr < 0x6000:
+		m.upper = val & 0x03
+	case addr < 0x8000:
+		m.mode = val & 0x01
+	default:
+		m.writeRAM(m.ramBank(), addr, val)
+	}
+}
+
+func (m *mbc1) ROMBank() int {
+	return m.wrapBank(int(m.upper)<<5 | int(m.romBank))
+}
+
+func (m *mbc1) Serialize(s *state.Serializer) {
+	m.serialize(s)
+	s.Uint8(&m.romBank)
+	s.Uint8(&m.upper)
+	s.Uint8(&m.mode)
+}
+
+// mbc3 switches up to 2MB of ROM and 32KB of RAM.  Selecting banks 08-0C instead of RAM shows the real time clock,
+// whose registers are kept but don't tick
+type mbc3 struct {
+	cartMemory
+	romBank byte // 7 bits. 0 is treated as 1
+	ramBank byte // 0-3 for RAM, 08-0C for the clock
+	rtc     [5]byte
+	latch   byte // writing 00 then 01 latches the clock
+}
+
+func (m *mbc3) Read(addr uint16) byte {
+	switch {
+	case addr < romBankSize:
+		return m.romByte(0, addr)
+	case addr < 0x8000:
+		return m.romByte(int(m.romBank), addr)
+	case m.ramBank >= 0x08 && m.ramBank <= 0x0C:
+		if !m.ramEnabled {
+			return 0xFF
+		}
+		return m.rtc[m.ramBank-0x08]
+	default:
+		return m.readRAM(int(m.ramBank&0x03), addr)
+	}
+}
+
+func (m *mbc3) Write(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = val&0x0F == 0x0A
+	case addr < 0x4000:
+		m.romBank = val & 0x7F
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr < 0x6000:
+		m.ramBank = val & 0x0F
+	case addr < 0x8000:
+		m.latch = val
+	case m.ramBank >= 0x08 && m.ramBank <= 0x0C:
+		if m.ramEnabled {
+			m.rtc[m.ramBank-0x08] = val
+		}
+	default:
+		m.writeRAM(int(m.ramBank&0x03), addr, val)
+	}
+}
+
+func (m *mbc3) ROMBank() int {
+	return m.wrapBank(int(m.romBank))
+}
+
+func (m *mbc3) Serialize(s *state.Serializer) {
+	m.serialize(s)
+	s.Uint8(&m.romBank)
+	s.Uint8(&m.ramBank)
+	s.Bytes(m.rtc[:])
+	s.Uint8(&m.latch)
+}
+
+// mbc5 switches up to 8MB of ROM and 128KB of RAM.  Unlike the others, ROM bank 0 can be selected at 4000-7FFF
+type mbc5 struct {
+	cartMemory
+	romBank uint16 // 9 bits
+	ramBank byte   // 4 bits
+}
+
+func (m *mbc5) Read(addr uint16) byte {
+	switch {
+	case addr < romBankSize:
+		return m.romByte(0, addr)
+	case addr < 0x8000:
+		return m.romByte(int(m.romBank), addr)
+	default:
+		return m.readRAM(int(m.ramBank), addr)
+	}
+}
+
+func (m *mbc5) Write(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = val&0x0F == 0x0A
+	case addr < 0x3000:
+		m.romBank = m.romBank&0x100 | uint16(val)
+	case addr < 0x4000:
+		m.romBank = m.romBank&0xFF | uint16(val&0x01)<<8
+	case addr < 0x6000:
+		m.ramBank = val & 0x0F
+	case addr < 0x8000:
+		// nothing here on MBC5
+	default:
+		m.writeRAM(int(m.ramBank), addr, val)
+	}
+}
+
+func (m *mbc5) ROMBank() int {
+	return m.wrapBank(int(m.romBank))
+}
+
+func (m *mbc5) Serialize(s *state.Serializer) {
+	m.serialize(s)
+	s.Uint16(&m.romBank)
+	s.Uint8(&m.ramBank)
+}
@@ -0,0 +1,130 @@
+package mem
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//bankedROM is a cartridge of the given type and size, whose banks each start with their bank number, little endian
+func bankedROM(typ byte, banks int, ramCode byte) *cartridge.ROM {
+	rom := make(cartridge.ROM, banks*romBankSize)
+	for bank := 0; bank < banks; bank++ {
+		rom[bank*romBankSize] = byte(bank)
+		rom[bank*romBankSize+1] = byte(bank >> 8)
+	}
+	rom[0x0147], rom[0x0149] = typ, ramCode
+	return &rom
+}
+
+//load maps the cartridge into fresh memory
+func load(t *testing.T, rom *cartridge.ROM) (*RAM, MBC) {
+	r := &RAM{}
+	mbc, err := r.LoadCartridge(rom)
+	require.NoError(t, err)
+	return r, mbc
+}
+
+//bankAt returns the number of the ROM bank switched in at addr, which must be at the start of 0000-3FFF or 4000-7FFF
+func bankAt(r *RAM, addr uint16) int {
+	return int(r.Peek(addr)) | int(r.Peek(addr+1))<<8
+}
+
+func TestMBC_bank0SelectsBank1(t *testing.T) {
+	for _, typ := range []byte{0x01, 0x13} {
+		r, mbc := load(t, bankedROM(typ, 8, 0))
+		assert.Equal(t, 1, bankAt(r, 0x4000), "bank 1 is switched in at startup")
+		r.WriteByte(0x2000, 3)
+		assert.Equal(t, 3, bankAt(r, 0x4000))
+		r.WriteByte(0x2000, 0)
+		assert.Equal(t, 1, bankAt(r, 0x4000), "type %02x", typ)
+		assert.Equal(t, 1, mbc.ROMBank())
+	}
+
+	// MBC1 only looks at 5 bits, so 20 is 00 too
+	r, _ := load(t, bankedROM(0x01, 8, 0))
+	r.WriteByte(0x2000, 0x20)
+	assert.Equal(t, 1, bankAt(r, 0x4000))
+
+	// MBC5 can switch bank 0 in
+	r, _ = load(t, bankedROM(0x19, 8, 0))
+	r.WriteByte(0x2000, 0)
+	assert.Equal(t, 0, bankAt(r, 0x4000))
+}
+
+func TestMBC1_mode1(t *testing.T) {
+	r, mbc := load(t, bankedROM(0x03, 64, 0x03))
+	r.WriteByte(0x2000, 0x02)
+	r.WriteByte(0x4000, 0x01)
+
+	// in mode 0, the upper bits only extend the bank at 4000-7FFF
+	assert.Equal(t, 0x22, bankAt(r, 0x4000))
+	assert.Equal(t, 0x22, mbc.ROMBank())
+	assert.Equal(t, 0x00, bankAt(r, 0x0000))
+
+	// in mode 1, they select the bank at 0000-3FFF too, and the RAM bank
+	r.WriteByte(0x6000, 0x01)
+	assert.Equal(t, 0x22, bankAt(r, 0x4000))
+	assert.Equal(t, 0x20, bankAt(r, 0x0000))
+
+	r.WriteByte(0x0000, 0x0A)
+	r.WriteByte(0xA000, 0x11)
+	r.WriteByte(0x4000, 0x00)
+	assert.Equal(t, byte(0x00), r.Peek(0xA000), "RAM bank 0 is separate from bank 1")
+	r.WriteByte(0x4000, 0x01)
+	assert.Equal(t, byte(0x11), r.Peek(0xA000))
+	assert.Equal(t, byte(0x11), mbc.RAM()[ramBankSize])
+}
+
+func TestMBC5_9bitBank(t *testing.T) {
+	r, mbc := load(t, bankedROM(0x19, 512, 0))
+	r.WriteByte(0x2000, 0x05)
+	r.WriteByte(0x3000, 0x01)
+	assert.Equal(t, 0x105, bankAt(r, 0x4000))
+	assert.Equal(t, 0x105, mbc.ROMBank())
+
+	// the low 8 bits leave the 9th alone
+	r.WriteByte(0x2000, 0xFF)
+	assert.Equal(t, 0x1FF, bankAt(r, 0x4000))
+	r.WriteByte(0x3000, 0x00)
+	assert.Equal(t, 0x0FF, bankAt(r, 0x4000))
+}
+
+func TestMBC_ramEnable(t *testing.T) {
+	for _, typ := range []byte{0x03, 0x13, 0x1B} {
+		r, mbc := load(t, bankedROM(typ, 4, 0x02))
+		r.WriteByte(0xA000, 0x42)
+		assert.Equal(t, byte(0xFF), r.Peek(0xA000), "type %02x: RAM reads FF until it's enabled", typ)
+		assert.Equal(t, byte(0x00), mbc.RAM()[0], "type %02x: writes are ignored until it's enabled", typ)
+
+		r.WriteByte(0x0000, 0x0A)
+		r.WriteByte(0xA000, 0x42)
+		assert.Equal(t, byte(0x42), r.Peek(0xA000), "type %02x", typ)
+
+		// only the low nibble counts
+		r.WriteByte(0x0000, 0x1A)
+		assert.Equal(t, byte(0x42), r.Peek(0xA000), "type %02x", typ)
+
+		r.WriteByte(0x0000, 0x00)
+		assert.Equal(t, byte(0xFF), r.Peek(0xA000), "type %02x", typ)
+		assert.Equal(t, byte(0x42), mbc.RAM()[0], "type %02x: disabling RAM keeps what's in it", typ)
+	}
+}
+
+func TestMBC_bankWrap(t *testing.T) {
+	for _, typ := range []byte{0x01, 0x13, 0x19} {
+		r, mbc := load(t, bankedROM(typ, 4, 0))
+		r.WriteByte(0x2000, 6)
+		assert.Equal(t, 2, bankAt(r, 0x4000), "type %02x: bank 6 of 4 wraps to 2", typ)
+		assert.Equal(t, 2, mbc.ROMBank(), "type %02x", typ)
+	}
+
+	// in mode 1 the upper bits wrap at 0000-3FFF too
+	r, _ := load(t, bankedROM(0x01, 32, 0))
+	r.WriteByte(0x4000, 0x01)
+	r.WriteByte(0x6000, 0x01)
+	assert.Equal(t, 0, bankAt(r, 0x0000))
+	assert.Equal(t, 1, bankAt(r, 0x4000))
+}
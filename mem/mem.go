@@ -2,27 +2,61 @@ package mem
 
 import (
 	"fmt"
-	"github.com/raidancampbell/goby/cartridge"
 )
 
-type RAM [0xFFFF]byte
+// RAM is the memory bus: plain memory, with hardware mapped over parts of it.  Each Game Boy has its own
+type RAM struct {
+	mem [0x10000]byte
+	// handlers is indexed by address.  a nil entry means the address is plain memory
+	handlers [0x10000]Handler
+	boot     bootOverlay
 
-func (r *RAM) doWrite(addr uint16, data []byte) {
-	if addr < 0x8000 {
-		panic(fmt.Sprintf("attempted write %x to address %x", data, addr))
+	// OnAccess, if set, sees every read and write through ReadByte and WriteByte, e.g. for debugger watchpoints.
+	// those are the CPU's accesses: the rest of the hardware goes through Peek and Poke, so it isn't seen
+	OnAccess func(addr uint16, val byte, write bool)
+}
+
+// Handler owns one or more memory-mapped hardware registers, intercepting reads and writes to them
+// e.g. the joypad register at FF00 is computed from the buttons held, rather than stored
+type Handler interface {
+	Read(addr uint16) byte
+	Write(addr uint16, val byte)
+}
+
+//Map routes all reads and writes in the given address range (inclusive) to the given handler
+// mapping a nil handler returns the range to plain memory
+func (r *RAM) Map(from, to uint16, h Handler) {
+	for addr := int(from); addr <= int(to); addr++ {
+		r.handlers[addr] = h
 	}
+}
+
+const (
+	// interrupt bits, in priority order.  these are the bit indices into IF and IE
+	IntVBlank  = 0
+	IntLCDStat = 1
+	IntTimer   = 2
+	IntSerial  = 3
+	IntJoypad  = 4
+
+	RegIF = 0xFF0F // interrupt flag: which interrupts have been requested
+	RegIE = 0xFFFF // interrupt enable: which interrupts may be serviced
+)
+
+//RequestInterrupt sets the given interrupt's bit in IF.  The CPU will service it once it's enabled
+func (r *RAM) RequestInterrupt(bit uint8) {
+	r.mem[RegIF] |= 1 << bit
+}
+
+func (r *RAM) doWrite(addr uint16, data []byte) {
 	//TODO: pass off responsibility to the different memory regions
 	//i.e. framebuffer, OAM, etc...
 	for i, b := range data {
 		offset := uint16(i)
-		r[addr+offset] = b
-	}
-}
-
-// LoadCartridge loads the cartridge's ROM into RAM
-func (r *RAM) LoadCartridge(rom *cartridge.ROM) {
-	for i := 0; i < 0x8000; i++ {
-		r[0x100 + i] = (*rom)[i]
+		if r.OnAccess != nil {
+			r.OnAccess(addr+offset, b, true)
+		}
+		r.Poke(addr+offset, b)
 	}
 }
 
@@ -36,5 +70,34 @@ func (r *RAM) WriteByte(addr uint16, val byte) {
 // this allows the various memory controllers to control their regions
 // e.g. hardware IO registers, cartridge RAM, OAM, VRAM, etc...
 func (r *RAM) ReadByte(addr uint16) byte {
-	return r[addr]
-}
\ No newline at end of file
+	val := r.Peek(addr)
+	if r.OnAccess != nil {
+		r.OnAccess(addr, val, false)
+	}
+	return val
+}
+
+//Peek reads memory the same way as ReadByte, but unseen by OnAccess.  It's for debuggers looking at memory,
+// and hardware other than the CPU, e.g. the PPU reading LCDC
+func (r *RAM) Peek(addr uint16) byte {
+	if b, ok := r.boot.byteAt(addr); ok {
+		return b
+	}
+	if h := r.handlers[addr]; h != nil {
+		return h.Read(addr)
+	}
+	return r.mem[addr]
+}
+
+//Poke writes memory the same way as WriteByte, but unseen by OnAccess.  It's for hardware other than the CPU,
+// e.g. the PPU updating LY, or DMA copying to OAM
+func (r *RAM) Poke(addr uint16, val byte) {
+	if h := r.handlers[addr]; h != nil {
+		h.Write(addr, val)
+		return
+	}
+	if addr < 0x8000 {
+		panic(fmt.Sprintf("attempted write %x to address %x", val, addr))
+	}
+	r.mem[addr] = val
+}
@@ -0,0 +1,54 @@
+package mem
+
+import "github.com/raidancampbell/goby/state"
+
+const (
+	RegSVBK = 0xFF70 // CGB WRAM bank select
+
+	wramBankStart = 0xD000
+	wramBankEnd   = 0xDFFF
+)
+
+// WRAMBanks is CGB work RAM: C000-CFFF is always bank 0, and D000-DFFF is switchable between banks 1-7 through SVBK
+type WRAMBanks struct {
+	banks [8][0x1000]byte
+	svbk  byte
+}
+
+//MapWRAMBanks installs switchable work RAM banks, as found on the CGB
+func (r *RAM) MapWRAMBanks() *WRAMBanks {
+	w := &WRAMBanks{svbk: 1}
+	r.Map(wramBankStart, wramBankEnd, w)
+	r.Map(RegSVBK, RegSVBK, w)
+	return w
+}
+
+//bank returns the bank mapped at D000-DFFF.  Selecting bank 0 selects bank 1
+func (w *WRAMBanks) bank() byte {
+	if w.svbk == 0 {
+		return 1
+	}
+	return w.svbk
+}
+
+func (w *WRAMBanks) Read(addr uint16) byte {
+	if addr == RegSVBK {
+		return 0xF8 | w.svbk
+	}
+	return w.banks[w.bank()][addr-wramBankStart]
+}
+
+func (w *WRAMBanks) Write(addr uint16, val byte) {
+	if addr == RegSVBK {
+		w.svbk = val & 0x07
+		return
+	}
+	w.banks[w.bank()][addr-wramBankStart] = val
+}
+
+func (w *WRAMBanks) Serialize(s *state.Serializer) {
+	for i := range w.banks {
+		s.Bytes(w.banks[i][:])
+	}
+	s.Uint8(&w.svbk)
+}
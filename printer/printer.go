@@ -0,0 +1,308 @@
+package printer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// packet commands
+const (
+	cmdInit   = 0x01
+	cmdPrint  = 0x02
+	cmdData   = 0x04
+	cmdStatus = 0x0F
+)
+
+// status bits, reported in the last byte of every packet
+const (
+	statusChecksumErr = 0x01
+	statusPrinting    = 0x02
+	statusFull        = 0x04
+	statusUnprocessed = 0x08
+)
+
+const (
+	magic1 = 0x88
+	magic2 = 0x33
+	// alive is the printer's answer to the first byte after the checksum: it identifies a printer on the cable
+	alive = 0x81
+
+	Width = 160
+	// bandSize is one data packet's worth of image: 2 rows of 20 tiles, 16 bytes per tile
+	bandSize = 40 * 16
+	// maxData is the printer's buffer: 9 bands, which is a full 160x144 screen
+	maxData = 9 * bandSize
+	// marginRows is how many blank pixel rows one unit of margin feeds
+	marginRows = 8
+	// printInquiries is how many status packets report the printer busy after it's told to print
+	printInquiries = 4
+)
+
+// shades match the DMG's grays, lightest first
+var shades = color.Palette{
+	color.Gray{Y: 0xFF},
+	color.Gray{Y: 0xA8},
+	color.Gray{Y: 0x54},
+	color.Gray{Y: 0x00},
+}
+
+// packet field being received
+type state int
+
+const (
+	stMagic1 state = iota
+	stMagic2
+	stCommand
+	stCompression
+	stLengthLo
+	stLengthHi
+	stData
+	stChecksumLo
+	stChecksumHi
+	stAlive
+	stStatus
+)
+
+// Printer is a Game Boy Printer on the other end of the link cable.  The Game Boy drives the clock,
+// sending packets of: magic bytes 88 33, command, compression flag, little endian length, data,
+// little endian checksum, then two zeros, which the printer answers with 81 and its status.
+//
+// finished pages are written as PNGs to Dir if it's set, and handed to OnPrint if that's set
+type Printer struct {
+	Dir     string
+	OnPrint func(page *image.Paletted)
+
+	st          state
+	command     byte
+	compressed  bool
+	length      int
+	packet      []byte
+	checksum    uint16
+	sum         uint16
+	status      byte
+	busy        int    // status inquiries left until the current print finishes
+	data        []byte // decompressed image data, waiting to be printed
+	page        []byte // shade per pixel of the page so far, Width to a row
+	pagesOutput int
+	err         error
+}
+
+//Err returns the last error encountered writing a page
+func (p *Printer) Err() error {
+	return p.err
+}
+
+//Transfer handles one byte of a packet, returning the printer's answer
+func (p *Printer) Transfer(out byte) byte {
+	switch p.st {
+	case stMagic1:
+		if out == magic1 {
+			p.st = stMagic2
+		}
+	case stMagic2:
+		p.st = stMagic1
+		if out == magic2 {
+			p.st = stCommand
+		}
+	case stCommand:
+		p.command = out
+		p.sum = uint16(out)
+		p.st = stCompression
+	case stCompression:
+		p.compressed = out&0x01 != 0
+		p.sum += uint16(out)
+		p.st = stLengthLo
+	case stLengthLo:
+		p.length = int(out)
+		p.sum += uint16(out)
+		p.st = stLengthHi
+	case stLengthHi:
+		p.length |= int(out) << 8
+		p.sum += uint16(out)
+		p.packet = p.packet[:0]
+		p.st = stData
+		if p.length == 0 {
+			p.st = stChecksumLo
+		}
+	case stData:
+		p.packet = append(p.packet, out)
+		p.sum += uint16(out)
+		if len(p.packet) == p.length {
+			p.st = stChecksumLo
+		}
+	case stChecksumLo:
+		p.checksum = uint16(out)
+		p.st = stChecksumHi
+	case stChecksumHi:
+		p.checksum |= uint16(out) << 8
+		p.handlePacket()
+		p.st = stAlive
+	case stAlive:
+		p.st = stStatus
+		return alive
+	case stStatus:
+		p.st = stMagic1
+		return p.status
+	}
+	return 0x00
+}
+
+func (p *Printer) handlePacket() {
+	if p.checksum != p.sum {
+		p.status |= statusChecksumErr
+		return
+	}
+	p.status &^= statusChecksumErr
+
+	switch p.command {
+	case cmdInit:
+		p.data = p.data[:0]
+		p.busy = 0
+		p.status = 0
+	case cmdData:
+		chunk := p.packet
+		if p.compressed {
+			chunk = decompress(chunk)
+		}
+		p.data = append(p.data, chunk...)
+		if len(p.data) > maxData {
+			p.data = p.data[:maxData]
+		}
+		if len(p.data) > 0 {
+			p.status |= statusUnprocessed
+		}
+		if len(p.data) == maxData {
+			p.status |= statusFull
+		}
+	case cmdPrint:
+		if len(p.packet) < 4 {
+			return
+		}
+		// sheets, margins, palette, exposure.  sheets and exposure make no difference to the output
+		p.print(p.packet[1], p.packet[2])
+		p.busy = printInquiries
+		p.status = (p.status | statusPrinting) &^ statusUnprocessed
+	case cmdStatus:
+		if p.busy > 0 {
+			p.busy--
+			if p.busy == 0 {
+				p.status &^= statusPrinting | statusFull
+			}
+		}
+	}
+}
+
+//decompress expands the printer's run length encoding.  Each control byte either has bit 7 set,
+// repeating the next byte (control&7F)+2 times, or is followed by control+1 literal bytes
+func decompress(in []byte) []byte {
+	var out []byte
+	for i := 0; i < len(in); {
+		ctrl := in[i]
+		i++
+		if ctrl&0x80 != 0 {
+			if i >= len(in) {
+				break
+			}
+			for n := 0; n < int(ctrl&0x7F)+2; n++ {
+				out = append(out, in[i])
+			}
+			i++
+			continue
+		}
+		end := i + int(ctrl) + 1
+		if end > len(in) {
+			end = len(in)
+		}
+		out = append(out, in[i:end]...)
+		i = end
+	}
+	return out
+}
+
+//print renders the buffered tiles onto the page.  The high nibble of margins is the feed before printing,
+// and the low nibble the feed after.  A page is only finished once there's a feed after it,
+// so a picture sent as several prints with no margins between them comes out as one page
+func (p *Printer) print(margins, palette byte) {
+	p.feed(int(margins>>4) * marginRows)
+
+	bands := len(p.data) / bandSize
+	for band := 0; band < bands; band++ {
+		for row := 0; row < 16; row++ {
+			line := make([]byte, Width)
+			tileRow := row / 8
+			for tx := 0; tx < 20; tx++ {
+				tile := p.data[band*bandSize+(tileRow*20+tx)*16:]
+				lo, hi := tile[(row%8)*2], tile[(row%8)*2+1]
+				for col := 0; col < 8; col++ {
+					bit := uint(7 - col)
+					colorID := ((hi>>bit)&1)<<1 | (lo>>bit)&1
+					line[tx*8+col] = (palette >> (colorID * 2)) & 0x03
+				}
+			}
+			p.page = append(p.page, line...)
+		}
+	}
+	p.data = p.data[:0]
+
+	if after := int(margins & 0x0F); after > 0 {
+		p.feed(after * marginRows)
+		p.finishPage()
+	}
+}
+
+func (p *Printer) feed(rows int) {
+	p.page = append(p.page, make([]byte, rows*Width)...)
+}
+
+func (p *Printer) finishPage() {
+	if len(p.page) == 0 {
+		return
+	}
+	img := image.NewPaletted(image.Rect(0, 0, Width, len(p.page)/Width), shades)
+	copy(img.Pix, p.page)
+	p.page = nil
+	p.pagesOutput++
+
+	if p.OnPrint != nil {
+		p.OnPrint(img)
+	}
+	if p.Dir != "" {
+		if err := writePNG(filepath.Join(p.Dir, fmt.Sprintf("print-%03d.png", p.pagesOutput)), img); err != nil {
+			p.err = err
+		}
+	}
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+//Packet builds a packet the way a game sends it, including the two trailing bytes the printer answers.
+// it's used by tests, and is handy for driving the printer without a game
+func Packet(command byte, compressed bool, data []byte) []byte {
+	pkt := []byte{magic1, magic2, command, 0, 0, 0}
+	if compressed {
+		pkt[3] = 1
+	}
+	binary.LittleEndian.PutUint16(pkt[4:], uint16(len(data)))
+	pkt = append(pkt, data...)
+	var sum uint16
+	for _, b := range pkt[2:] {
+		sum += uint16(b)
+	}
+	pkt = append(pkt, byte(sum), byte(sum>>8), 0, 0)
+	return pkt
+}
@@ -0,0 +1,63 @@
+package printer
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//send feeds a packet to the printer, returning its answers to the two trailing bytes
+func send(p *Printer, pkt []byte) (byte, byte) {
+	var replies []byte
+	for _, b := range pkt {
+		replies = append(replies, p.Transfer(b))
+	}
+	return replies[len(replies)-2], replies[len(replies)-1]
+}
+
+func TestPrinter_printsPage(t *testing.T) {
+	var pages []*image.Paletted
+	p := &Printer{OnPrint: func(page *image.Paletted) { pages = append(pages, page) }}
+
+	alive, status := send(p, Packet(cmdInit, false, nil))
+	assert.Equal(t, byte(0x81), alive)
+	assert.Equal(t, byte(0x00), status)
+
+	// one band where every pixel is color 3, run length encoded as a single run of 640 0xFF bytes
+	var band []byte
+	for n := 0; n < bandSize; n += 128 {
+		band = append(band, 0x80|126, 0xFF)
+	}
+	_, status = send(p, Packet(cmdData, true, band))
+	assert.Equal(t, byte(statusUnprocessed), status)
+
+	// no margin before, one after, with the standard palette
+	_, status = send(p, Packet(cmdPrint, false, []byte{1, 0x01, 0xE4, 0x40}))
+	assert.Equal(t, byte(statusPrinting), status&statusPrinting)
+	require.Len(t, pages, 1)
+
+	page := pages[0]
+	assert.Equal(t, image.Rect(0, 0, Width, 16+marginRows), page.Bounds())
+	assert.Equal(t, uint8(3), page.ColorIndexAt(0, 0))
+	assert.Equal(t, uint8(3), page.ColorIndexAt(Width-1, 15))
+	assert.Equal(t, uint8(0), page.ColorIndexAt(0, 16))
+
+	for i := 0; i < printInquiries; i++ {
+		_, status = send(p, Packet(cmdStatus, false, nil))
+	}
+	assert.Equal(t, byte(0x00), status)
+}
+
+func TestPrinter_checksumError(t *testing.T) {
+	p := &Printer{}
+	pkt := Packet(cmdData, false, []byte{1, 2, 3})
+	pkt[len(pkt)-4]++
+	_, status := send(p, pkt)
+	assert.Equal(t, byte(statusChecksumErr), status)
+}
+
+func Test_decompress(t *testing.T) {
+	assert.Equal(t, []byte{1, 2, 3, 9, 9, 9, 9}, decompress([]byte{0x02, 1, 2, 3, 0x82, 9}))
+}
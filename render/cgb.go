@@ -0,0 +1,85 @@
+package render
+
+// ColorFrameBuffer is the visible screen in CGB mode, where the value at an element is 15-bit RGB:
+// 5 bits each of red (bits 0-4), green (bits 5-9) and blue (bits 10-14), the same layout as palette RAM
+type ColorFrameBuffer [144][160]uint16
+
+const (
+	regVBK  = 0xFF4F // VRAM bank
+	regBCPS = 0xFF68 // BG palette index
+	regBCPD = 0xFF69 // BG palette data
+	regOCPS = 0xFF6A // OBJ palette index
+	regOCPD = 0xFF6B // OBJ palette data
+
+	autoIncrement = 0x80
+)
+
+// paletteRAM holds 8 palettes of 4 colors each, 2 bytes per color, little endian.
+// it's accessed through an index register and a data register.  if bit 7 of the index is set,
+// the index advances after every write to the data register
+type paletteRAM struct {
+	data  [64]byte
+	index byte // bits 0-5 address data, bit 7 is auto-increment
+}
+
+func (r *paletteRAM) read() byte {
+	return r.data[r.index&0x3F]
+}
+
+func (r *paletteRAM) write(val byte) {
+	r.data[r.index&0x3F] = val
+	if r.index&autoIncrement != 0 {
+		r.index = autoIncrement | (r.index+1)&0x3F
+	}
+}
+
+//color returns the 15-bit color for the given palette and color ID
+func (r *paletteRAM) color(palette, colorID uint8) uint16 {
+	i := int(palette&0x07)*8 + int(colorID)*2
+	return uint16(r.data[i]) | uint16(r.data[i+1])<<8&0x7F00
+}
+
+//setColor stores a 15-bit color for the given palette and color ID
+func (r *paletteRAM) setColor(palette, colorID uint8, rgb uint16) {
+	i := int(palette&0x07)*8 + int(colorID)*2
+	r.data[i] = byte(rgb)
+	r.data[i+1] = byte(rgb>>8) & 0x7F
+}
+
+func (p *PPU) readPalette(addr uint16) byte {
+	switch addr {
+	case regBCPS:
+		return p.bgPalettes.index | 0x40
+	case regBCPD:
+		return p.bgPalettes.read()
+	case regOCPS:
+		return p.obPalettes.index | 0x40
+	case regOCPD:
+		return p.obPalettes.read()
+	}
+	return 0xFF
+}
+
+func (p *PPU) writePalette(addr uint16, val byte) {
+	switch addr {
+	case regBCPS:
+		p.bgPalettes.index = val & (autoIncrement | 0x3F)
+	case regBCPD:
+		p.bgPalettes.write(val)
+	case regOCPS:
+		p.obPalettes.index = val & (autoIncrement | 0x3F)
+	case regOCPD:
+		p.obPalettes.write(val)
+	}
+}
+
+//RGB15ToARGB expands a 15-bit CGB color to an SDL color, scaling each 5-bit channel to 8 bits
+func RGB15ToARGB(rgb uint16) uint32 {
+	r := uint32(rgb & 0x1F)
+	g := uint32(rgb>>5) & 0x1F
+	b := uint32(rgb>>10) & 0x1F
+	r = r<<3 | r>>2
+	g = g<<3 | g>>2
+	b = b<<3 | b>>2
+	return 0xFF000000 | r<<16 | g<<8 | b
+}
@@ -0,0 +1,57 @@
+package render
+
+// CompatPalette colorizes a DMG game on CGB hardware: BGP, OBP0 and OBP1 pick shades from these
+// instead of from gray.  colors are 24-bit RGB, as listed in most references
+type CompatPalette struct {
+	Name string
+	BG   [4]uint32
+	OBJ0 [4]uint32
+	OBJ1 [4]uint32
+}
+
+// the palettes the CGB boot ROM offers for manual selection, by holding a direction and optionally A or B
+var (
+	PaletteBrown     = CompatPalette{"brown", brown, brown, brown}
+	PaletteRed       = CompatPalette{"red", red, green, blue}
+	PaletteDarkBrown = CompatPalette{"dark brown", [4]uint32{0xFFE6C5, 0xCE9C84, 0x846B29, 0x5A3108}, brown, brown}
+	PalettePastel    = CompatPalette{"pastel mix", pastel, pastel, pastel}
+	PaletteOrange    = CompatPalette{"orange", orange, orange, orange}
+	PaletteYellow    = CompatPalette{"yellow", [4]uint32{0xFFFFFF, 0xFFFF00, 0x7B4A00, 0x000000}, blue, green}
+	PaletteBlue      = CompatPalette{"blue", blue, red, green}
+	PaletteDarkBlue  = CompatPalette{"dark blue", [4]uint32{0xFFFFFF, 0x8C8CDE, 0x52528C, 0x000000}, red, brown}
+	PaletteGray      = CompatPalette{"grayscale", gray, gray, gray}
+	PaletteGreen     = CompatPalette{"green", lime, lime, lime}
+	PaletteDarkGreen = CompatPalette{"dark green", [4]uint32{0xFFFFFF, 0x7BFF31, 0x0063C5, 0x000000}, red, red}
+	PaletteInverted  = CompatPalette{"inverted", inverted, inverted, inverted}
+)
+
+var (
+	brown    = [4]uint32{0xFFFFFF, 0xFFAD63, 0x843100, 0x000000}
+	red      = [4]uint32{0xFFFFFF, 0xFF8484, 0x943A3A, 0x000000}
+	green    = [4]uint32{0xFFFFFF, 0x7BFF31, 0x008400, 0x000000}
+	blue     = [4]uint32{0xFFFFFF, 0x63A5FF, 0x0000FF, 0x000000}
+	pastel   = [4]uint32{0xFFFFA5, 0xFF9494, 0x9494FF, 0x000000}
+	orange   = [4]uint32{0xFFFFFF, 0xFFFF00, 0xFF0000, 0x000000}
+	gray     = [4]uint32{0xFFFFFF, 0xA5A5A5, 0x525252, 0x000000}
+	lime     = [4]uint32{0xFFFFFF, 0x52FF00, 0xFF4200, 0x000000}
+	inverted = [4]uint32{0x000000, 0x008484, 0xFFDE00, 0xFFFFFF}
+)
+
+//SetCompatPalette switches a DMG mode PPU to color, the way a CGB runs DMG games.
+// the colors are loaded into BG palette 0 and OBJ palettes 0 and 1, which BGP, OBP0 and OBP1 then index into
+func (p *PPU) SetCompatPalette(pal CompatPalette) {
+	p.compat = true
+	for i := uint8(0); i < 4; i++ {
+		p.bgPalettes.setColor(0, i, RGB24ToRGB15(pal.BG[i]))
+		p.obPalettes.setColor(0, i, RGB24ToRGB15(pal.OBJ0[i]))
+		p.obPalettes.setColor(1, i, RGB24ToRGB15(pal.OBJ1[i]))
+	}
+}
+
+//RGB24ToRGB15 keeps the top 5 bits of each 8-bit channel, packed in palette RAM's layout
+func RGB24ToRGB15(rgb uint32) uint16 {
+	r := uint16(rgb>>19) & 0x1F
+	g := uint16(rgb>>11) & 0x1F
+	b := uint16(rgb>>3) & 0x1F
+	return b<<10 | g<<5 | r
+}
@@ -0,0 +1,15 @@
+package render
+
+// the window scales the LCD supports
+const (
+	MinScale = 1
+	MaxScale = 8
+)
+
+// Display is where finished frames end up, e.g. an SDL window or an in-memory buffer
+type Display interface {
+	//Present shows the given frame.  The frame is not reused by the caller, so it may be retained
+	Present(f *Frame) error
+	//Close releases any resources held by the display
+	Close() error
+}
@@ -0,0 +1,110 @@
+package render
+
+// Effect post-processes a finished frame, the way a shader would, but on the CPU
+type Effect interface {
+	//Apply returns the processed frame.  It may modify and return the given frame
+	Apply(f *Frame) *Frame
+}
+
+// Filtered is a Display that runs every frame through a chain of effects before presenting it
+type Filtered struct {
+	Display
+	Effects []Effect
+}
+
+//Filter wraps a display with the given effects, applied in order. with no effects, the display is returned as-is
+func Filter(d Display, effects ...Effect) Display {
+	if len(effects) == 0 {
+		return d
+	}
+	return &Filtered{Display: d, Effects: effects}
+}
+
+func (d *Filtered) Present(f *Frame) error {
+	for _, e := range d.Effects {
+		f = e.Apply(f)
+	}
+	return d.Display.Present(f)
+}
+
+// Ghosting blends each frame with the ones before it, like the slow response of the original LCDs.
+// games that flicker objects every other frame to show more of them rely on this to look solid
+type Ghosting struct {
+	// Weight is how much of the previous frame shows through, from 0 (none) to 1 (frozen). 0.5 is typical
+	Weight float64
+	prev   *Frame
+}
+
+func (g *Ghosting) Apply(f *Frame) *Frame {
+	if g.prev == nil || g.prev.Width != f.Width || g.prev.Height != f.Height {
+		g.prev = NewFrame(f.Width, f.Height)
+		copy(g.prev.Pix, f.Pix)
+		return f
+	}
+	keep := uint32(g.Weight * 256)
+	for i, cur := range f.Pix {
+		f.Pix[i] = blend(g.prev.Pix[i], cur, keep)
+	}
+	copy(g.prev.Pix, f.Pix)
+	return f
+}
+
+//blend mixes two ARGB colors, taking weight/256 of a and the rest of b
+func blend(a, b, weight uint32) uint32 {
+	out := uint32(0xFF000000)
+	for shift := uint(0); shift < 24; shift += 8 {
+		ca, cb := a>>shift&0xFF, b>>shift&0xFF
+		out |= (ca*weight + cb*(256-weight)) >> 8 << shift
+	}
+	return out
+}
+
+// PixelGrid scales the frame up and darkens the edges of each pixel, so the gaps between the LCD's pixels show
+type PixelGrid struct {
+	// Scale is how many output pixels each pixel becomes, in each direction. at least 2 is needed for a visible grid
+	Scale int
+	// Strength is how much the gaps are darkened, from 0 to 1
+	Strength float64
+}
+
+func (g PixelGrid) Apply(f *Frame) *Frame {
+	scale := g.Scale
+	if scale < 2 {
+		scale = 2
+	}
+	out := NewFrame(f.Width*scale, f.Height*scale)
+	gap := uint32((1 - g.Strength) * 256)
+	for y := 0; y < out.Height; y++ {
+		for x := 0; x < out.Width; x++ {
+			c := f.Pix[(y/scale)*f.Width+x/scale]
+			if x%scale == scale-1 || y%scale == scale-1 {
+				c = blend(c, BLACK, gap)
+			}
+			out.Pix[y*out.Width+x] = c
+		}
+	}
+	return out
+}
+
+// ColorCorrection approximates how the CGB's LCD shows colors: washed out and less saturated than
+// the raw values, which games were designed around.  It should only be used for CGB games
+type ColorCorrection struct{}
+
+func (ColorCorrection) Apply(f *Frame) *Frame {
+	for i, c := range f.Pix {
+		r, g, b := c>>19&0x1F, c>>11&0x1F, c>>3&0x1F
+		// each channel bleeds into the others, then the result is scaled back to 8 bits
+		cr := min32(r*26+g*4+b*2, 960) >> 2
+		cg := min32(g*24+b*8, 960) >> 2
+		cb := min32(r*6+g*4+b*22, 960) >> 2
+		f.Pix[i] = 0xFF000000 | cr<<16 | cg<<8 | cb
+	}
+	return f
+}
+
+func min32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDMGPalette(t *testing.T) {
+	p, err := ParseDMGPalette("Pocket")
+	require.NoError(t, err)
+	assert.Equal(t, PalettePocket, p)
+
+	p, err = ParseDMGPalette("e0f8d0, 88c070,#346856,081820")
+	require.NoError(t, err)
+	assert.Equal(t, DMGPalette{0xFFE0F8D0, 0xFF88C070, 0xFF346856, 0xFF081820}, p)
+
+	_, err = ParseDMGPalette("e0f8d0,88c070")
+	assert.Error(t, err)
+}
+
+func TestGhosting(t *testing.T) {
+	g := &Ghosting{Weight: 0.5}
+	f := NewFrame(1, 1)
+	f.Pix[0] = WHITE
+	g.Apply(f)
+
+	f = NewFrame(1, 1)
+	f.Pix[0] = BLACK
+	assert.Equal(t, uint32(0xFF7F7F7F), g.Apply(f).Pix[0])
+}
+
+func TestPixelGrid(t *testing.T) {
+	f := NewFrame(2, 1)
+	f.Pix[0], f.Pix[1] = WHITE, BLACK
+	out := PixelGrid{Scale: 3, Strength: 1}.Apply(f)
+	require.Equal(t, 6, out.Width)
+	require.Equal(t, 3, out.Height)
+	assert.Equal(t, WHITE, out.Pix[0])
+	assert.Equal(t, BLACK, out.Pix[2])
+	assert.Equal(t, BLACK, out.Pix[3])
+}
+
+func TestColorCorrection(t *testing.T) {
+	f := NewFrame(1, 1)
+	f.Pix[0] = 0xFFFF0000
+	// pure red loses some intensity, and picks up a little blue
+	assert.Equal(t, uint32(0xFFC9002E), ColorCorrection{}.Apply(f).Pix[0])
+}
@@ -0,0 +1,46 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Frame is a finished picture, ready to be presented.
+// pixels are stored row-major as ARGB8888, the same layout as the SDL color constants
+type Frame struct {
+	Width, Height int
+	Pix           []uint32
+}
+
+//NewFrame allocates a blank frame of the given dimensions
+func NewFrame(width, height int) *Frame {
+	return &Frame{
+		Width:  width,
+		Height: height,
+		Pix:    make([]uint32, width*height),
+	}
+}
+
+//Image converts the frame to a standard library image
+func (f *Frame) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			argb := f.Pix[y*f.Width+x]
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(argb >> 16),
+				G: uint8(argb >> 8),
+				B: uint8(argb),
+				A: uint8(argb >> 24),
+			})
+		}
+	}
+	return img
+}
+
+//WritePNG encodes the frame as a PNG
+func (f *Frame) WritePNG(w io.Writer) error {
+	return png.Encode(w, f.Image())
+}
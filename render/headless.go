@@ -0,0 +1,55 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Headless is a Display with no window.  It keeps the most recent frame in memory,
+// and optionally writes every frame to disk as a numbered PNG
+type Headless struct {
+	OutDir string // if set, each frame is written here as frame-NNNNNN.png
+
+	last   *Frame
+	frames int
+}
+
+func (h *Headless) Present(f *Frame) error {
+	h.last = f
+	h.frames++
+	if h.OutDir == "" {
+		return nil
+	}
+	return h.Save(filepath.Join(h.OutDir, fmt.Sprintf("frame-%06d.png", h.frames)))
+}
+
+//Last returns the most recently presented frame, or nil if nothing has been presented yet
+func (h *Headless) Last() *Frame {
+	return h.last
+}
+
+//Frames returns how many frames have been presented
+func (h *Headless) Frames() int {
+	return h.frames
+}
+
+//Save writes the most recently presented frame to the given path as a PNG
+func (h *Headless) Save(path string) error {
+	if h.last == nil {
+		return fmt.Errorf("no frame has been presented yet")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := h.last.WritePNG(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (h *Headless) Close() error {
+	return nil
+}
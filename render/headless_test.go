@@ -0,0 +1,32 @@
+package render
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadless_writesFrames(t *testing.T) {
+	dir := t.TempDir()
+	h := &Headless{OutDir: dir}
+	assert.Nil(t, h.Last())
+
+	f := NewFrame(ScreenWidth, ScreenHeight)
+	f.Pix[0] = DARK_GRAY
+	require.NoError(t, h.Present(f))
+	assert.Equal(t, 1, h.Frames())
+	assert.Same(t, f, h.Last())
+
+	file, err := os.Open(filepath.Join(dir, "frame-000001.png"))
+	require.NoError(t, err)
+	defer file.Close()
+	img, err := png.Decode(file)
+	require.NoError(t, err)
+	assert.Equal(t, ScreenWidth, img.Bounds().Dx())
+	r, g, b, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, []uint32{0x5454, 0x5454, 0x5454}, []uint32{r, g, b})
+}
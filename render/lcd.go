@@ -1,42 +1,156 @@
+//go:build sdl
+// +build sdl
+
 package render
 
 import (
+	"fmt"
+	"unsafe"
+
 	"github.com/veandco/go-sdl2/sdl"
-	"os"
-	"os/signal"
 )
 
-type LCD struct {
-	window *sdl.Window
+// LCDOptions controls how frames are presented in the SDL window
+type LCDOptions struct {
+	Scale      int  // integer window scale, 1x through 8x
+	Fullscreen bool // start in fullscreen. can be toggled with F11
+	VSync      bool // wait for the display's vertical sync when presenting
 }
 
-func (l *LCD) destruct() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, os.Kill)
-	<- c
-	l.window.Destroy()
-	sdl.Quit()
+// LCD is a Display backed by an SDL window
+type LCD struct {
+	window   *sdl.Window
+	renderer *sdl.Renderer
+	texture  *sdl.Texture
+	texW     int
+	texH     int
+	opts     LCDOptions
+	handlers []func(sdl.Event)
 }
 
-func (l *LCD) Init() {
+func (l *LCD) Init(opts LCDOptions) error {
+	if opts.Scale < MinScale || opts.Scale > MaxScale {
+		return fmt.Errorf("window scale must be between %dx and %dx, got %d", MinScale, MaxScale, opts.Scale)
+	}
+	l.opts = opts
+
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
-		panic(err)
+		return err
 	}
 
-	// render the whole 256x256 internal framebuffer
-	window, err := sdl.CreateWindow("goby", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, 256, 256, sdl.WINDOW_SHOWN)
+	w, h := ScreenWidth, ScreenHeight
+	window, err := sdl.CreateWindow("goby", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
+		int32(w*opts.Scale), int32(h*opts.Scale), sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	l.window = window
 
-	go l.destruct()
+	var flags uint32 = sdl.RENDERER_ACCELERATED
+	if opts.VSync {
+		flags |= sdl.RENDERER_PRESENTVSYNC
+	}
+	renderer, err := sdl.CreateRenderer(window, -1, flags)
+	if err != nil {
+		return err
+	}
+	l.renderer = renderer
 
-	surface, err := window.GetSurface()
+	if err := renderer.SetIntegerScale(true); err != nil {
+		return err
+	}
+	if err := l.resize(w, h); err != nil {
+		return err
+	}
+
+	if opts.Fullscreen {
+		l.ToggleFullscreen()
+	}
+
+	renderer.SetDrawColor(0, 0, 0, 0xFF)
+	renderer.Clear()
+	renderer.Present()
+	return nil
+}
+
+//resize recreates the streaming texture at the given native resolution.
+// the logical size keeps the picture at an integer multiple of that resolution,
+// letterboxing when the window (or fullscreen display) doesn't divide evenly
+func (l *LCD) resize(w, h int) error {
+	if l.texture != nil {
+		l.texture.Destroy()
+	}
+	texture, err := l.renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, int32(w), int32(h))
 	if err != nil {
-		panic(err)
+		return err
 	}
-	surface.FillRect(nil, 0)
+	l.texture = texture
+	l.texW, l.texH = w, h
+	if l.window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP == 0 {
+		l.window.SetSize(int32(w*l.opts.Scale), int32(h*l.opts.Scale))
+	}
+	return l.renderer.SetLogicalSize(int32(w), int32(h))
+}
+
+//Present uploads the finished frame to the window
+func (l *LCD) Present(f *Frame) error {
+	if f.Width != l.texW || f.Height != l.texH {
+		if err := l.resize(f.Width, f.Height); err != nil {
+			return err
+		}
+	}
+	pixels := (*[1 << 24]byte)(unsafe.Pointer(&f.Pix[0]))[:len(f.Pix)*4]
+	if err := l.texture.Update(nil, pixels, f.Width*4); err != nil {
+		return err
+	}
+	if err := l.renderer.Clear(); err != nil {
+		return err
+	}
+	if err := l.renderer.Copy(l.texture, nil, nil); err != nil {
+		return err
+	}
+	l.renderer.Present()
+	return nil
+}
 
-	window.UpdateSurface()
-}
\ No newline at end of file
+//ToggleFullscreen switches between windowed and borderless fullscreen
+func (l *LCD) ToggleFullscreen() {
+	var flags uint32
+	if l.window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP == 0 {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	l.window.SetFullscreen(flags)
+}
+
+//Subscribe registers a function to receive every SDL event, e.g. for input handling
+func (l *LCD) Subscribe(f func(sdl.Event)) {
+	l.handlers = append(l.handlers, f)
+}
+
+//PollEvents drains the SDL event queue.  It returns false once the window has been closed
+// SDL turns SIGINT/SIGTERM into a quit event, so this is also how ctrl-c is handled
+func (l *LCD) PollEvents() bool {
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		for _, f := range l.handlers {
+			f(event)
+		}
+		switch e := event.(type) {
+		case *sdl.QuitEvent:
+			return false
+		case *sdl.KeyboardEvent:
+			if e.Type == sdl.KEYDOWN && e.Repeat == 0 && e.Keysym.Sym == sdl.K_F11 {
+				l.ToggleFullscreen()
+			}
+		}
+	}
+	return true
+}
+
+//Close tears down the window and shuts down SDL
+func (l *LCD) Close() error {
+	l.texture.Destroy()
+	l.renderer.Destroy()
+	l.window.Destroy()
+	sdl.Quit()
+	return nil
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DMGPalette is the 4 colors a DMG game is shown in, from lightest to darkest, as ARGB
+type DMGPalette [4]uint32
+
+var (
+	// PaletteGrays is the default, plain grayscale
+	PaletteGrays = DMGPalette{WHITE, LIGHT_GRAY, DARK_GRAY, BLACK}
+	// PaletteClassic is the green tint of the original DMG screen
+	PaletteClassic = DMGPalette{0xFF9BBC0F, 0xFF8BAC0F, 0xFF306230, 0xFF0F380F}
+	// PalettePocket is the Game Boy Pocket's neutral, slightly olive screen
+	PalettePocket = DMGPalette{0xFFC4CFA1, 0xFF8B956D, 0xFF4D533C, 0xFF1F1F1F}
+	// PaletteLight is the backlit, blue-green Game Boy Light
+	PaletteLight = DMGPalette{0xFF00B581, 0xFF009A71, 0xFF00694A, 0xFF004F3B}
+)
+
+// DMGPalettes are the built-in palettes, by name
+var DMGPalettes = map[string]DMGPalette{
+	"gray":    PaletteGrays,
+	"classic": PaletteClassic,
+	"pocket":  PalettePocket,
+	"light":   PaletteLight,
+}
+
+//ParseDMGPalette accepts either the name of a built-in palette, or 4 comma separated RGB hex colors
+// from lightest to darkest, e.g. "e0f8d0,88c070,346856,081820"
+func ParseDMGPalette(s string) (DMGPalette, error) {
+	if p, ok := DMGPalettes[strings.ToLower(s)]; ok {
+		return p, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		names := make([]string, 0, len(DMGPalettes))
+		for name := range DMGPalettes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return DMGPalette{}, fmt.Errorf("palette %q is neither one of %s nor 4 RGB colors", s, strings.Join(names, ", "))
+	}
+	var p DMGPalette
+	for i, part := range parts {
+		rgb, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(part), "#"), 16, 24)
+		if err != nil {
+			return DMGPalette{}, fmt.Errorf("palette color %q: %v", part, err)
+		}
+		p[i] = 0xFF000000 | uint32(rgb)
+	}
+	return p, nil
+}
@@ -1,12 +1,20 @@
 package render
 
+import (
+	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/state"
+)
+
 // screen resolution: 160x144
 // internal resolution: 256x256
 
 // FrameBuffer is typedef'd to the internal resolution, where the value at an element is the
-// 2-bit grayscale value.  To support color, this should be a 3D uint8 array, where the last rank is 8-bit rgb
+// 2-bit grayscale value.  Color is kept separately, in a ColorFrameBuffer
 type FrameBuffer [256][256]uint8
 
+// ScreenBuffer is the visible screen, where the value at an element is the 2-bit grayscale value
+type ScreenBuffer [144][160]uint8
+
 const (
 	// SDL colors
 	WHITE = uint32(0xFFFFFFFF)
@@ -15,15 +23,248 @@ const (
 	BLACK = uint32(0xFF000000)
 )
 
+const (
+	ScreenWidth  = 160
+	ScreenHeight = 144
+	MapSize      = 256
+
+	// CyclesPerFrame is the number of 4MHz cycles it takes to draw a full frame, including VBlank
+	CyclesPerFrame = 70224
+	cyclesPerLine  = 456
+	linesPerFrame  = 154
+	// hblankStart is how far into a line HBlank begins: after 80 cycles of OAM scan and 172 of drawing
+	hblankStart = 80 + 172
+
+	regLCDC = 0xFF40
+	regSCY  = 0xFF42
+	regSCX  = 0xFF43
+	regLY   = 0xFF44
+	regBGP  = 0xFF47
+	regOBP0 = 0xFF48
+	regOBP1 = 0xFF49
+	regWY   = 0xFF4A
+	regWX   = 0xFF4B
+
+	vramStart = 0x8000
+	vramEnd   = 0x9FFF
+	oamStart  = 0xFE00
+)
 
 type PPU struct {
-	FB FrameBuffer
+	FB          FrameBuffer      // the whole background map, for debugging
+	Screen      ScreenBuffer     // the visible screen, in DMG mode
+	ColorScreen ColorFrameBuffer // the visible screen, in CGB mode
 
 	LCDC uint8 // FF40
+	SCY  uint8 // FF42
+	SCX  uint8 // FF43
+
+	// Palette maps DMG shades to colors. it defaults to gray
+	Palette DMGPalette
+
+	// CGB enables color: a second VRAM bank, palette RAM, BG map attributes and CGB object priority
+	CGB bool
+	// compat colors a DMG game through CGB palette RAM, see SetCompatPalette
+	compat bool
+	// OnHBlank, if set, is called at the start of every visible line's HBlank, e.g. for HBlank DMA
+	OnHBlank func()
+
+	ram        *mem.RAM
+	vram       [2][0x2000]byte // 8000-9FFF. DMG only uses the first bank
+	vbk        byte            // FF4F, CGB VRAM bank select
+	bgPalettes paletteRAM      // FF68-FF69
+	obPalettes paletteRAM      // FF6A-FF6B
+	lineCycles int             // cycles spent on the current scanline
+	lineDrawn  bool            // the current scanline has been drawn, and HBlank has begun
+	ly         uint8           // current scanline, FF44
+	windowLine int             // the window keeps its own line counter, which only advances on lines it's drawn
+}
+
+//Init resets the PPU and maps VRAM into memory.  In CGB mode, the VRAM bank and palette registers are mapped too
+func (p *PPU) Init(ram *mem.RAM, cgb bool) {
+	*p = PPU{ram: ram, CGB: cgb, OnHBlank: p.OnHBlank, Palette: p.Palette}
+	if p.Palette == (DMGPalette{}) {
+		p.Palette = PaletteGrays
+	}
+	p.ram.Map(vramStart, vramEnd, p)
+	if cgb {
+		p.ram.Map(regVBK, regVBK, p)
+		p.ram.Map(regBCPS, regOCPD, p)
+	}
 }
 
-// Init initializes the framebuffer
-func (p *PPU) Init() {
-	p.FB = FrameBuffer{}
+//Step advances the PPU by the given number of 4MHz cycles
+// it returns true when VBlank begins, which is when a finished frame is ready to be presented
+func (p *PPU) Step(cycles4 int) bool {
+	p.LCDC = p.ram.Peek(regLCDC)
+	if p.LCDC&0x80 == 0 {
+		// LCD is off: LY is held at 0 and nothing is drawn
+		p.lineCycles = 0
+		p.lineDrawn = false
+		p.windowLine = 0
+		p.setLY(0)
+		return false
+	}
+
+	frameDone := false
+	p.lineCycles += cycles4
+	for {
+		if !p.lineDrawn && p.lineCycles >= hblankStart && p.ly < ScreenHeight {
+			p.lineDrawn = true
+			p.renderLine(int(p.ly))
+			if p.OnHBlank != nil {
+				p.OnHBlank()
+			}
+		}
+		if p.lineCycles < cyclesPerLine {
+			break
+		}
+		p.lineCycles -= cyclesPerLine
+		p.lineDrawn = false
+		p.setLY((p.ly + 1) % linesPerFrame)
+		switch p.ly {
+		case 0:
+			p.windowLine = 0
+		case ScreenHeight:
+			p.SCY = p.ram.Peek(regSCY)
+			p.SCX = p.ram.Peek(regSCX)
+			p.renderBackground()
+			p.ram.RequestInterrupt(mem.IntVBlank)
+			frameDone = true
+		}
+	}
+	return frameDone
+}
+
+func (p *PPU) setLY(ly uint8) {
+	p.ly = ly
+	p.ram.Poke(regLY, ly)
 }
 
+func (p *PPU) Read(addr uint16) byte {
+	switch {
+	case addr <= vramEnd:
+		return p.vram[p.vbk][addr-vramStart]
+	case addr == regVBK:
+		return 0xFE | p.vbk
+	default:
+		return p.readPalette(addr)
+	}
+}
+
+func (p *PPU) Write(addr uint16, val byte) {
+	switch {
+	case addr <= vramEnd:
+		p.vram[p.vbk][addr-vramStart] = val
+	case addr == regVBK:
+		p.vbk = val & 0x01
+	default:
+		p.writePalette(addr, val)
+	}
+}
+
+//renderBackground draws the whole 32x32 tile background map into the framebuffer
+func (p *PPU) renderBackground() {
+	mapBase := uint16(0x9800)
+	if p.LCDC&0x08 != 0 {
+		mapBase = 0x9C00
+	}
+	bgp := p.ram.Peek(regBGP)
+
+	for tileY := 0; tileY < 32; tileY++ {
+		for tileX := 0; tileX < 32; tileX++ {
+			tileIdx := p.vram[0][mapBase-vramStart+uint16(tileY*32+tileX)]
+			tileAddr := p.tileAddr(tileIdx)
+			for row := 0; row < 8; row++ {
+				for col := 0; col < 8; col++ {
+					colorID := p.tilePixel(0, tileAddr, row, col)
+					p.FB[tileY*8+row][tileX*8+col] = (bgp >> (colorID * 2)) & 0x3
+				}
+			}
+		}
+	}
+}
+
+//tileAddr returns the address of the given BG tile's data.
+// LCDC bit 4 selects between unsigned addressing from 8000 and signed addressing from 9000
+func (p *PPU) tileAddr(idx uint8) uint16 {
+	if p.LCDC&0x10 != 0 {
+		return 0x8000 + uint16(idx)*16
+	}
+	return uint16(int32(0x9000) + int32(int8(idx))*16)
+}
+
+//tilePixel returns the 2-bit color ID of a pixel within the tile at the given address
+func (p *PPU) tilePixel(bank int, tileAddr uint16, row, col int) uint8 {
+	offset := tileAddr - vramStart + uint16(row*2)
+	lo := p.vram[bank][offset]
+	hi := p.vram[bank][offset+1]
+	bit := uint(7 - col)
+	return ((hi>>bit)&1)<<1 | (lo>>bit)&1
+}
+
+//TransferTiles returns the data of the first 256 tiles on screen, reading the BG map left to right
+// and top to bottom.  The SGB receives bulk data this way: the game fills VRAM and displays it
+func (p *PPU) TransferTiles() []byte {
+	mapBase := uint16(0x9800)
+	if p.LCDC&0x08 != 0 {
+		mapBase = 0x9C00
+	}
+	data := make([]byte, 0, 256*16)
+	for i := 0; i < 256; i++ {
+		tileIdx := p.vram[0][mapBase-vramStart+uint16((i/20)*32+i%20)]
+		offset := p.tileAddr(tileIdx) - vramStart
+		data = append(data, p.vram[0][offset:offset+16]...)
+	}
+	return data
+}
+
+//Frame converts the framebuffer into SDL colors.
+// by default the visible 160x144 screen is returned, in color if in CGB mode or colorizing a DMG game.
+// if full is set, the whole 256x256 background map is returned instead
+func (p *PPU) Frame(full bool) *Frame {
+	if full {
+		f := NewFrame(MapSize, MapSize)
+		for y := 0; y < MapSize; y++ {
+			for x := 0; x < MapSize; x++ {
+				f.Pix[y*MapSize+x] = p.Palette[p.FB[y][x]]
+			}
+		}
+		return f
+	}
+
+	f := NewFrame(ScreenWidth, ScreenHeight)
+	for y := 0; y < ScreenHeight; y++ {
+		for x := 0; x < ScreenWidth; x++ {
+			if p.CGB || p.compat {
+				f.Pix[y*ScreenWidth+x] = RGB15ToARGB(p.ColorScreen[y][x])
+			} else {
+				f.Pix[y*ScreenWidth+x] = p.Palette[p.Screen[y][x]]
+			}
+		}
+	}
+	return f
+}
+
+//Serialize saves or restores VRAM, palettes, timing, and the screen as drawn so far
+func (p *PPU) Serialize(s *state.Serializer) {
+	s.Bytes(p.vram[0][:])
+	s.Bytes(p.vram[1][:])
+	s.Uint8(&p.vbk)
+	s.Bytes(p.bgPalettes.data[:])
+	s.Uint8(&p.bgPalettes.index)
+	s.Bytes(p.obPalettes.data[:])
+	s.Uint8(&p.obPalettes.index)
+	s.Uint8(&p.LCDC)
+	s.Uint8(&p.SCY)
+	s.Uint8(&p.SCX)
+	s.Int(&p.lineCycles)
+	s.Bool(&p.lineDrawn)
+	s.Uint8(&p.ly)
+	s.Int(&p.windowLine)
+	s.Bool(&p.compat)
+	for y := range p.Screen {
+		s.Bytes(p.Screen[y][:])
+		s.Uint16s(p.ColorScreen[y][:])
+	}
+}
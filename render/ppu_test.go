@@ -0,0 +1,105 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+//solidTile fills a tile in the given VRAM bank with a single color ID
+func solidTile(p *PPU, bank int, addr uint16, colorID uint8) {
+	for row := 0; row < 8; row++ {
+		var lo, hi byte
+		if colorID&1 != 0 {
+			lo = 0xFF
+		}
+		if colorID&2 != 0 {
+			hi = 0xFF
+		}
+		p.vram[bank][addr-vramStart+uint16(row*2)] = lo
+		p.vram[bank][addr-vramStart+uint16(row*2)+1] = hi
+	}
+}
+
+func TestPPU_paletteAutoIncrement(t *testing.T) {
+	p := &PPU{}
+	p.Init(&mem.RAM{}, true)
+
+	p.Write(regBCPS, autoIncrement|0x08) // palette 1, color 0
+	for _, b := range []byte{0x1F, 0x00, 0xE0, 0x03} {
+		p.Write(regBCPD, b)
+	}
+	assert.Equal(t, byte(0xCC), p.Read(regBCPS))
+	assert.Equal(t, uint16(0x001F), p.bgPalettes.color(1, 0))
+	assert.Equal(t, uint16(0x03E0), p.bgPalettes.color(1, 1))
+	assert.Equal(t, uint32(0xFFFF0000), RGB15ToARGB(p.bgPalettes.color(1, 0)))
+}
+
+func TestPPU_cgbMapAttributes(t *testing.T) {
+	ram := &mem.RAM{}
+	p := &PPU{}
+	p.Init(ram, true)
+	p.LCDC = 0x91 // LCD and BG on, tile data at 8000, map at 9800
+
+	// tile 0 is color 1 in bank 0, but color 3 in bank 1
+	solidTile(p, 0, 0x8000, 1)
+	solidTile(p, 1, 0x8000, 3)
+	p.vram[1][0x9800-vramStart] = attrBank | 2 // first tile of the map uses bank 1 and palette 2
+	p.bgPalettes.setColor(2, 3, 0x7C00)
+	p.bgPalettes.setColor(0, 1, 0x001F)
+
+	p.renderLine(0)
+	assert.Equal(t, uint16(0x7C00), p.ColorScreen[0][0])
+	assert.Equal(t, uint16(0x001F), p.ColorScreen[0][8])
+}
+
+func TestPPU_objectPriority(t *testing.T) {
+	ram := &mem.RAM{}
+	p := &PPU{}
+	p.Init(ram, false)
+	p.LCDC = 0x93 // LCD, BG and objects on
+	ram.WriteByte(regBGP, 0xE4)
+	ram.WriteByte(regOBP0, 0xE4)
+	solidTile(p, 0, 0x8010, 1)
+	solidTile(p, 0, 0x8020, 2)
+
+	// two overlapping objects: the first in OAM is further right
+	for i, b := range []byte{16, 12, 1, 0, 16, 8, 2, 0} {
+		ram.WriteByte(oamStart+uint16(i), b)
+	}
+
+	p.renderLine(0)
+	// on DMG the leftmost object wins where they overlap
+	assert.Equal(t, uint8(2), p.Screen[0][4])
+	assert.Equal(t, uint8(2), p.Screen[0][7])
+	assert.Equal(t, uint8(1), p.Screen[0][8])
+
+	p.CGB = true
+	p.obPalettes.setColor(0, 1, 0x1111)
+	p.obPalettes.setColor(0, 2, 0x2222)
+	p.renderLine(0)
+	// on CGB OAM order wins
+	assert.Equal(t, uint16(0x2222), p.ColorScreen[0][3])
+	assert.Equal(t, uint16(0x1111), p.ColorScreen[0][4])
+}
+
+func TestPPU_compatPalette(t *testing.T) {
+	ram := &mem.RAM{}
+	p := &PPU{}
+	p.Init(ram, false)
+	p.SetCompatPalette(PaletteDarkGreen)
+	p.LCDC = 0x91
+	ram.WriteByte(regBGP, 0xE4) // identity: color ID n is shade n
+
+	solidTile(p, 0, 0x8000, 1)
+	p.renderLine(0)
+	assert.Equal(t, uint8(1), p.Screen[0][0])
+	assert.Equal(t, uint32(0xFF7BFF31), p.Frame(false).Pix[0])
+
+	// BGP still picks the shade, which then indexes the palette
+	ram.WriteByte(regBGP, 0xE4&^0x0C|0x08)
+	p.renderLine(0)
+	// 5 bits per channel can't hold every 24-bit color exactly
+	assert.Equal(t, RGB15ToARGB(RGB24ToRGB15(0x0063C5)), p.Frame(false).Pix[0])
+}
@@ -0,0 +1,201 @@
+package render
+
+import "sort"
+
+const (
+	// BG map attributes, CGB only.  They live in VRAM bank 1 at the same address as the tile index
+	attrPalette  = 0x07
+	attrBank     = 0x08
+	attrXFlip    = 0x20
+	attrYFlip    = 0x40
+	attrPriority = 0x80 // BG colors 1-3 are drawn over objects
+
+	// object attributes.  The palette and bank bits are CGB only, OBP1 is DMG only
+	objOBP1       = 0x10
+	objXFlip      = 0x20
+	objYFlip      = 0x40
+	objBehindBG   = 0x80 // BG colors 1-3 are drawn over the object
+	maxObjPerLine = 10
+)
+
+// object is one OAM entry, with its position already converted to screen coordinates
+type object struct {
+	index int // position in OAM
+	y, x  int
+	tile  uint8
+	attrs uint8
+}
+
+//renderLine draws a single scanline: background, then window, then objects
+func (p *PPU) renderLine(ly int) {
+	var colorIDs [ScreenWidth]uint8 // BG/window color IDs, for object priority
+	var bgAttrs [ScreenWidth]uint8
+
+	// on DMG, LCDC bit 0 blanks the BG and window.  on CGB it instead takes away their priority over objects
+	bgEnabled := p.CGB || p.LCDC&0x01 != 0
+	if bgEnabled {
+		p.renderBGLine(ly, &colorIDs, &bgAttrs)
+	} else {
+		for x := 0; x < ScreenWidth; x++ {
+			p.Screen[ly][x] = 0
+			p.ColorScreen[ly][x] = p.bgPalettes.color(0, 0)
+		}
+	}
+	if p.LCDC&0x02 != 0 {
+		p.renderObjLine(ly, &colorIDs, &bgAttrs)
+	}
+}
+
+//renderBGLine draws the background for the given line, with the window over it
+func (p *PPU) renderBGLine(ly int, colorIDs, bgAttrs *[ScreenWidth]uint8) {
+	bgMap := uint16(0x9800)
+	if p.LCDC&0x08 != 0 {
+		bgMap = 0x9C00
+	}
+	winMap := uint16(0x9800)
+	if p.LCDC&0x40 != 0 {
+		winMap = 0x9C00
+	}
+	scy, scx := p.ram.Peek(regSCY), p.ram.Peek(regSCX)
+	wy, wx := int(p.ram.Peek(regWY)), int(p.ram.Peek(regWX))-7
+	windowOnLine := p.LCDC&0x20 != 0 && ly >= wy && wx < ScreenWidth
+
+	for x := 0; x < ScreenWidth; x++ {
+		mapBase, mapX, mapY := bgMap, int(uint8(x)+scx), int(uint8(ly)+scy)
+		if windowOnLine && x >= wx {
+			mapBase, mapX, mapY = winMap, x-wx, p.windowLine
+		}
+		mapOffset := mapBase - vramStart + uint16((mapY/8)*32+mapX/8)
+		tileIdx := p.vram[0][mapOffset]
+		row, col := mapY%8, mapX%8
+
+		var attrs uint8
+		bank := 0
+		if p.CGB {
+			attrs = p.vram[1][mapOffset]
+			if attrs&attrBank != 0 {
+				bank = 1
+			}
+			if attrs&attrYFlip != 0 {
+				row = 7 - row
+			}
+			if attrs&attrXFlip != 0 {
+				col = 7 - col
+			}
+		}
+
+		colorID := p.tilePixel(bank, p.tileAddr(tileIdx), row, col)
+		colorIDs[x] = colorID
+		bgAttrs[x] = attrs
+		p.setBGPixel(ly, x, attrs&attrPalette, colorID)
+	}
+	if windowOnLine {
+		p.windowLine++
+	}
+}
+
+func (p *PPU) setBGPixel(ly, x int, palette, colorID uint8) {
+	if p.CGB {
+		p.ColorScreen[ly][x] = p.bgPalettes.color(palette, colorID)
+		return
+	}
+	bgp := p.ram.Peek(regBGP)
+	shade := (bgp >> (colorID * 2)) & 0x3
+	p.Screen[ly][x] = shade
+	if p.compat {
+		p.ColorScreen[ly][x] = p.bgPalettes.color(0, shade)
+	}
+}
+
+//lineObjects returns the objects on the given line, in priority order.
+// the first 10 objects in OAM that overlap the line are drawn.  On DMG the one furthest left wins
+// where objects overlap, with ties going to OAM order.  On CGB, OAM order alone decides
+func (p *PPU) lineObjects(ly int, height int) []object {
+	var objs []object
+	for i := 0; i < 40 && len(objs) < maxObjPerLine; i++ {
+		addr := uint16(oamStart + i*4)
+		y := int(p.ram.Peek(addr)) - 16
+		if ly < y || ly >= y+height {
+			continue
+		}
+		objs = append(objs, object{
+			index: i,
+			y:     y,
+			x:     int(p.ram.Peek(addr+1)) - 8,
+			tile:  p.ram.Peek(addr + 2),
+			attrs: p.ram.Peek(addr + 3),
+		})
+	}
+	if !p.CGB {
+		sort.SliceStable(objs, func(i, j int) bool {
+			return objs[i].x < objs[j].x
+		})
+	}
+	return objs
+}
+
+//renderObjLine draws the objects on the given line over the background
+func (p *PPU) renderObjLine(ly int, colorIDs, bgAttrs *[ScreenWidth]uint8) {
+	height := 8
+	if p.LCDC&0x04 != 0 {
+		height = 16
+	}
+	// when CGB's LCDC bit 0 is clear, objects are always drawn over the background
+	bgCanWin := !p.CGB || p.LCDC&0x01 != 0
+
+	var claimed [ScreenWidth]bool
+	for _, obj := range p.lineObjects(ly, height) {
+		row := ly - obj.y
+		if obj.attrs&objYFlip != 0 {
+			row = height - 1 - row
+		}
+		tile := obj.tile
+		if height == 16 {
+			tile &= 0xFE
+		}
+		bank := 0
+		if p.CGB && obj.attrs&attrBank != 0 {
+			bank = 1
+		}
+		tileAddr := vramStart + uint16(tile)*16 + uint16(row/8)*16
+
+		for col := 0; col < 8; col++ {
+			x := obj.x + col
+			if x < 0 || x >= ScreenWidth || claimed[x] {
+				continue
+			}
+			tileCol := col
+			if obj.attrs&objXFlip != 0 {
+				tileCol = 7 - col
+			}
+			colorID := p.tilePixel(bank, tileAddr, row%8, tileCol)
+			if colorID == 0 {
+				// transparent: an object further down the priority order may still draw here
+				continue
+			}
+			// a higher priority object claims the pixel even if the background then hides it
+			claimed[x] = true
+			behind := obj.attrs&objBehindBG != 0 || bgAttrs[x]&attrPriority != 0
+			if bgCanWin && behind && colorIDs[x] != 0 {
+				continue
+			}
+			p.setObjPixel(ly, x, obj.attrs, colorID)
+		}
+	}
+}
+
+func (p *PPU) setObjPixel(ly, x int, attrs, colorID uint8) {
+	if p.CGB {
+		p.ColorScreen[ly][x] = p.obPalettes.color(attrs&attrPalette, colorID)
+		return
+	}
+	obp, palette := p.ram.Peek(regOBP0), uint8(0)
+	if attrs&objOBP1 != 0 {
+		obp, palette = p.ram.Peek(regOBP1), 1
+	}
+	shade := (obp >> (colorID * 2)) & 0x3
+	p.Screen[ly][x] = shade
+	if p.compat {
+		p.ColorScreen[ly][x] = p.obPalettes.color(palette, shade)
+	}
+}
@@ -0,0 +1,163 @@
+package rewind
+
+import (
+	"bytes"
+	"compress/flate"
+	"io/ioutil"
+)
+
+// DefaultKeyframeInterval is how many snapshots go between keyframes: one a second
+const DefaultKeyframeInterval = 60
+
+// Buffer keeps the most recent snapshots of the machine, e.g. one per frame, so that play can be stepped backwards.
+// a keyframe is stored whole every so often, and every snapshot after it is stored as its XOR with the keyframe.
+// consecutive frames differ in few bytes, so the deltas are mostly zeros, and compress to a fraction of the original
+type Buffer struct {
+	capacity int // snapshots
+	interval int // snapshots per keyframe
+	groups   []*group
+	count    int // snapshots across all groups
+	size     int // compressed bytes across all groups
+}
+
+// group is a keyframe and the deltas that depend on it. groups are dropped whole, oldest first
+type group struct {
+	keyframe []byte   // uncompressed, for XORing against. only kept for the newest group
+	frames   [][]byte // compressed: the keyframe first, then deltas against it
+	length   int      // snapshot length. every snapshot in a group is the same size
+}
+
+//New creates a buffer holding up to capacity snapshots, with a keyframe every interval snapshots
+func New(capacity, interval int) *Buffer {
+	if interval > capacity {
+		// a group can't be dropped while it's still being added to
+		interval = capacity
+	}
+	if interval < 1 {
+		interval = 1
+	}
+	return &Buffer{capacity: capacity, interval: interval}
+}
+
+//Len returns how many snapshots can be stepped back through
+func (b *Buffer) Len() int {
+	return b.count
+}
+
+//Cap returns how many snapshots the buffer holds at most.  Pushing to a buffer of capacity 0 does nothing
+func (b *Buffer) Cap() int {
+	return b.capacity
+}
+
+//Size returns the compressed size of everything held, in bytes
+func (b *Buffer) Size() int {
+	return b.size
+}
+
+//Push records a snapshot.  The buffer keeps its own copy
+func (b *Buffer) Push(snapshot []byte) {
+	if b.capacity <= 0 {
+		return
+	}
+	last := b.newest()
+	if last == nil || len(last.frames) >= b.interval || last.length != len(snapshot) {
+		if last != nil {
+			// only the newest keyframe is ever XORed against
+			last.keyframe = nil
+		}
+		key := append([]byte(nil), snapshot...)
+		b.append(&group{keyframe: key, length: len(snapshot)}, key)
+	} else {
+		delta := make([]byte, len(snapshot))
+		for i := range snapshot {
+			delta[i] = snapshot[i] ^ last.keyframe[i]
+		}
+		b.append(last, delta)
+	}
+	for b.count > b.capacity {
+		b.dropOldest()
+	}
+}
+
+func (b *Buffer) append(g *group, data []byte) {
+	if len(b.groups) == 0 || b.groups[len(b.groups)-1] != g {
+		b.groups = append(b.groups, g)
+	}
+	c := compress(data)
+	g.frames = append(g.frames, c)
+	b.count++
+	b.size += len(c)
+}
+
+//Pop removes and returns the most recent snapshot, and false if there are none left
+func (b *Buffer) Pop() ([]byte, bool) {
+	g := b.newest()
+	if g == nil {
+		return nil, false
+	}
+	key := g.keyframe
+	if key == nil {
+		key = decompress(g.frames[0], g.length)
+	}
+
+	n := len(g.frames) - 1
+	out := key
+	if n > 0 {
+		out = decompress(g.frames[n], g.length)
+		for i := range out {
+			out[i] ^= key[i]
+		}
+	}
+	b.size -= len(g.frames[n])
+	g.frames = g.frames[:n]
+	b.count--
+	if n == 0 {
+		b.groups = b.groups[:len(b.groups)-1]
+	} else {
+		// keep the keyframe around for the next Pop, and for any Push that follows
+		g.keyframe = key
+	}
+	return out, true
+}
+
+func (b *Buffer) newest() *group {
+	if len(b.groups) == 0 {
+		return nil
+	}
+	return b.groups[len(b.groups)-1]
+}
+
+//dropOldest drops the oldest group.  Its deltas are useless without its keyframe
+func (b *Buffer) dropOldest() {
+	g := b.groups[0]
+	b.groups = b.groups[1:]
+	b.count -= len(g.frames)
+	for _, f := range g.frames {
+		b.size -= len(f)
+	}
+}
+
+//Clear drops everything
+func (b *Buffer) Clear() {
+	b.groups = nil
+	b.count = 0
+	b.size = 0
+}
+
+func compress(data []byte) []byte {
+	var buf bytes.Buffer
+	// BestSpeed is plenty for runs of zeros, and this happens every frame
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func decompress(data []byte, length int) []byte {
+	out, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	if err != nil || len(out) != length {
+		// only ever reads back what compress wrote
+		panic("rewind: corrupt snapshot")
+	}
+	return out
+}
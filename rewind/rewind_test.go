@@ -0,0 +1,71 @@
+package rewind
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//snapshot is a large, mostly unchanging state with a frame counter in it
+func snapshot(frame int) []byte {
+	s := make([]byte, 0x4000)
+	for i := range s {
+		s[i] = byte(i * 7)
+	}
+	s[100] = byte(frame)
+	s[0x3000] = byte(frame >> 8)
+	return s
+}
+
+func TestBuffer_stepsBackwards(t *testing.T) {
+	b := New(100, 10)
+	for i := 0; i < 25; i++ {
+		b.Push(snapshot(i))
+	}
+	assert.Equal(t, 25, b.Len())
+	// 3 keyframes, and deltas of mostly zeros
+	assert.Less(t, b.Size(), 3*0x4000/2)
+
+	for i := 24; i >= 20; i-- {
+		s, ok := b.Pop()
+		require.True(t, ok)
+		assert.Equal(t, snapshot(i), s)
+	}
+
+	// play resumes from the rewound point
+	b.Push(snapshot(1000))
+	s, _ := b.Pop()
+	assert.Equal(t, snapshot(1000), s)
+	s, _ = b.Pop()
+	assert.Equal(t, snapshot(19), s)
+}
+
+func TestBuffer_dropsOldestGroups(t *testing.T) {
+	b := New(20, 10)
+	for i := 0; i < 35; i++ {
+		b.Push(snapshot(i))
+	}
+	// the first two groups of 10 had to go, as only 20 snapshots are kept
+	assert.Equal(t, 15, b.Len())
+
+	var last []byte
+	for {
+		s, ok := b.Pop()
+		if !ok {
+			break
+		}
+		last = s
+	}
+	assert.Equal(t, snapshot(20), last)
+	assert.Zero(t, b.Size())
+}
+
+func TestBuffer_off(t *testing.T) {
+	b := New(0, DefaultKeyframeInterval)
+	assert.Equal(t, 0, b.Cap())
+	b.Push(snapshot(0))
+	assert.Equal(t, 0, b.Len())
+	_, ok := b.Pop()
+	assert.False(t, ok)
+}
@@ -0,0 +1,163 @@
+package sched
+
+import (
+	"time"
+
+	"github.com/raidancampbell/goby/apu"
+	"github.com/raidancampbell/goby/render"
+)
+
+// FrameRate is the DMG's refresh rate: the 4194304 Hz clock over 70224 cycles per frame, about 59.7275 Hz
+const FrameRate = float64(apu.ClockHz) / render.CyclesPerFrame
+
+// Mode is what the scheduler paces emulation against
+type Mode int
+
+const (
+	// SyncAudio waits for the audio queue to drain to its target latency.  This gives crackle-free sound
+	SyncAudio Mode = iota
+	// SyncVideo leaves pacing to the display's vsync, i.e. the display blocks when presenting.
+	// the emulated frame rate then matches the host's refresh rate, which should be close to 60Hz
+	SyncVideo
+	// SyncTimer sleeps until each frame's deadline on the wall clock
+	SyncTimer
+	// Unlimited runs as fast as the host allows, e.g. for benchmarking. sound is discarded
+	Unlimited
+)
+
+const (
+	// targetLatency is how much audio SyncAudio keeps queued
+	targetLatency = 50 * time.Millisecond
+	// maxRateDelta is the furthest dynamic rate control will stray from the nominal sample rate.
+	// half a percent of pitch is inaudible, and covers the difference between host and emulated clocks
+	maxRateDelta = 0.005
+	// maxLag is how far behind SyncTimer may fall before it gives up catching up
+	maxLag = 5
+	// DefaultMultiplier is how many times real speed fast-forward runs at
+	DefaultMultiplier = 4
+)
+
+// framePeriod is how long a frame lasts on the DMG
+const framePeriod = time.Duration(render.CyclesPerFrame) * time.Second / apu.ClockHz
+
+// AudioQueue is an audio sink that can report its backlog, e.g. apu.SDLOutput
+type AudioQueue interface {
+	apu.Sink
+	//Queued returns how many stereo samples are waiting to be played
+	Queued() int
+}
+
+// Scheduler holds emulation to the DMG's real speed.  It sits between the APU and the audio output,
+// so that it can apply dynamic rate control and drop sound while fast-forwarding
+type Scheduler struct {
+	Mode        Mode
+	FastForward bool // run Multiplier frames per host frame
+	Multiplier  int
+	Rewinding   bool // stepping backwards, which makes no sound, so frames are paced by the clock
+
+	apu      *apu.APU
+	out      AudioQueue // may be nil
+	deadline time.Time
+}
+
+//New creates a scheduler.  The audio queue may be nil, in which case SyncAudio falls back to SyncTimer
+func New(mode Mode, a *apu.APU, out AudioQueue) *Scheduler {
+	return &Scheduler{
+		Mode:       mode,
+		Multiplier: DefaultMultiplier,
+		apu:        a,
+		out:        out,
+	}
+}
+
+//Frames returns how many emulated frames should run before the next call to Wait
+func (s *Scheduler) Frames() int {
+	if s.FastForward && s.Multiplier > 1 {
+		return s.Multiplier
+	}
+	return 1
+}
+
+//Queue forwards samples to the audio output, unless they would arrive faster than real time
+func (s *Scheduler) Queue(samples []int16) error {
+	if s.out == nil || s.FastForward || s.Rewinding || s.Mode == Unlimited {
+		return nil
+	}
+	return s.out.Queue(samples)
+}
+
+//Wait blocks until the next frame is due
+func (s *Scheduler) Wait() {
+	switch s.pacing() {
+	case SyncAudio:
+		s.waitForAudio()
+	case SyncVideo:
+		s.adjustRate()
+	case SyncTimer:
+		s.waitForDeadline()
+	}
+}
+
+//pacing returns what Wait paces against right now.  SyncAudio falls back to the clock when there's no audio to
+// wait for: there's no output, or nothing is being queued because of fast-forward or rewind
+func (s *Scheduler) pacing() Mode {
+	if s.Mode == SyncAudio && (s.out == nil || s.Rewinding || s.FastForward) {
+		return SyncTimer
+	}
+	return s.Mode
+}
+
+//waitForAudio sleeps until the audio queue has drained to the target latency
+func (s *Scheduler) waitForAudio() {
+	target := s.targetQueued()
+	for s.out.Queued() > target {
+		time.Sleep(time.Millisecond)
+	}
+	s.adjustRate()
+}
+
+//waitForDeadline sleeps until the frames run since the last call are due
+func (s *Scheduler) waitForDeadline() {
+	period := s.period()
+	now := time.Now()
+	if s.deadline.IsZero() || now.Sub(s.deadline) > maxLag*period {
+		// first frame, or too far behind (e.g. the process was suspended): start over rather than racing to catch up
+		s.deadline = now
+	}
+	s.deadline = s.deadline.Add(period)
+	time.Sleep(s.deadline.Sub(now))
+}
+
+//period returns how long the frames run between calls to Wait should take.  Fast-forwarding, that's Frames
+// frames, each a Multiplier'th of the usual period
+func (s *Scheduler) period() time.Duration {
+	period := framePeriod * time.Duration(s.Frames())
+	if s.FastForward && s.Multiplier > 1 {
+		period /= time.Duration(s.Multiplier)
+	}
+	return period
+}
+
+func (s *Scheduler) targetQueued() int {
+	return int(float64(s.apu.SampleRate()) * targetLatency.Seconds())
+}
+
+//adjustRate nudges the APU's output rate so the audio queue hovers at the target latency.
+// a queue that's running dry needs more samples per emulated frame, and a full one needs fewer
+func (s *Scheduler) adjustRate() {
+	if s.out == nil {
+		return
+	}
+	s.apu.SetSampleRate(rateFor(s.apu.SampleRate(), s.out.Queued(), s.targetQueued()))
+}
+
+//rateFor is the dynamic rate control formula.  The queue's capacity is twice the target,
+// and the rate varies linearly from +maxRateDelta when empty to -maxRateDelta when full
+func rateFor(nominal, queued, target int) float64 {
+	capacity := 2 * float64(target)
+	fill := float64(queued)
+	if fill > capacity {
+		fill = capacity
+	}
+	return float64(nominal) * (1 + maxRateDelta*(capacity-2*fill)/capacity)
+}
@@ -0,0 +1,40 @@
+package sched
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_rateFor(t *testing.T) {
+	// on target: the nominal rate
+	assert.InDelta(t, 48000, rateFor(48000, 2400, 2400), 0.001)
+	// running dry: produce more samples
+	assert.InDelta(t, 48240, rateFor(48000, 0, 2400), 0.001)
+	// overfull: produce fewer, but never beyond the maximum delta
+	assert.InDelta(t, 47760, rateFor(48000, 4800, 2400), 0.001)
+	assert.InDelta(t, 47760, rateFor(48000, 96000, 2400), 0.001)
+}
+
+func TestScheduler_fastForward(t *testing.T) {
+	s := New(SyncAudio, nil, nil)
+	s.out = &queue{}
+	assert.Equal(t, SyncAudio, s.pacing())
+	assert.Equal(t, framePeriod, s.period())
+
+	// nothing is queued while fast-forwarding, so the clock takes over, running Multiplier frames per period
+	s.FastForward = true
+	s.Multiplier = 3
+	assert.Equal(t, SyncTimer, s.pacing())
+	assert.Equal(t, 3, s.Frames())
+	assert.Equal(t, framePeriod, s.period())
+
+	s.Mode = Unlimited
+	assert.Equal(t, Unlimited, s.pacing())
+}
+
+// queue is an audio output that plays nothing
+type queue struct{}
+
+func (*queue) Queue(samples []int16) error { return nil }
+func (*queue) Queued() int                 { return 0 }
@@ -0,0 +1,202 @@
+package serial
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/state"
+)
+
+const (
+	RegSB = 0xFF01 // serial transfer data
+	RegSC = 0xFF02 // serial transfer control
+
+	scStart    = 0x80 // SC bit 7: a transfer is requested or in progress
+	scInternal = 0x01 // SC bit 0: this side drives the clock
+
+	// transferCycles is how long 8 bits take to shift out at the internal 8192Hz clock
+	transferCycles = 8 * 512
+
+	// disconnected is what's shifted in when nothing is on the other end of the cable
+	disconnected = 0xFF
+)
+
+// SerialPeer is whatever is on the other end of the link cable
+type SerialPeer interface {
+	//Transfer is called when this side, driving the clock, finishes shifting out a byte.
+	// it returns the byte the peer shifted back in at the same time
+	Transfer(out byte) byte
+}
+
+// Port is the serial port: the SB and SC registers at FF01 and FF02.
+// when SC selects the internal clock, this side is the master and a transfer completes after 8 bits at 8192Hz.
+// with the external clock, a transfer only completes when the peer clocks one in, via Receive
+type Port struct {
+	mu        sync.Mutex
+	ram       *mem.RAM
+	peer      SerialPeer
+	sb        byte
+	sc        byte
+	remaining int // cycles left in an internally clocked transfer
+}
+
+//New creates a serial port connected to the given peer, which may be nil,
+// and maps it into memory at FF01-FF02
+func New(ram *mem.RAM, peer SerialPeer) *Port {
+	p := &Port{ram: ram, peer: peer}
+	ram.Map(RegSB, RegSC, p)
+	return p
+}
+
+//Connect plugs a peer into the port.  A nil peer unplugs the cable
+func (p *Port) Connect(peer SerialPeer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peer = peer
+}
+
+func (p *Port) Read(addr uint16) byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if addr == RegSB {
+		return p.sb
+	}
+	// bits 1-6 are unused on the DMG
+	return p.sc | 0x7E
+}
+
+func (p *Port) Write(addr uint16, val byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if addr == RegSB {
+		p.sb = val
+		return
+	}
+	p.sc = val & (scStart | scInternal)
+	if p.sc == scStart|scInternal {
+		p.remaining = transferCycles
+	}
+}
+
+//Step advances an internally clocked transfer by the given number of 4MHz cycles.
+// peers that keep time, such as a TCP link, are stepped too
+func (p *Port) Step(cycles int) {
+	p.mu.Lock()
+	peer := p.peer
+	p.mu.Unlock()
+	if c, ok := peer.(Clocked); ok {
+		c.Step(cycles)
+	}
+
+	p.mu.Lock()
+	if p.sc != scStart|scInternal {
+		p.mu.Unlock()
+		return
+	}
+	p.remaining -= cycles
+	if p.remaining > 0 {
+		p.mu.Unlock()
+		return
+	}
+	out := p.sb
+	// the lock isn't held while talking to the peer: it may be another port, which could be transferring to us
+	p.mu.Unlock()
+
+	in := byte(disconnected)
+	if peer != nil {
+		in = peer.Transfer(out)
+	}
+
+	p.mu.Lock()
+	p.complete(in)
+	p.mu.Unlock()
+}
+
+//Receive is the peer driving the clock: it shifts in the given byte and returns this side's byte.
+// the exchange only happens if this side has started a transfer on the external clock,
+// otherwise nothing is shifted and the peer sees a disconnected line
+func (p *Port) Receive(in byte) byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sc != scStart {
+		return disconnected
+	}
+	out := p.sb
+	p.complete(in)
+	return out
+}
+
+//complete finishes a transfer, raising the serial interrupt.  The lock must be held
+func (p *Port) complete(in byte) {
+	p.sb = in
+	p.sc &^= scStart
+	p.ram.RequestInterrupt(mem.IntSerial)
+}
+
+// Loopback is a cable plugged back into the same port: every byte sent is received
+type Loopback struct{}
+
+func (Loopback) Transfer(out byte) byte {
+	return out
+}
+
+//Link connects two ports as if by a cable, e.g. two GameBoys in the same process.
+// whichever side uses the internal clock drives the transfer
+func Link(a, b *Port) {
+	a.Connect(b.asPeer())
+	b.Connect(a.asPeer())
+}
+
+// portPeer adapts a Port to be the remote end of another port's cable
+type portPeer struct {
+	remote *Port
+}
+
+func (p *Port) asPeer() SerialPeer {
+	return portPeer{remote: p}
+}
+
+func (pp portPeer) Transfer(out byte) byte {
+	return pp.remote.Receive(out)
+}
+
+// Capture collects every byte sent, e.g. for test ROMs that report their results as text over serial.
+// it answers like a disconnected cable
+type Capture struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (c *Capture) Transfer(out byte) byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf = append(c.buf, out)
+	return disconnected
+}
+
+//Bytes returns a copy of everything sent so far
+func (c *Capture) Bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.buf...)
+}
+
+//String returns everything sent so far as text
+func (c *Capture) String() string {
+	return string(c.Bytes())
+}
+
+//Contains reports whether the given text has been sent
+func (c *Capture) Contains(s string) bool {
+	return strings.Contains(c.String(), s)
+}
+
+//Serialize saves or restores the registers and any transfer in progress.  What's plugged in isn't part of the state
+func (p *Port) Serialize(s *state.Serializer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.Uint8(&p.sb)
+	s.Uint8(&p.sc)
+	s.Int(&p.remaining)
+}
@@ -0,0 +1,74 @@
+package serial
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+//startTransfer writes SB and SC the way a game would
+func startTransfer(p *Port, data byte, internal bool) {
+	p.Write(RegSB, data)
+	sc := byte(scStart)
+	if internal {
+		sc |= scInternal
+	}
+	p.Write(RegSC, sc)
+}
+
+func TestPort_internalClockTakesEightBits(t *testing.T) {
+	ram := &mem.RAM{}
+	capture := &Capture{}
+	p := &Port{ram: ram, peer: capture}
+
+	startTransfer(p, 'A', true)
+	p.Step(transferCycles - 4)
+	assert.Equal(t, byte(0xFF), p.Read(RegSC))
+	assert.Zero(t, ram.Peek(mem.RegIF))
+
+	p.Step(4)
+	assert.Equal(t, byte(0x7F), p.Read(RegSC))
+	assert.Equal(t, byte(0xFF), p.Read(RegSB))
+	assert.Equal(t, byte(1<<mem.IntSerial), ram.Peek(mem.RegIF))
+	assert.Equal(t, "A", capture.String())
+}
+
+func TestPort_loopback(t *testing.T) {
+	p := &Port{ram: &mem.RAM{}, peer: Loopback{}}
+	startTransfer(p, 0x42, true)
+	p.Step(transferCycles)
+	assert.Equal(t, byte(0x42), p.Read(RegSB))
+}
+
+func TestLink_exchangesBytes(t *testing.T) {
+	masterRAM, slaveRAM := &mem.RAM{}, &mem.RAM{}
+	master := &Port{ram: masterRAM}
+	slave := &Port{ram: slaveRAM}
+	Link(master, slave)
+
+	// the slave waits on the external clock, and doesn't move on its own
+	startTransfer(slave, 0x22, false)
+	slave.Step(transferCycles)
+	assert.Equal(t, byte(0xFE), slave.Read(RegSC))
+
+	startTransfer(master, 0x11, true)
+	master.Step(transferCycles)
+	assert.Equal(t, byte(0x22), master.Read(RegSB))
+	assert.Equal(t, byte(0x11), slave.Read(RegSB))
+	assert.Equal(t, byte(0x7E), slave.Read(RegSC))
+	assert.Equal(t, byte(1<<mem.IntSerial), masterRAM.Peek(mem.RegIF))
+	assert.Equal(t, byte(1<<mem.IntSerial), slaveRAM.Peek(mem.RegIF))
+}
+
+func TestLink_slaveNotReady(t *testing.T) {
+	master := &Port{ram: &mem.RAM{}}
+	slave := &Port{ram: &mem.RAM{}}
+	Link(master, slave)
+	slave.Write(RegSB, 0x22)
+
+	startTransfer(master, 0x11, true)
+	master.Step(transferCycles)
+	assert.Equal(t, byte(0xFF), master.Read(RegSB))
+	assert.Equal(t, byte(0x22), slave.Read(RegSB))
+}
@@ -0,0 +1,222 @@
+package serial
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// DefaultQuantum is how many cycles each side of a TCP link may run before waiting for the other.
+// it's the length of one internally clocked transfer, so the clock master is never held up for long
+const DefaultQuantum = transferCycles
+
+// message types on the wire.  every message is a type byte followed by a big endian uint32
+const (
+	msgHello    = iota + 1 // payload: the listener's quantum
+	msgTransfer            // payload: the byte the clock master shifted out
+	msgReply               // payload: the byte the other side shifted back
+	msgSync                // payload: the sender's quantum count
+)
+
+const msgSize = 5
+
+// Clocked is implemented by peers that need to track emulated time, e.g. to stay in lockstep with a remote emulator.
+// the port passes every Step along to them
+type Clocked interface {
+	Step(cycles int)
+}
+
+// TCPLink is a link cable to another goby process.  One side listens and the other connects.
+//
+// both emulators run in deterministic lockstep: after every quantum of cycles, each side sends a sync
+// and waits for the other's.  whichever side drives the clock sends its byte and waits for the reply.
+// the other side only answers at its next sync point, handling messages strictly in order,
+// so a byte sent during quantum N always arrives at the end of the receiver's quantum N
+type TCPLink struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	port    *Port
+	quantum int
+	elapsed int    // cycles into the current quantum
+	seq     uint32 // quantums completed
+	peerSeq uint32 // the latest sync received from the other side
+
+	mu  sync.Mutex
+	err error
+}
+
+//Listen waits for another goby to connect on the given address, e.g. ":5000".
+// the link uses the given quantum, which is sent to the connecting side
+func Listen(addr string, port *Port, quantum int) (*TCPLink, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	return accept(ln, port, quantum)
+}
+
+func accept(ln net.Listener, port *Port, quantum int) (*TCPLink, error) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l := newTCPLink(conn, port, quantum)
+	if err := l.send(msgHello, uint32(quantum)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	port.Connect(l)
+	return l, nil
+}
+
+//Dial connects to a goby listening on the given address, e.g. "192.168.1.2:5000", and adopts its quantum
+func Dial(addr string, port *Port) (*TCPLink, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	l := newTCPLink(conn, port, 0)
+	typ, quantum, err := l.recv()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if typ != msgHello || quantum == 0 {
+		conn.Close()
+		return nil, fmt.Errorf("link: unexpected handshake from %s", addr)
+	}
+	l.quantum = int(quantum)
+	port.Connect(l)
+	return l, nil
+}
+
+func newTCPLink(conn net.Conn, port *Port, quantum int) *TCPLink {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		// every message is tiny and latency bound
+		tcp.SetNoDelay(true)
+	}
+	return &TCPLink{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		port:    port,
+		quantum: quantum,
+	}
+}
+
+//Err returns the error that broke the link, if any.  A broken link behaves like an unplugged cable
+func (l *TCPLink) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+func (l *TCPLink) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = err
+		l.conn.Close()
+	}
+}
+
+func (l *TCPLink) Close() error {
+	l.fail(io.ErrClosedPipe)
+	return nil
+}
+
+func (l *TCPLink) send(typ byte, payload uint32) error {
+	var msg [msgSize]byte
+	msg[0] = typ
+	binary.BigEndian.PutUint32(msg[1:], payload)
+	_, err := l.conn.Write(msg[:])
+	return err
+}
+
+func (l *TCPLink) recv() (byte, uint32, error) {
+	var msg [msgSize]byte
+	if _, err := io.ReadFull(l.r, msg[:]); err != nil {
+		return 0, 0, err
+	}
+	return msg[0], binary.BigEndian.Uint32(msg[1:]), nil
+}
+
+//Transfer sends the clock master's byte and waits for the other side's reply
+func (l *TCPLink) Transfer(out byte) byte {
+	if l.Err() != nil {
+		return disconnected
+	}
+	if err := l.send(msgTransfer, uint32(out)); err != nil {
+		l.fail(err)
+		return disconnected
+	}
+	for {
+		typ, payload, err := l.recv()
+		if err != nil {
+			l.fail(err)
+			return disconnected
+		}
+		switch typ {
+		case msgReply:
+			return byte(payload)
+		case msgSync:
+			// the other side reached its next sync point before answering. it can't get further ahead than that
+			l.peerSeq = payload
+		case msgTransfer:
+			// both sides are driving the clock, so neither receives anything meaningful
+			if err := l.send(msgReply, disconnected); err != nil {
+				l.fail(err)
+				return disconnected
+			}
+		default:
+			l.fail(fmt.Errorf("link: unexpected message %d while transferring", typ))
+			return disconnected
+		}
+	}
+}
+
+//Step counts emulated cycles, and waits for the other side at the end of every quantum
+func (l *TCPLink) Step(cycles int) {
+	if l.Err() != nil {
+		return
+	}
+	l.elapsed += cycles
+	for l.elapsed >= l.quantum {
+		l.elapsed -= l.quantum
+		if err := l.sync(); err != nil {
+			l.fail(err)
+			return
+		}
+	}
+}
+
+//sync announces the end of this quantum, then handles the other side's messages until it reaches the same point
+func (l *TCPLink) sync() error {
+	l.seq++
+	if err := l.send(msgSync, l.seq); err != nil {
+		return err
+	}
+	for l.peerSeq < l.seq {
+		typ, payload, err := l.recv()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case msgSync:
+			if payload != l.seq {
+				return fmt.Errorf("link: out of lockstep, at %d but peer is at %d", l.seq, payload)
+			}
+			l.peerSeq = payload
+		case msgTransfer:
+			if err := l.send(msgReply, uint32(l.port.Receive(byte(payload)))); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("link: unexpected message %d while syncing", typ)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package serial
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPLink_lockstepTransfer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	master := &Port{ram: &mem.RAM{}}
+	slave := &Port{ram: &mem.RAM{}}
+
+	var masterLink *TCPLink
+	var acceptErr error
+	accepted := make(chan struct{})
+	go func() {
+		masterLink, acceptErr = accept(ln, master, DefaultQuantum)
+		close(accepted)
+	}()
+	slaveLink, err := Dial(ln.Addr().String(), slave)
+	require.NoError(t, err)
+	<-accepted
+	require.NoError(t, acceptErr)
+	defer masterLink.Close()
+	defer slaveLink.Close()
+	assert.Equal(t, DefaultQuantum, slaveLink.quantum)
+
+	startTransfer(slave, 0x22, false)
+	startTransfer(master, 0x11, true)
+
+	// each side runs in its own goroutine, like two processes would
+	const cycles = DefaultQuantum * 4
+	var wg sync.WaitGroup
+	for _, p := range []*Port{master, slave} {
+		wg.Add(1)
+		go func(p *Port) {
+			defer wg.Done()
+			for i := 0; i < cycles; i += 4 {
+				p.Step(4)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	require.NoError(t, masterLink.Err())
+	require.NoError(t, slaveLink.Err())
+	assert.Equal(t, byte(0x22), master.Read(RegSB))
+	assert.Equal(t, byte(0x11), slave.Read(RegSB))
+	assert.Equal(t, uint32(4), masterLink.seq)
+	assert.Equal(t, uint32(4), slaveLink.seq)
+}
@@ -0,0 +1,100 @@
+package sgb
+
+//attrBlock handles ATTR_BLK: up to 18 rectangles, each coloring its inside, its border and/or its outside
+func (s *SGB) attrBlock(data []byte) {
+	sets := int(data[1] & 0x1F)
+	for i := 0; i < sets && 2+i*6+6 <= len(data); i++ {
+		set := data[2+i*6:]
+		ctrl, pals := set[0]&0x07, set[1]
+		x1, y1, x2, y2 := int(set[2]&0x1F), int(set[3]&0x1F), int(set[4]&0x1F), int(set[5]&0x1F)
+		inside, line, outside := pals&0x03, (pals>>2)&0x03, (pals>>4)&0x03
+		// with only one of inside and outside set, the border takes its palette too
+		switch ctrl {
+		case 0x01:
+			ctrl, line = 0x03, inside
+		case 0x04:
+			ctrl, line = 0x06, outside
+		}
+		for y := 0; y < cellsHigh; y++ {
+			for x := 0; x < cellsWide; x++ {
+				in := x > x1 && x < x2 && y > y1 && y < y2
+				on := !in && x >= x1 && x <= x2 && y >= y1 && y <= y2
+				switch {
+				case in && ctrl&0x01 != 0:
+					s.attrs[y][x] = inside
+				case on && ctrl&0x02 != 0:
+					s.attrs[y][x] = line
+				case !in && !on && ctrl&0x04 != 0:
+					s.attrs[y][x] = outside
+				}
+			}
+		}
+	}
+}
+
+//attrLine handles ATTR_LIN: whole rows or columns of cells set to one palette
+func (s *SGB) attrLine(data []byte) {
+	sets := int(data[1])
+	for i := 0; i < sets && 2+i < len(data); i++ {
+		set := data[2+i]
+		n, pal := int(set&0x1F), (set>>5)&0x03
+		if set&0x80 != 0 {
+			if n < cellsHigh {
+				for x := 0; x < cellsWide; x++ {
+					s.attrs[n][x] = pal
+				}
+			}
+		} else if n < cellsWide {
+			for y := 0; y < cellsHigh; y++ {
+				s.attrs[y][n] = pal
+			}
+		}
+	}
+}
+
+//attrDivide handles ATTR_DIV: the screen is split at a row or column, with separate palettes for either side
+// and for the dividing line itself
+func (s *SGB) attrDivide(data []byte) {
+	after, before, on := data[1]&0x03, (data[1]>>2)&0x03, (data[1]>>4)&0x03
+	horizontal := data[1]&0x40 != 0
+	at := int(data[2] & 0x1F)
+	for y := 0; y < cellsHigh; y++ {
+		for x := 0; x < cellsWide; x++ {
+			pos := x
+			if horizontal {
+				pos = y
+			}
+			switch {
+			case pos < at:
+				s.attrs[y][x] = before
+			case pos == at:
+				s.attrs[y][x] = on
+			default:
+				s.attrs[y][x] = after
+			}
+		}
+	}
+}
+
+//attrChar handles ATTR_CHR: palettes for individual cells, 4 per byte, from a starting cell
+// either left to right or top to bottom, wrapping at the screen edge
+func (s *SGB) attrChar(data []byte) {
+	x, y := int(data[1]), int(data[2])
+	count := int(data[3]) | int(data[4])<<8
+	vertical := data[5]&0x01 != 0
+	for i := 0; i < count && 6+i/4 < len(data); i++ {
+		if x >= cellsWide || y >= cellsHigh {
+			return
+		}
+		s.attrs[y][x] = (data[6+i/4] >> uint(6-(i%4)*2)) & 0x03
+		if vertical {
+			if y++; y == cellsHigh {
+				y = 0
+				x++
+			}
+		} else if x++; x == cellsWide {
+			x = 0
+			y++
+		}
+	}
+}
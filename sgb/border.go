@@ -0,0 +1,107 @@
+package sgb
+
+import "github.com/raidancampbell/goby/render"
+
+const (
+	// Width and Height are the SGB's output: the game screen, centered in a border
+	Width  = 256
+	Height = 224
+
+	screenX = (Width - render.ScreenWidth) / 2
+	screenY = (Height - render.ScreenHeight) / 2
+
+	mapWidth  = 32
+	mapHeight = 28
+)
+
+// border is drawn by the SNES: 256 4-bit tiles, a 32x28 map of them, and 4 palettes of 16 colors.
+// color 0 is transparent, so the game screen shows through the middle
+type border struct {
+	tiles    [256][32]byte
+	tilemap  [mapWidth * mapHeight]uint16
+	palettes [4][16]uint16 // SNES palettes 4-7
+	chrHigh  bool          // the pending CHR_TRN is for tiles 80-FF
+}
+
+//loadTiles handles CHR_TRN: 128 tiles, into either the low or high half
+func (b *border) loadTiles(data []byte) {
+	first := 0
+	if b.chrHigh {
+		first = 128
+	}
+	for i := 0; i < 128; i++ {
+		copy(b.tiles[first+i][:], data[i*32:])
+	}
+}
+
+//loadMap handles PCT_TRN: the tile map, followed by the palettes at 800
+func (b *border) loadMap(data []byte) {
+	for i := range b.tilemap {
+		b.tilemap[i] = uint16(data[i*2]) | uint16(data[i*2+1])<<8
+	}
+	for p := range b.palettes {
+		for c := range b.palettes[p] {
+			o := 0x800 + p*32 + c*2
+			b.palettes[p][c] = (uint16(data[o]) | uint16(data[o+1])<<8) & 0x7FFF
+		}
+	}
+}
+
+//pixel returns the color of the border at the given point, and false if it's transparent.
+// each map entry is a tile number, a palette in bits 10-12, and X and Y flips in bits 14 and 15
+func (b *border) pixel(x, y int) (uint16, bool) {
+	entry := b.tilemap[(y/8)*mapWidth+x/8]
+	row, col := y%8, x%8
+	if entry&0x4000 != 0 {
+		col = 7 - col
+	}
+	if entry&0x8000 != 0 {
+		row = 7 - row
+	}
+	// SNES 4bpp tiles: planes 0 and 1 interleaved by row, then planes 2 and 3
+	tile := &b.tiles[entry&0xFF]
+	bit := uint(7 - col)
+	colorID := (tile[row*2]>>bit)&1 |
+		(tile[row*2+1]>>bit)&1<<1 |
+		(tile[16+row*2]>>bit)&1<<2 |
+		(tile[16+row*2+1]>>bit)&1<<3
+	if colorID == 0 {
+		return 0, false
+	}
+	return b.palettes[(entry>>10)&0x03][colorID], true
+}
+
+//Frame colorizes the game screen by each cell's palette, and composites it into the border
+func (s *SGB) Frame(screen *render.ScreenBuffer) *render.Frame {
+	if s.mask != MaskFreeze {
+		for y := 0; y < render.ScreenHeight; y++ {
+			for x := 0; x < render.ScreenWidth; x++ {
+				switch s.mask {
+				case MaskBlack:
+					s.screen[y][x] = 0
+				case MaskColor0:
+					s.screen[y][x] = s.palettes[0][0]
+				default:
+					s.screen[y][x] = s.palettes[s.attrs[y/8][x/8]][screen[y][x]]
+				}
+			}
+		}
+	}
+
+	f := render.NewFrame(Width, Height)
+	backdrop := s.palettes[0][0]
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			color := backdrop
+			gx, gy := x-screenX, y-screenY
+			if gx >= 0 && gx < render.ScreenWidth && gy >= 0 && gy < render.ScreenHeight {
+				color = s.screen[gy][gx]
+			}
+			if c, ok := s.border.pixel(x, y); ok {
+				color = c
+			}
+			f.Pix[y*Width+x] = render.RGB15ToARGB(color)
+		}
+	}
+	return f
+}
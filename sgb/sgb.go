@@ -0,0 +1,263 @@
+package sgb
+
+import (
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/state"
+)
+
+// commands, from the top 5 bits of a packet's first byte
+const (
+	cmdPAL01   = 0x00
+	cmdPAL23   = 0x01
+	cmdPAL03   = 0x02
+	cmdPAL12   = 0x03
+	cmdATTRBLK = 0x04
+	cmdATTRLIN = 0x05
+	cmdATTRDIV = 0x06
+	cmdATTRCHR = 0x07
+	cmdPALSET  = 0x0A
+	cmdPALTRN  = 0x0B
+	cmdMLTREQ  = 0x11
+	cmdCHRTRN  = 0x13
+	cmdPCTTRN  = 0x14
+	cmdATTRTRN = 0x15
+	cmdATTRSET = 0x16
+	cmdMASKEN  = 0x17
+
+	packetSize = 16
+	// cells are the 8x8 pixel blocks that palettes are assigned to
+	cellsWide = 20
+	cellsHigh = 18
+	// atfSize is the length of one attribute file: 2 bits per cell
+	atfSize  = cellsWide * cellsHigh / 4
+	atfCount = 45
+)
+
+// mask modes, set by MASK_EN.  The game screen is hidden while it's redrawn
+const (
+	MaskOff    = 0
+	MaskFreeze = 1 // keep showing the last frame
+	MaskBlack  = 2
+	MaskColor0 = 3 // fill with color 0
+)
+
+// SGB is the Super Game Boy.  The game sends it command packets by writing bit patterns to P1,
+// which it uses to colorize the screen and draw a border around it.  Larger data, like the border itself,
+// is sent by displaying it: see Tiles
+type SGB struct {
+	// Tiles returns the data of the first 256 tiles on screen, which is what VRAM transfers read.
+	// it's called at the start of the frame after the transfer command
+	Tiles func() []byte
+	// OnPlayers is called when the game asks for 1, 2 or 4 controllers
+	OnPlayers func(n int)
+
+	// packet reception
+	lastP1    byte
+	receiving bool
+	bit       int
+	packet    [packetSize]byte
+	packets   [][packetSize]byte // the packets of a command so far
+
+	palettes [4][4]uint16               // the 4 palettes in use, 15-bit color
+	system   [512][4]uint16             // palettes sent by PAL_TRN, selected by PAL_SET
+	attrs    [cellsHigh][cellsWide]byte // palette of each cell
+	atfs     [atfCount][atfSize]byte    // attribute files sent by ATTR_TRN, applied by PAL_SET and ATTR_SET
+	mask     byte
+	screen   render.ColorFrameBuffer // the colorized game screen, kept while the mask freezes it
+
+	border   border
+	transfer byte // the last transfer command
+	pending  bool // the transfer is waiting for the next frame
+}
+
+//New creates an SGB showing the game in grayscale, with no border
+func New() *SGB {
+	s := &SGB{lastP1: 0x30}
+	for i := range s.palettes {
+		s.palettes[i] = [4]uint16{0x7FFF, 0x5294, 0x294A, 0x0000}
+	}
+	return s
+}
+
+//WriteP1 watches the joypad register for packets.  Pulling both P14 and P15 low starts a packet,
+// then each bit is sent by pulling one of them low: P14 for 0, P15 for 1.  Both go high between bits
+func (s *SGB) WriteP1(val byte) {
+	val &= 0x30
+	if val == s.lastP1 {
+		return
+	}
+	prev := s.lastP1
+	s.lastP1 = val
+	if prev != 0x30 {
+		return
+	}
+	switch val {
+	case 0x00:
+		s.receiving = true
+		s.bit = 0
+		s.packet = [packetSize]byte{}
+	case 0x10, 0x20:
+		if !s.receiving {
+			return
+		}
+		if val == 0x10 {
+			s.packet[s.bit/8] |= 1 << uint(s.bit%8)
+		}
+		s.bit++
+		if s.bit == packetSize*8 {
+			// the stop bit that follows is ignored, as nothing is receiving
+			s.receiving = false
+			s.receivePacket()
+		}
+	}
+}
+
+//receivePacket collects the packets of a command, running it once the last one arrives.
+// the first packet says how many there are in its low 3 bits
+func (s *SGB) receivePacket() {
+	s.packets = append(s.packets, s.packet)
+	count := int(s.packets[0][0] & 0x07)
+	if count == 0 {
+		count = 1
+	}
+	if len(s.packets) < count {
+		return
+	}
+	var data []byte
+	for _, p := range s.packets {
+		data = append(data, p[:]...)
+	}
+	s.packets = nil
+	s.run(data[0]>>3, data)
+}
+
+func (s *SGB) run(cmd byte, data []byte) {
+	switch cmd {
+	case cmdPAL01:
+		s.setPalettes(0, 1, data)
+	case cmdPAL23:
+		s.setPalettes(2, 3, data)
+	case cmdPAL03:
+		s.setPalettes(0, 3, data)
+	case cmdPAL12:
+		s.setPalettes(1, 2, data)
+	case cmdATTRBLK:
+		s.attrBlock(data)
+	case cmdATTRLIN:
+		s.attrLine(data)
+	case cmdATTRDIV:
+		s.attrDivide(data)
+	case cmdATTRCHR:
+		s.attrChar(data)
+	case cmdPALSET:
+		for i := range s.palettes {
+			n := (uint16(data[1+i*2]) | uint16(data[2+i*2])<<8) & 0x1FF
+			s.palettes[i] = s.system[n]
+		}
+		s.applyATF(data[9])
+	case cmdATTRSET:
+		s.applyATF(data[1] | 0x80)
+	case cmdMLTREQ:
+		if s.OnPlayers != nil {
+			s.OnPlayers([4]int{1, 2, 1, 4}[data[1]&0x03])
+		}
+	case cmdMASKEN:
+		s.mask = data[1] & 0x03
+	case cmdPALTRN, cmdCHRTRN, cmdPCTTRN, cmdATTRTRN:
+		s.transfer = cmd
+		s.pending = true
+		s.border.chrHigh = cmd == cmdCHRTRN && data[1]&0x01 != 0
+	}
+}
+
+//setPalettes handles PAL01, PAL23, PAL03 and PAL12: a shared color 0, then colors 1-3 of each palette
+func (s *SGB) setPalettes(a, b int, data []byte) {
+	color := func(i int) uint16 {
+		return (uint16(data[i]) | uint16(data[i+1])<<8) & 0x7FFF
+	}
+	for i := range s.palettes {
+		s.palettes[i][0] = color(1)
+	}
+	for c := 1; c < 4; c++ {
+		s.palettes[a][c] = color(1 + c*2)
+		s.palettes[b][c] = color(7 + c*2)
+	}
+}
+
+//applyATF copies attribute file n (bits 0-5) into the cell attributes if bit 7 is set.  bit 6 cancels the mask
+func (s *SGB) applyATF(val byte) {
+	if val&0x40 != 0 {
+		s.mask = MaskOff
+	}
+	if val&0x80 == 0 {
+		return
+	}
+	n := int(val & 0x3F)
+	if n >= atfCount {
+		return
+	}
+	for i := 0; i < cellsWide*cellsHigh; i++ {
+		b := s.atfs[n][i/4]
+		s.attrs[i/cellsWide][i%cellsWide] = (b >> uint(6-(i%4)*2)) & 0x03
+	}
+}
+
+//VBlank runs a transfer requested during the previous frame, reading the data the game is now displaying
+func (s *SGB) VBlank() {
+	if !s.pending || s.Tiles == nil {
+		return
+	}
+	s.pending = false
+	data := s.Tiles()
+	switch s.transfer {
+	case cmdPALTRN:
+		for i := range s.system {
+			for c := 0; c < 4; c++ {
+				o := i*8 + c*2
+				s.system[i][c] = (uint16(data[o]) | uint16(data[o+1])<<8) & 0x7FFF
+			}
+		}
+	case cmdATTRTRN:
+		for i := range s.atfs {
+			copy(s.atfs[i][:], data[i*atfSize:])
+		}
+	case cmdCHRTRN:
+		s.border.loadTiles(data)
+	case cmdPCTTRN:
+		s.border.loadMap(data)
+	}
+}
+
+//Serialize saves or restores palettes, attributes, the border and the mask.  A packet half received is dropped
+func (s *SGB) Serialize(st *state.Serializer) {
+	for i := range s.palettes {
+		st.Uint16s(s.palettes[i][:])
+	}
+	for i := range s.system {
+		st.Uint16s(s.system[i][:])
+	}
+	for y := range s.attrs {
+		st.Bytes(s.attrs[y][:])
+	}
+	for i := range s.atfs {
+		st.Bytes(s.atfs[i][:])
+	}
+	st.Uint8(&s.mask)
+	for y := range s.screen {
+		st.Uint16s(s.screen[y][:])
+	}
+	for i := range s.border.tiles {
+		st.Bytes(s.border.tiles[i][:])
+	}
+	st.Uint16s(s.border.tilemap[:])
+	for i := range s.border.palettes {
+		st.Uint16s(s.border.palettes[i][:])
+	}
+	st.Uint8(&s.transfer)
+	st.Bool(&s.pending)
+	st.Bool(&s.border.chrHigh)
+	if st.Loading() {
+		s.receiving = false
+		s.packets = nil
+	}
+}
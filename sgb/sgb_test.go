@@ -0,0 +1,91 @@
+package sgb
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/render"
+	"github.com/stretchr/testify/assert"
+)
+
+//send writes a command to P1 the way games do, one packet at a time
+func send(s *SGB, data []byte) {
+	for len(data)%packetSize != 0 {
+		data = append(data, 0)
+	}
+	for p := 0; p < len(data); p += packetSize {
+		s.WriteP1(0x00)
+		s.WriteP1(0x30)
+		for i := 0; i < packetSize*8; i++ {
+			if data[p+i/8]&(1<<uint(i%8)) != 0 {
+				s.WriteP1(0x10)
+			} else {
+				s.WriteP1(0x20)
+			}
+			s.WriteP1(0x30)
+		}
+		// stop bit
+		s.WriteP1(0x20)
+		s.WriteP1(0x30)
+	}
+}
+
+func TestSGB_palettes(t *testing.T) {
+	s := New()
+	send(s, []byte{cmdPAL01<<3 | 1, 0x1F, 0x00, 0xE0, 0x03, 0x00, 0x7C, 0x00, 0x00, 0xFF, 0x7F, 0x00, 0x00, 0x00, 0x00})
+	assert.Equal(t, [4]uint16{0x001F, 0x03E0, 0x7C00, 0x0000}, s.palettes[0])
+	assert.Equal(t, [4]uint16{0x001F, 0x7FFF, 0x0000, 0x0000}, s.palettes[1])
+	assert.Equal(t, uint16(0x001F), s.palettes[3][0])
+}
+
+func TestSGB_attrBlock(t *testing.T) {
+	s := New()
+	// one block from (2,2) to (5,5): inside palette 1, border palette 2, outside palette 3
+	send(s, []byte{cmdATTRBLK<<3 | 1, 1, 0x07, 0x39, 2, 2, 5, 5})
+	assert.Equal(t, byte(1), s.attrs[3][3])
+	assert.Equal(t, byte(2), s.attrs[2][4])
+	assert.Equal(t, byte(3), s.attrs[0][0])
+
+	// only the inside: the border is colored with it, the outside is untouched
+	send(s, []byte{cmdATTRBLK<<3 | 1, 1, 0x01, 0x00, 2, 2, 5, 5})
+	assert.Equal(t, byte(0), s.attrs[2][4])
+	assert.Equal(t, byte(3), s.attrs[0][0])
+}
+
+func TestSGB_multiplayer(t *testing.T) {
+	s := New()
+	players := 0
+	s.OnPlayers = func(n int) { players = n }
+	send(s, []byte{cmdMLTREQ<<3 | 1, 0x03})
+	assert.Equal(t, 4, players)
+}
+
+func TestSGB_border(t *testing.T) {
+	s := New()
+	data := make([]byte, 4096)
+	s.Tiles = func() []byte { return data }
+
+	// tile 1 is color 15 everywhere
+	for i := 32; i < 64; i++ {
+		data[i] = 0xFF
+	}
+	send(s, []byte{cmdCHRTRN<<3 | 1, 0})
+	s.VBlank()
+
+	// the top left corner uses tile 1 with palette 4, whose color 15 is red
+	data = make([]byte, 4096)
+	data[0], data[1] = 0x01, 0x10
+	data[0x800+15*2] = 0x1F
+	send(s, []byte{cmdPCTTRN<<3 | 1})
+	s.VBlank()
+
+	f := s.Frame(&render.ScreenBuffer{})
+	assert.Equal(t, uint32(0xFFFF0000), f.Pix[0])
+	// tile 0 is transparent, so the backdrop shows through
+	assert.Equal(t, uint32(0xFFFFFFFF), f.Pix[8])
+	// the game screen is in the middle, still in grayscale
+	assert.Equal(t, uint32(0xFFFFFFFF), f.Pix[screenY*Width+screenX])
+
+	send(s, []byte{cmdMASKEN<<3 | 1, MaskBlack})
+	f = s.Frame(&render.ScreenBuffer{})
+	assert.Equal(t, uint32(0xFF000000), f.Pix[screenY*Width+screenX])
+}
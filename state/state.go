@@ -0,0 +1,123 @@
+package state
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Serializer saves or restores state, depending on which way it was created.  Every component describes
+// its state once, in a Serialize method that passes pointers to its fields, and the same method does both.
+// the first error is kept and everything after it is skipped, so it only needs checking at the end
+type Serializer struct {
+	r   io.Reader
+	w   io.Writer
+	err error
+	buf [8]byte
+}
+
+// Serializable is anything that can be saved and restored
+type Serializable interface {
+	Serialize(s *Serializer)
+}
+
+//NewWriter creates a Serializer that saves to the given writer
+func NewWriter(w io.Writer) *Serializer {
+	return &Serializer{w: w}
+}
+
+//NewReader creates a Serializer that restores from the given reader
+func NewReader(r io.Reader) *Serializer {
+	return &Serializer{r: r}
+}
+
+//Loading returns whether state is being restored, for components that must recompute something afterwards
+func (s *Serializer) Loading() bool {
+	return s.r != nil
+}
+
+//Err returns the first error encountered, if any
+func (s *Serializer) Err() error {
+	return s.err
+}
+
+//Fail records an error, e.g. when restored state doesn't make sense.  Only the first error is kept
+func (s *Serializer) Fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+//Bytes saves or restores a byte slice in place.  The length isn't recorded, so it must be known on restore
+func (s *Serializer) Bytes(b []byte) {
+	if s.err != nil {
+		return
+	}
+	if s.Loading() {
+		_, s.err = io.ReadFull(s.r, b)
+	} else {
+		_, s.err = s.w.Write(b)
+	}
+}
+
+//fixed saves or restores an n byte value through the scratch buffer
+func (s *Serializer) fixed(n int, save func(b []byte), load func(b []byte)) {
+	if s.err != nil {
+		return
+	}
+	b := s.buf[:n]
+	if !s.Loading() {
+		save(b)
+	}
+	s.Bytes(b)
+	if s.Loading() && s.err == nil {
+		load(b)
+	}
+}
+
+func (s *Serializer) Uint8(v *uint8) {
+	s.fixed(1, func(b []byte) { b[0] = *v }, func(b []byte) { *v = b[0] })
+}
+
+func (s *Serializer) Uint16(v *uint16) {
+	s.fixed(2, func(b []byte) { binary.LittleEndian.PutUint16(b, *v) },
+		func(b []byte) { *v = binary.LittleEndian.Uint16(b) })
+}
+
+func (s *Serializer) Uint32(v *uint32) {
+	s.fixed(4, func(b []byte) { binary.LittleEndian.PutUint32(b, *v) },
+		func(b []byte) { *v = binary.LittleEndian.Uint32(b) })
+}
+
+func (s *Serializer) Uint64(v *uint64) {
+	s.fixed(8, func(b []byte) { binary.LittleEndian.PutUint64(b, *v) },
+		func(b []byte) { *v = binary.LittleEndian.Uint64(b) })
+}
+
+//Int is stored as 64 bits, so state is the same on every platform
+func (s *Serializer) Int(v *int) {
+	u := uint64(int64(*v))
+	s.Uint64(&u)
+	*v = int(int64(u))
+}
+
+func (s *Serializer) Bool(v *bool) {
+	var b uint8
+	if *v {
+		b = 1
+	}
+	s.Uint8(&b)
+	*v = b != 0
+}
+
+func (s *Serializer) Float64(v *float64) {
+	u := math.Float64bits(*v)
+	s.Uint64(&u)
+	*v = math.Float64frombits(u)
+}
+
+func (s *Serializer) Uint16s(v []uint16) {
+	for i := range v {
+		s.Uint16(&v[i])
+	}
+}
@@ -0,0 +1,157 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a label at a bank and address
+type Symbol struct {
+	Name string
+	Bank int
+	Addr uint16
+}
+
+// Table holds the labels from a symbol file, as written by RGBDS and read by no$gmb and BGB:
+// one "BB:AAAA Name" to a line, with ; comments.  Banks are only told apart in ROM, at 0000-7FFF,
+// since RAM labels are found by address alone.  a nil Table has no symbols
+type Table struct {
+	rom    map[int][]Symbol // by bank, sorted by address
+	ram    []Symbol         // sorted by address
+	byName map[string]Symbol
+}
+
+//Parse reads a symbol file
+func Parse(r io.Reader) (*Table, error) {
+	t := &Table{rom: map[int][]Symbol{}, byName: map[string]Symbol{}}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, ';'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected BB:AAAA Name, got %q", line, text)
+		}
+		location := strings.SplitN(fields[0], ":", 2)
+		if len(location) != 2 {
+			return nil, fmt.Errorf("line %d: expected BB:AAAA, got %q", line, fields[0])
+		}
+		bank, err := strconv.ParseUint(location[0], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad bank %q", line, location[0])
+		}
+		addr, err := strconv.ParseUint(location[1], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad address %q", line, location[1])
+		}
+		s := Symbol{Name: fields[1], Bank: int(bank), Addr: uint16(addr)}
+		if s.Addr < 0x8000 {
+			t.rom[s.Bank] = append(t.rom[s.Bank], s)
+		} else {
+			t.ram = append(t.ram, s)
+		}
+		t.byName[s.Name] = s
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, list := range t.rom {
+		sortByAddr(list)
+	}
+	sortByAddr(t.ram)
+	return t, nil
+}
+
+func sortByAddr(list []Symbol) {
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Addr < list[j].Addr })
+}
+
+//ForROM loads the symbol file next to a ROM, e.g. game.sym for game.gb.  If there isn't one, it returns nil and no error
+func ForROM(romPath string) (*Table, error) {
+	f, err := os.Open(strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sym")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+//Len returns how many symbols there are
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.byName)
+}
+
+//Lookup finds a symbol by name
+func (t *Table) Lookup(name string) (Symbol, bool) {
+	if t == nil {
+		return Symbol{}, false
+	}
+	s, ok := t.byName[name]
+	return s, ok
+}
+
+//before returns the last symbol at or before the address, in the same bank if it's in ROM
+func (t *Table) before(bank int, addr uint16) (Symbol, bool) {
+	if t == nil {
+		return Symbol{}, false
+	}
+	list := t.ram
+	if addr < 0x8000 {
+		list = t.rom[bank]
+	}
+	i := sort.Search(len(list), func(i int) bool { return list[i].Addr > addr })
+	if i == 0 {
+		return Symbol{}, false
+	}
+	return list[i-1], true
+}
+
+//At returns the name of the label exactly at the bank and address, or "" if there isn't one
+func (t *Table) At(bank int, addr uint16) string {
+	if s, ok := t.before(bank, addr); ok && s.Addr == addr {
+		return s.Name
+	}
+	return ""
+}
+
+//Describe names an address after the closest label before it in the same area of memory, e.g. Main.loop+$12.
+// it returns "" if there's no such label
+func (t *Table) Describe(bank int, addr uint16) string {
+	s, ok := t.before(bank, addr)
+	if !ok || region(s.Addr) != region(addr) {
+		return ""
+	}
+	if s.Addr == addr {
+		return s.Name
+	}
+	return fmt.Sprintf("%s+$%X", s.Name, addr-s.Addr)
+}
+
+//region returns which area of the memory map an address is in, so a label is never used to describe
+// an address past the end of its area
+func region(addr uint16) int {
+	bounds := []uint16{0x4000, 0x8000, 0xA000, 0xC000, 0xE000, 0xFE00, 0xFF00, 0xFF80}
+	for i, b := range bounds {
+		if addr < b {
+			return i
+		}
+	}
+	return len(bounds)
+}
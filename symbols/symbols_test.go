@@ -0,0 +1,71 @@
+package symbols
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sym = `; File generated by rgblink
+00:0100 Start
+00:0150 Main
+00:0158 Main.loop
+01:4000 Bank1Routine
+02:4000 Bank2Routine
+00:C000 wBuffer
+00:FF80 hFrameCounter
+`
+
+func TestParse(t *testing.T) {
+	table, err := Parse(strings.NewReader(sym))
+	require.NoError(t, err)
+	assert.Equal(t, 7, table.Len())
+
+	s, ok := table.Lookup("Main.loop")
+	require.True(t, ok)
+	assert.Equal(t, Symbol{Name: "Main.loop", Bank: 0, Addr: 0x0158}, s)
+
+	assert.Equal(t, "Main", table.At(0, 0x0150))
+	assert.Equal(t, "", table.At(0, 0x0151))
+	assert.Equal(t, "Main.loop+$12", table.Describe(0, 0x016A))
+	assert.Equal(t, "Bank2Routine+$3", table.Describe(2, 0x4003))
+	assert.Equal(t, "Bank1Routine", table.Describe(1, 0x4000))
+	assert.Equal(t, "", table.Describe(3, 0x4000), "no labels in bank 3")
+	// RAM banks aren't compared, but labels don't reach past their area of memory
+	assert.Equal(t, "wBuffer+$10", table.Describe(1, 0xC010))
+	assert.Equal(t, "hFrameCounter", table.Describe(0, 0xFF80))
+	assert.Equal(t, "", table.Describe(0, 0xFF40))
+	assert.Equal(t, "", table.Describe(0, 0x00FF))
+
+	var nothing *Table
+	assert.Equal(t, "", nothing.Describe(0, 0x0150))
+	_, ok = nothing.Lookup("Main")
+	assert.False(t, ok)
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, bad := range []string{"0150 Main", "00:XYZ Main", "00:0150", "00:0150 Main extra"} {
+		_, err := Parse(strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestForROM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symbols")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	table, err := ForROM(filepath.Join(dir, "game.gb"))
+	assert.NoError(t, err)
+	assert.Nil(t, table)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "game.sym"), []byte(sym), 0644))
+	table, err = ForROM(filepath.Join(dir, "game.gb"))
+	require.NoError(t, err)
+	assert.Equal(t, 7, table.Len())
+}
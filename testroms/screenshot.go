@@ -0,0 +1,105 @@
+package testroms
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+
+	"github.com/raidancampbell/goby/render"
+)
+
+// how the diff image shows pixels that differ
+var diffColor = color.RGBA{R: 0xFF, A: 0xFF}
+
+//screenshotResult compares the last frame a Screenshot ROM showed against its golden
+func screenshotResult(result Result, frame *render.Frame) Result {
+	result.Frame = frame
+	if frame == nil {
+		result.Status, result.Detail = Fail, "nothing was shown: the LCD was never turned on"
+		return result
+	}
+	golden, err := LoadPNG(result.ROM.Golden)
+	if err != nil {
+		result.Status, result.Detail = Fail, fmt.Sprintf("no golden image: %v", err)
+		return result
+	}
+	differ, diff, err := Compare(frame, golden, result.ROM.Tolerance)
+	if err != nil {
+		result.Status, result.Detail = Fail, err.Error()
+		return result
+	}
+	result.Diff = diff
+	if differ > 0 {
+		result.Status, result.Detail = Fail, fmt.Sprintf("%d of %d pixels differ from the golden image", differ, frame.Width*frame.Height)
+		return result
+	}
+	result.Status = Pass
+	return result
+}
+
+//Compare compares a frame against a golden image, pixel by pixel, allowing each color channel to be off by up to
+// tolerance.  It returns how many pixels differ, and an image showing them in red over a faded copy of the golden
+func Compare(frame *render.Frame, golden image.Image, tolerance uint8) (int, *image.RGBA, error) {
+	bounds := golden.Bounds()
+	if bounds.Dx() != frame.Width || bounds.Dy() != frame.Height {
+		return 0, nil, fmt.Errorf("the golden image is %dx%d, but the frame is %dx%d", bounds.Dx(), bounds.Dy(), frame.Width, frame.Height)
+	}
+	actual := frame.Image()
+	diff := image.NewRGBA(image.Rect(0, 0, frame.Width, frame.Height))
+	differ := 0
+	for y := 0; y < frame.Height; y++ {
+		for x := 0; x < frame.Width; x++ {
+			want := color.RGBAModel.Convert(golden.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.RGBA)
+			if !near(actual.RGBAAt(x, y), want, tolerance) {
+				differ++
+				diff.SetRGBA(x, y, diffColor)
+				continue
+			}
+			gray := color.GrayModel.Convert(want).(color.Gray).Y
+			faded := 0xC0 + gray/4
+			diff.SetRGBA(x, y, color.RGBA{R: faded, G: faded, B: faded, A: 0xFF})
+		}
+	}
+	return differ, diff, nil
+}
+
+//near returns whether every channel of two colors is within tolerance of the other.  Alpha is ignored
+func near(a, b color.RGBA, tolerance uint8) bool {
+	return within(a.R, b.R, tolerance) && within(a.G, b.G, tolerance) && within(a.B, b.B, tolerance)
+}
+
+func within(a, b, tolerance uint8) bool {
+	if a > b {
+		return a-b <= tolerance
+	}
+	return b-a <= tolerance
+}
+
+//LoadPNG reads a PNG, e.g. a golden image
+func LoadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return img, nil
+}
+
+//SavePNG writes an image as a PNG, e.g. a diff, or a frame as a new golden
+func SavePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
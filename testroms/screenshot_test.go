@@ -0,0 +1,160 @@
+package testroms
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/raidancampbell/goby/gameboy"
+	"github.com/raidancampbell/goby/render"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	update    = flag.Bool("update", false, "write each screenshot ROM's last frame as its golden image, instead of comparing")
+	tolerance = flag.Uint("tolerance", 2, "how far each color channel can be from the golden image's")
+	frames    = flag.Int("frames", 600, "how many frames each screenshot ROM runs for, unless it stops with LD B,B first")
+)
+
+// where frames and diffs of failed screenshot ROMs are written
+var outDir = filepath.Join("testdata", "out")
+
+//screenshotROMs finds the ROMs in testdata/screenshots.  Each one's golden image is next to it, e.g. dmg-acid2.png
+// for dmg-acid2.gb, and .gbc ROMs run on the CGB
+func screenshotROMs() []ROM {
+	var roms []ROM
+	filepath.Walk(filepath.Join("testdata", "screenshots"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".gb" && ext != ".gbc" {
+			return nil
+		}
+		model := gameboy.ModelDMG
+		if ext == ".gbc" {
+			model = gameboy.ModelCGB
+		}
+		roms = append(roms, ROM{
+			Path:      path,
+			Suite:     Screenshot,
+			Model:     model,
+			Frames:    *frames,
+			Golden:    strings.TrimSuffix(path, ext) + ".png",
+			Tolerance: uint8(*tolerance),
+		})
+		return nil
+	})
+	sort.Slice(roms, func(i, j int) bool { return roms[i].Path < roms[j].Path })
+	return roms
+}
+
+func TestScreenshots(t *testing.T) {
+	roms := screenshotROMs()
+	if len(roms) == 0 {
+		t.Skip("testdata/screenshots is empty, see testdata/README.md")
+	}
+	for _, rom := range roms {
+		rom := rom
+		name, _ := filepath.Rel(filepath.Join("testdata", "screenshots"), rom.Path)
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			result := Run(rom)
+			if *update && result.Frame != nil {
+				require.NoError(t, SavePNG(rom.Golden, result.Frame.Image()))
+				t.Logf("updated %s after %d frames", rom.Golden, result.Frames)
+				return
+			}
+			results = append(results, result)
+			if result.Status == Pass {
+				return
+			}
+			t.Errorf("%s after %d frames: %s", result.Status, result.Frames, result.Detail)
+			saveFailure(t, strings.TrimSuffix(name, filepath.Ext(name)), result)
+		})
+	}
+}
+
+//saveFailure writes what a failed screenshot ROM showed, and the diff against its golden, to testdata/out
+func saveFailure(t *testing.T, name string, result Result) {
+	base := filepath.Join(outDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		t.Log(err)
+		return
+	}
+	if result.Frame != nil {
+		if err := SavePNG(base+"-actual.png", result.Frame.Image()); err != nil {
+			t.Log(err)
+		}
+	}
+	if result.Diff != nil {
+		if err := SavePNG(base+"-diff.png", result.Diff); err != nil {
+			t.Log(err)
+		}
+		t.Logf("see %s-diff.png", base)
+	}
+}
+
+//lcdROM turns the LCD on, with every color the darkest, then halts
+func lcdROM(t *testing.T) string {
+	return writeROM(t, []byte{
+		0x3E, 0xFF, // LD A,$FF
+		0xE0, 0x47, // LDH (BGP),A
+		0x3E, 0x91, // LD A,$91
+		0xE0, 0x40, // LDH (LCDC),A
+		0x76, // HALT
+	})
+}
+
+func TestRun_screenshot(t *testing.T) {
+	path := lcdROM(t)
+	defer os.Remove(path)
+	dir, err := ioutil.TempDir("", "golden")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	rom := ROM{Path: path, Suite: Screenshot, Model: gameboy.ModelDMG, Frames: 3, Golden: filepath.Join(dir, "golden.png")}
+
+	result := Run(rom)
+	assert.Equal(t, Fail, result.Status)
+	assert.Contains(t, result.Detail, "no golden image")
+	require.NotNil(t, result.Frame)
+
+	require.NoError(t, SavePNG(rom.Golden, result.Frame.Image()))
+	result = Run(rom)
+	assert.Equal(t, Pass, result.Status, result.Detail)
+	assert.Equal(t, 3, result.Frames)
+
+	golden := result.Frame.Image()
+	golden.SetRGBA(10, 20, color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF})
+	require.NoError(t, SavePNG(rom.Golden, golden))
+	result = Run(rom)
+	assert.Equal(t, Fail, result.Status)
+	assert.Equal(t, "1 of 23040 pixels differ from the golden image", result.Detail)
+	require.NotNil(t, result.Diff)
+	assert.Equal(t, diffColor, result.Diff.RGBAAt(10, 20))
+}
+
+func TestCompare(t *testing.T) {
+	frame := render.NewFrame(2, 1)
+	frame.Pix[0], frame.Pix[1] = render.LIGHT_GRAY, render.DARK_GRAY
+	golden := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	golden.SetRGBA(0, 0, color.RGBA{R: 0xAA, G: 0xAA, B: 0xAA, A: 0xFF})
+	golden.SetRGBA(1, 0, color.RGBA{R: 0x55, G: 0x55, B: 0x55, A: 0xFF})
+
+	differ, _, err := Compare(frame, golden, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 0, differ)
+	differ, diff, err := Compare(frame, golden, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, differ)
+	assert.Equal(t, diffColor, diff.RGBAAt(0, 0))
+
+	_, _, err = Compare(render.NewFrame(3, 1), golden, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,217 @@
+// Package testroms runs the public test ROM suites, e.g. blargg's and mooneye's, headlessly, and works out whether
+// each passed from how it reports its result
+package testroms
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/cpu"
+	"github.com/raidancampbell/goby/gameboy"
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/serial"
+)
+
+// Suite is a family of test ROMs that report their results the same way
+type Suite int
+
+const (
+	// Blargg ROMs print their results over serial, ending in Passed or Failed
+	Blargg Suite = iota
+	// Mooneye ROMs run LD B,B once done, with B, C, D, E, H and L set to 3, 5, 8, 13, 21, 34 if they passed
+	Mooneye
+	// Screenshot ROMs, e.g. dmg-acid2, leave their result on screen, which is compared against a golden image.
+	// they run until LD B,B, or for all their frames if they never run it
+	Screenshot
+)
+
+func (s Suite) String() string {
+	switch s {
+	case Mooneye:
+		return "mooneye"
+	case Screenshot:
+		return "screenshot"
+	}
+	return "blargg"
+}
+
+// ROM is a test ROM to run
+type ROM struct {
+	Path   string
+	Suite  Suite
+	Model  gameboy.Model
+	Frames int // how long to give it before giving up
+	// Golden is the PNG a Screenshot ROM's last frame should match.  Tolerance is how far each color channel
+	// can be from it, for goldens from emulators with slightly different palettes
+	Golden    string
+	Tolerance uint8
+}
+
+// Status is how a test ROM's run ended
+type Status string
+
+const (
+	Pass    Status = "pass"
+	Fail    Status = "fail"
+	Timeout Status = "timeout" // it never reported a result
+	Crash   Status = "crash"   // the emulator stopped, e.g. on an opcode it doesn't implement yet
+)
+
+// Result is how a test ROM did
+type Result struct {
+	ROM    ROM
+	Status Status
+	// Detail says why it failed: what it printed, the registers it finished with, or what stopped the emulator
+	Detail string
+	// Output is everything the ROM sent over serial
+	Output string
+	Frames int // how many frames it ran for
+	// Frame is the last frame a Screenshot ROM showed, and Diff shows where it differs from the golden, in red
+	Frame *render.Frame
+	Diff  *image.RGBA
+}
+
+// mooneye's signatures, in B, C, D, E, H and L
+var (
+	fibonacci = [6]byte{3, 5, 8, 13, 21, 34}
+	failed    = [6]byte{0x42, 0x42, 0x42, 0x42, 0x42, 0x42}
+)
+
+// ldBB is LD B,B, which mooneye ROMs, and screenshot ROMs like dmg-acid2, run once they're done
+const ldBB = 0x40
+
+//Run runs a test ROM from startup, without a boot ROM, until it reports its result or runs out of frames
+func Run(rom ROM) (result Result) {
+	result.ROM = rom
+	defer func() {
+		if r := recover(); r != nil {
+			result.Status = Crash
+			result.Detail = fmt.Sprint(r)
+		}
+	}()
+	b, err := ioutil.ReadFile(rom.Path)
+	if err != nil {
+		return Result{ROM: rom, Status: Crash, Detail: err.Error()}
+	}
+	cart := cartridge.ROM(b)
+
+	display := &render.Headless{}
+	gb := gameboy.New(&cart, rom.Model, display)
+	capture := &serial.Capture{}
+	gb.Serial.Connect(capture)
+	var done bool
+	var regs cpu.Registers
+	if rom.Suite != Blargg {
+		gb.BeforeStep = func() bool {
+			regs = gb.CPU.Regs()
+			done = !regs.Halted && gb.RAM.Peek(regs.PC) == ldBB
+			return !done
+		}
+	}
+
+	result.Status = Timeout
+	for result.Frames < rom.Frames {
+		err := gb.RunFrame()
+		result.Frames++
+		result.Output = capture.String()
+		if err != nil && err != gameboy.ErrBreak {
+			result.Status, result.Detail = Crash, err.Error()
+			return result
+		}
+		switch rom.Suite {
+		case Blargg:
+			if strings.Contains(result.Output, "Passed") {
+				result.Status = Pass
+				return result
+			}
+			if strings.Contains(result.Output, "Failed") {
+				result.Status, result.Detail = Fail, summarize(result.Output)
+				return result
+			}
+		case Mooneye:
+			if done {
+				result.Status, result.Detail = mooneyeResult(regs)
+				return result
+			}
+		case Screenshot:
+			if done {
+				return screenshotResult(result, display.Last())
+			}
+		}
+	}
+	if rom.Suite == Screenshot {
+		return screenshotResult(result, display.Last())
+	}
+	result.Detail = summarize(result.Output)
+	return result
+}
+
+//mooneyeResult checks the registers a mooneye ROM finished with against the pass signature
+func mooneyeResult(r cpu.Registers) (Status, string) {
+	got := [6]byte{r.B, r.C, r.D, r.E, r.H, r.L}
+	if got == fibonacci {
+		return Pass, ""
+	}
+	detail := fmt.Sprintf("B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X", r.B, r.C, r.D, r.E, r.H, r.L)
+	if got == failed {
+		detail = "failed an assertion, " + detail
+	}
+	return Fail, detail
+}
+
+//summarize squashes what a ROM printed onto one line, keeping the end, which is where the result is
+func summarize(output string) string {
+	s := strings.Join(strings.Fields(output), " ")
+	const max = 100
+	if len(s) > max {
+		s = "..." + s[len(s)-max:]
+	}
+	return s
+}
+
+//MooneyeModel picks the model to run a mooneye ROM on, from the suffix of its name, e.g. boot_regs-dmgABC.gb.
+// it returns false for ROMs that only pass on hardware that isn't emulated, i.e. the DMG0 and AGS
+func MooneyeModel(path string) (gameboy.Model, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return gameboy.ModelDMG, true
+	}
+	switch suffix := name[i+1:]; {
+	case strings.HasPrefix(suffix, "dmg0"), strings.HasPrefix(suffix, "ags"):
+		return 0, false
+	case suffix == "mgb":
+		return gameboy.ModelMGB, true
+	case strings.HasPrefix(suffix, "agb"), suffix == "A":
+		return gameboy.ModelAGB, true
+	case strings.HasPrefix(suffix, "cgb"), suffix == "C":
+		return gameboy.ModelCGB, true
+	case strings.HasPrefix(suffix, "sgb"), suffix == "S":
+		return gameboy.ModelSGB, true
+	}
+	return gameboy.ModelDMG, true
+}
+
+//WriteReport writes a markdown table of how every ROM did, and how many passed
+func WriteReport(w io.Writer, results []Result) error {
+	var b strings.Builder
+	passed := 0
+	for _, r := range results {
+		if r.Status == Pass {
+			passed++
+		}
+	}
+	fmt.Fprintf(&b, "# Test ROM compatibility\n\n%d of %d passed\n\n", passed, len(results))
+	fmt.Fprintf(&b, "| ROM | suite | result | frames | detail |\n|---|---|---|---|---|\n")
+	for _, r := range results {
+		detail := strings.Replace(r.Detail, "|", `\|`, -1)
+		fmt.Fprintf(&b, "| %s | %s | %s | %d | %s |\n", filepath.ToSlash(r.ROM.Path), r.ROM.Suite, r.Status, r.Frames, detail)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,179 @@
+package testroms
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/raidancampbell/goby/gameboy"
+	"github.com/raidancampbell/goby/internal/testrom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var reportPath = flag.String("report", filepath.Join("testdata", "report.md"), "where to write how each test ROM did")
+
+// blargg's ROMs, and how long each takes on hardware, with some to spare
+var blargg = []ROM{
+	{Path: "blargg/cpu_instrs/cpu_instrs.gb", Frames: 4000},
+	{Path: "blargg/instr_timing/instr_timing.gb", Frames: 600},
+	{Path: "blargg/mem_timing/mem_timing.gb", Frames: 600},
+	{Path: "blargg/halt_bug.gb", Frames: 600},
+}
+
+// results collects every ROM that was run, for the report
+var results []Result
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	if len(results) > 0 && *reportPath != "" {
+		f, err := os.Create(*reportPath)
+		if err == nil {
+			err = WriteReport(f, results)
+			f.Close()
+		}
+		if err != nil {
+			os.Stderr.WriteString("writing report: " + err.Error() + "\n")
+		}
+	}
+	os.Exit(code)
+}
+
+//check runs a ROM from testdata as a subtest, skipping it if it isn't there
+func check(t *testing.T, rom ROM) {
+	t.Run(rom.Path, func(t *testing.T) {
+		rom.Path = filepath.Join("testdata", filepath.FromSlash(rom.Path))
+		if _, err := os.Stat(rom.Path); err != nil {
+			t.Skipf("%s is missing, see testdata/README.md", rom.Path)
+		}
+		result := Run(rom)
+		results = append(results, result)
+		if result.Status != Pass {
+			t.Errorf("%s after %d frames: %s", result.Status, result.Frames, result.Detail)
+		}
+	})
+}
+
+func TestBlargg(t *testing.T) {
+	for _, rom := range blargg {
+		rom.Suite = Blargg
+		rom.Model = gameboy.ModelDMG
+		check(t, rom)
+	}
+}
+
+func TestMooneye(t *testing.T) {
+	var paths []string
+	filepath.Walk(filepath.Join("testdata", "mooneye"), func(path string, info os.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case info.IsDir() && (info.Name() == "manual-only" || info.Name() == "utils"):
+			// these need someone to look at the screen, or don't test anything
+			return filepath.SkipDir
+		case strings.HasSuffix(path, ".gb"):
+			rel, _ := filepath.Rel("testdata", path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if len(paths) == 0 {
+		t.Skip("testdata/mooneye is missing, see testdata/README.md")
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		model, ok := MooneyeModel(path)
+		if !ok {
+			continue
+		}
+		check(t, ROM{Path: path, Suite: Mooneye, Model: model, Frames: 1200})
+	}
+}
+
+//writeROM writes a cartridge that runs the given code at 0100, then halts, to a temporary file
+func writeROM(t *testing.T, code []byte) string {
+	f, err := ioutil.TempFile("", "testrom*.gb")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(*testrom.New(code...))
+	require.NoError(t, err)
+	return f.Name()
+}
+
+//mooneyeROM loads the given registers, then runs LD B,B
+func mooneyeROM(t *testing.T, b, c, d, e, h, l byte) string {
+	return writeROM(t, []byte{
+		0x06, b, // LD B,b
+		0x0E, c, // LD C,c
+		0x16, d, // LD D,d
+		0x1E, e, // LD E,e
+		0x3E, h, // LD A,h
+		0x67,    // LD H,A
+		0x2E, l, // LD L,l
+		0x40, // LD B,B
+	})
+}
+
+func TestRun_mooneye(t *testing.T) {
+	pass := mooneyeROM(t, 3, 5, 8, 13, 21, 34)
+	defer os.Remove(pass)
+	result := Run(ROM{Path: pass, Suite: Mooneye, Model: gameboy.ModelDMG, Frames: 10})
+	assert.Equal(t, Pass, result.Status, result.Detail)
+	assert.Equal(t, 1, result.Frames)
+
+	fail := mooneyeROM(t, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42)
+	defer os.Remove(fail)
+	result = Run(ROM{Path: fail, Suite: Mooneye, Model: gameboy.ModelDMG, Frames: 10})
+	assert.Equal(t, Fail, result.Status)
+	assert.Equal(t, "failed an assertion, B:42 C:42 D:42 E:42 H:42 L:42", result.Detail)
+}
+
+func TestRun_timeout(t *testing.T) {
+	// blargg's ROMs print over serial, and this one never does
+	halts := writeROM(t, nil)
+	defer os.Remove(halts)
+	result := Run(ROM{Path: halts, Suite: Blargg, Model: gameboy.ModelDMG, Frames: 3})
+	assert.Equal(t, Timeout, result.Status)
+	assert.Equal(t, 3, result.Frames)
+}
+
+func TestMooneyeModel(t *testing.T) {
+	for path, want := range map[string]gameboy.Model{
+		"acceptance/ei_timing.gb":               gameboy.ModelDMG,
+		"acceptance/boot_regs-dmgABC.gb":        gameboy.ModelDMG,
+		"acceptance/boot_div-cgbABCDE.gb":       gameboy.ModelCGB,
+		"acceptance/boot_hwio-C.gb":             gameboy.ModelCGB,
+		"acceptance/boot_regs-sgb.gb":           gameboy.ModelSGB,
+		"acceptance/boot_div-S.gb":              gameboy.ModelSGB,
+		"acceptance/boot_hwio-dmgABCmgb.gb":     gameboy.ModelDMG,
+		"acceptance/boot_regs-mgb.gb":           gameboy.ModelMGB,
+		"acceptance/boot_regs-A.gb":             gameboy.ModelAGB,
+		"misc/boot_div-A.gb":                    gameboy.ModelAGB,
+		"acceptance/boot_regs-agb.gb":           gameboy.ModelAGB,
+		"acceptance/timer/tima_write_reload.gb": gameboy.ModelDMG,
+	} {
+		model, ok := MooneyeModel(path)
+		assert.True(t, ok, path)
+		assert.Equal(t, want, model, path)
+	}
+	for _, path := range []string{"acceptance/boot_regs-dmg0.gb", "acceptance/boot_div-dmg0.gb", "acceptance/boot_regs-ags.gb"} {
+		_, ok := MooneyeModel(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, WriteReport(&b, []Result{
+		{ROM: ROM{Path: "a.gb"}, Status: Pass, Frames: 10},
+		{ROM: ROM{Path: "b.gb", Suite: Mooneye}, Status: Crash, Detail: "unable to find opcode d3 | oops"},
+	}))
+	assert.Contains(t, b.String(), "1 of 2 passed\n")
+	assert.Contains(t, b.String(), "| a.gb | blargg | pass | 10 |  |\n")
+	assert.Contains(t, b.String(), `| b.gb | mooneye | crash | 0 | unable to find opcode d3 \| oops |`)
+}
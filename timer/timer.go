@@ -0,0 +1,104 @@
+package timer
+
+import (
+	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/state"
+)
+
+const (
+	RegDIV  = 0xFF04 // divider: the upper byte of a free-running 16-bit counter
+	RegTIMA = 0xFF05 // timer counter
+	RegTMA  = 0xFF06 // timer modulo: reloaded into TIMA when it overflows
+	RegTAC  = 0xFF07 // timer control
+
+	tacEnable = 0x04
+)
+
+// tacBits are the bits of the internal counter whose falling edge increments TIMA, selected by TAC bits 0-1:
+// 4096Hz, 262144Hz, 65536Hz and 16384Hz
+var tacBits = [4]uint16{1 << 9, 1 << 3, 1 << 5, 1 << 7}
+
+// Timer is DIV, TIMA, TMA and TAC.  It's clocked by the CPU, so in CGB double speed mode it runs twice as fast
+type Timer struct {
+	ram     *mem.RAM
+	counter uint16
+	tima    byte
+	tma     byte
+	tac     byte
+}
+
+//New creates a timer and maps it into memory at FF04-FF07
+func New(ram *mem.RAM) *Timer {
+	t := &Timer{ram: ram}
+	ram.Map(RegDIV, RegTAC, t)
+	return t
+}
+
+//Step advances the timer by the given number of CPU cycles
+func (t *Timer) Step(cycles int) {
+	for i := 0; i < cycles; i += 4 {
+		t.setCounter(t.counter + 4)
+	}
+}
+
+//setCounter updates the internal counter, incrementing TIMA on a falling edge of the selected bit.
+// this is also why writing DIV can increment TIMA
+func (t *Timer) setCounter(counter uint16) {
+	before := t.input()
+	t.counter = counter
+	if before && !t.input() {
+		t.incrementTIMA()
+	}
+}
+
+//input is the selected counter bit, ANDed with the timer enable
+func (t *Timer) input() bool {
+	return t.tac&tacEnable != 0 && t.counter&tacBits[t.tac&0x03] != 0
+}
+
+func (t *Timer) incrementTIMA() {
+	t.tima++
+	if t.tima == 0 {
+		t.tima = t.tma
+		t.ram.RequestInterrupt(mem.IntTimer)
+	}
+}
+
+func (t *Timer) Read(addr uint16) byte {
+	switch addr {
+	case RegDIV:
+		return byte(t.counter >> 8)
+	case RegTIMA:
+		return t.tima
+	case RegTMA:
+		return t.tma
+	default:
+		return 0xF8 | t.tac
+	}
+}
+
+func (t *Timer) Write(addr uint16, val byte) {
+	switch addr {
+	case RegDIV:
+		// any write resets the whole counter
+		t.setCounter(0)
+	case RegTIMA:
+		t.tima = val
+	case RegTMA:
+		t.tma = val
+	default:
+		// changing the selected bit or disabling the timer is a falling edge too, if the old input was high
+		before := t.input()
+		t.tac = val & 0x07
+		if before && !t.input() {
+			t.incrementTIMA()
+		}
+	}
+}
+
+func (t *Timer) Serialize(s *state.Serializer) {
+	s.Uint16(&t.counter)
+	s.Uint8(&t.tima)
+	s.Uint8(&t.tma)
+	s.Uint8(&t.tac)
+}
@@ -0,0 +1,78 @@
+package timer
+
+import (
+	"testing"
+
+	"github.com/raidancampbell/goby/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimer_divReset(t *testing.T) {
+	ram := &mem.RAM{}
+	tm := New(ram)
+	tm.Step(0x1234)
+	assert.Equal(t, byte(0x12), ram.Peek(RegDIV))
+
+	// whatever's written, the whole counter goes back to 0
+	ram.WriteByte(RegDIV, 0x99)
+	assert.Equal(t, byte(0x00), ram.Peek(RegDIV))
+	tm.Step(0xFC)
+	assert.Equal(t, byte(0x00), ram.Peek(RegDIV), "the low byte was reset too")
+	tm.Step(4)
+	assert.Equal(t, byte(0x01), ram.Peek(RegDIV))
+}
+
+func TestTimer_rates(t *testing.T) {
+	for tac, period := range map[byte]int{0x04: 1024, 0x05: 16, 0x06: 64, 0x07: 256} {
+		ram := &mem.RAM{}
+		tm := New(ram)
+		ram.WriteByte(RegTAC, tac)
+		tm.Step(period - 4)
+		assert.Equal(t, byte(0), ram.Peek(RegTIMA), "TAC %02x", tac)
+		tm.Step(4)
+		assert.Equal(t, byte(1), ram.Peek(RegTIMA), "TAC %02x", tac)
+		tm.Step(period * 9)
+		assert.Equal(t, byte(10), ram.Peek(RegTIMA), "TAC %02x", tac)
+	}
+
+	// without the enable bit, TIMA stays put
+	ram := &mem.RAM{}
+	tm := New(ram)
+	ram.WriteByte(RegTAC, 0x01)
+	tm.Step(1024)
+	assert.Equal(t, byte(0), ram.Peek(RegTIMA))
+	assert.Equal(t, byte(0xF9), ram.Peek(RegTAC), "the unused bits read 1")
+}
+
+func TestTimer_overflow(t *testing.T) {
+	ram := &mem.RAM{}
+	tm := New(ram)
+	ram.WriteByte(RegTMA, 0xAB)
+	ram.WriteByte(RegTIMA, 0xFE)
+	ram.WriteByte(RegTAC, 0x05)
+
+	tm.Step(16)
+	assert.Equal(t, byte(0xFF), ram.Peek(RegTIMA))
+	assert.Zero(t, ram.Peek(mem.RegIF))
+
+	tm.Step(16)
+	assert.Equal(t, byte(0xAB), ram.Peek(RegTIMA), "TIMA is reloaded from TMA")
+	assert.Equal(t, byte(1<<mem.IntTimer), ram.Peek(mem.RegIF))
+}
+
+func TestTimer_divResetFallingEdge(t *testing.T) {
+	ram := &mem.RAM{}
+	tm := New(ram)
+	ram.WriteByte(RegTAC, 0x05) // bit 3 of the counter
+	tm.Step(8)
+	assert.Equal(t, byte(0), ram.Peek(RegTIMA))
+
+	// the selected bit is set, so resetting the counter drops it, which counts as an edge
+	ram.WriteByte(RegDIV, 0)
+	assert.Equal(t, byte(1), ram.Peek(RegTIMA))
+
+	// with the bit clear, there's no edge
+	tm.Step(4)
+	ram.WriteByte(RegDIV, 0)
+	assert.Equal(t, byte(1), ram.Peek(RegTIMA))
+}
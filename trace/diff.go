@@ -0,0 +1,203 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Diff compares a trace, as it's written, against a reference log from another emulator, line by line, and finds the
+// first line where they differ.  Lines are compared field by field, e.g. A:01, ignoring case, anything after a ;,
+// and fields the reference doesn't have, so logs without PCMEM can still be compared
+type Diff struct {
+	// Mismatch is the first difference, once found
+	Mismatch *Mismatch
+	// Lines is how many lines were compared
+	Lines int
+
+	ref     *bufio.Scanner
+	refDone bool
+	context int
+	before  []string // the last context lines, which matched
+	partial []byte   // the start of a line that hasn't been finished yet
+}
+
+// Mismatch is where a trace first differs from the reference
+type Mismatch struct {
+	Line            int
+	Before          []string // the lines before it, which matched
+	Ours, Reference string
+	Deltas          []Delta
+	// the lines after it, up to the context asked for
+	OursAfter, ReferenceAfter []string
+}
+
+// Delta is a field that differs, e.g. a register
+type Delta struct {
+	Field           string
+	Ours, Reference string
+}
+
+//NewDiff compares against the given reference log, keeping context lines either side of the first difference
+func NewDiff(reference io.Reader, context int) *Diff {
+	ref := bufio.NewScanner(reference)
+	ref.Buffer(nil, 1<<20)
+	return &Diff{ref: ref, context: context}
+}
+
+//OpenLog opens a reference log, decompressing it if it's gzipped
+func OpenLog(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(2)
+	if !bytes.Equal(magic, []byte{0x1F, 0x8B}) {
+		return struct {
+			io.Reader
+			io.Closer
+		}{br, f}, nil
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+//Done returns whether there's nothing more to compare: the reference has run out,
+// or the first difference was found, along with the lines after it
+func (d *Diff) Done() bool {
+	if d.refDone {
+		return true
+	}
+	return d.Mismatch != nil && len(d.Mismatch.OursAfter) >= d.context
+}
+
+//Recent returns the last lines that matched, up to the context asked for
+func (d *Diff) Recent() []string {
+	return d.before
+}
+
+//ReferenceEnded returns whether every line of the reference was compared
+func (d *Diff) ReferenceEnded() bool {
+	return d.refDone
+}
+
+//Write takes trace lines, as written by a Tracer.  It never fails
+func (d *Diff) Write(p []byte) (int, error) {
+	d.partial = append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(d.partial, '\n')
+		if i < 0 {
+			break
+		}
+		d.line(string(d.partial[:i]))
+		d.partial = d.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (d *Diff) line(ours string) {
+	if d.Done() {
+		return
+	}
+	var ref string
+	if d.ref.Scan() {
+		ref = d.ref.Text()
+	} else {
+		d.refDone = d.Mismatch == nil
+		if d.refDone {
+			return
+		}
+	}
+
+	if m := d.Mismatch; m != nil {
+		m.OursAfter = append(m.OursAfter, ours)
+		if ref != "" {
+			m.ReferenceAfter = append(m.ReferenceAfter, ref)
+		}
+		return
+	}
+
+	d.Lines++
+	deltas := compare(ours, ref)
+	if len(deltas) == 0 {
+		d.before = append(d.before, ours)
+		if len(d.before) > d.context {
+			d.before = d.before[1:]
+		}
+		return
+	}
+	d.Mismatch = &Mismatch{Line: d.Lines, Before: d.before, Ours: ours, Reference: ref, Deltas: deltas}
+}
+
+//fields splits a log line into its fields, e.g. A:01 becomes A -> 01
+func fields(line string) (names []string, values map[string]string) {
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	values = map[string]string{}
+	for _, f := range strings.Fields(strings.ToUpper(line)) {
+		kv := strings.SplitN(f, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		names = append(names, kv[0])
+		values[kv[0]] = kv[1]
+	}
+	return names, values
+}
+
+//compare returns the fields of the reference line that our line has differently, or doesn't have
+func compare(ours, ref string) []Delta {
+	_, ourValues := fields(ours)
+	names, refValues := fields(ref)
+	var deltas []Delta
+	for _, name := range names {
+		if ourValues[name] != refValues[name] {
+			deltas = append(deltas, Delta{Field: name, Ours: ourValues[name], Reference: refValues[name]})
+		}
+	}
+	if len(names) == 0 && strings.TrimSpace(ref) != strings.TrimSpace(ours) {
+		// not a line of fields: compare it whole
+		deltas = append(deltas, Delta{Field: "line", Ours: ours, Reference: ref})
+	}
+	return deltas
+}
+
+//WriteTo describes the mismatch: the lines that matched before it, both versions of the line,
+// the fields that differ, and what each did next
+func (m *Mismatch) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "first difference at line %d\n", m.Line)
+	for i, line := range m.Before {
+		fmt.Fprintf(&b, "           %8d  %s\n", m.Line-len(m.Before)+i, line)
+	}
+	fmt.Fprintf(&b, "  ours     %8d  %s\n", m.Line, m.Ours)
+	fmt.Fprintf(&b, "  expected %8d  %s\n", m.Line, m.Reference)
+	for _, d := range m.Deltas {
+		fmt.Fprintf(&b, "  %s: %s, expected %s\n", d.Field, d.Ours, d.Reference)
+	}
+	if len(m.OursAfter) > 0 || len(m.ReferenceAfter) > 0 {
+		fmt.Fprintf(&b, "then ours:\n")
+		for i, line := range m.OursAfter {
+			fmt.Fprintf(&b, "           %8d  %s\n", m.Line+1+i, line)
+		}
+		fmt.Fprintf(&b, "then expected:\n")
+		for i, line := range m.ReferenceAfter {
+			fmt.Fprintf(&b, "           %8d  %s\n", m.Line+1+i, line)
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
@@ -0,0 +1,84 @@
+package trace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var reference = []string{
+	"A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02",
+	"A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0101 PCMEM:C3,13,02,CE",
+	"A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0213 PCMEM:3E,02,00,00",
+	"A:02 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0215 PCMEM:00,00,00,00",
+	"A:02 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0216 PCMEM:00,00,00,00",
+}
+
+func TestDiff(t *testing.T) {
+	ours := append([]string(nil), reference...)
+	ours[3] = "A:03 F:80 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0215 PCMEM:00,00,00,00"
+	// reference logs without PCMEM, and in lower case, still compare
+	var ref []string
+	for _, line := range reference {
+		ref = append(ref, strings.ToLower(line[:strings.Index(line, " PCMEM")]))
+	}
+
+	d := NewDiff(strings.NewReader(strings.Join(ref, "\n")), 2)
+	for _, line := range ours {
+		assert.False(t, d.Done())
+		d.Write([]byte(line + "\n"))
+	}
+	require.NotNil(t, d.Mismatch)
+	m := d.Mismatch
+	assert.Equal(t, 4, m.Line)
+	assert.Equal(t, ours[1:3], m.Before)
+	assert.Equal(t, []Delta{{"A", "03", "02"}, {"F", "80", "B0"}}, m.Deltas)
+	assert.Equal(t, ours[4:], m.OursAfter)
+	assert.Equal(t, ref[4:], m.ReferenceAfter)
+
+	var out bytes.Buffer
+	m.WriteTo(&out)
+	assert.Contains(t, out.String(), "first difference at line 4\n")
+	assert.Contains(t, out.String(), "  A: 03, expected 02\n  F: 80, expected B0\n")
+}
+
+func TestDiff_match(t *testing.T) {
+	d := NewDiff(strings.NewReader(strings.Join(reference[:2], "\n")), 2)
+	// lines can arrive in pieces
+	all := strings.Join(reference, "\n") + "\n"
+	d.Write([]byte(all[:10]))
+	d.Write([]byte(all[10:]))
+	assert.True(t, d.Done())
+	assert.True(t, d.ReferenceEnded())
+	assert.Nil(t, d.Mismatch)
+	assert.Equal(t, 2, d.Lines)
+}
+
+func TestOpenLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("A:01\n"))
+	w.Close()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ref.log.gz"), gz.Bytes(), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ref.log"), []byte("A:01\n"), 0644))
+
+	for _, name := range []string{"ref.log.gz", "ref.log"} {
+		r, err := OpenLog(filepath.Join(dir, name))
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "A:01\n", string(data), name)
+	}
+}
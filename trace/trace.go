@@ -0,0 +1,187 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/raidancampbell/goby/cpu"
+	"github.com/raidancampbell/goby/disasm"
+	"github.com/raidancampbell/goby/mem"
+	"github.com/raidancampbell/goby/symbols"
+)
+
+// Range is a range of addresses, inclusive at both ends
+type Range struct {
+	From, To uint16
+}
+
+// Filter picks which instructions are traced.  Empty fields don't filter anything
+type Filter struct {
+	PC []Range
+	// Banks only lets through code in these ROM banks.  0000-3FFF is bank 0, and code in RAM is never in one
+	Banks   []int
+	Opcodes []byte
+}
+
+func (f Filter) match(pc uint16, bank int, op byte) bool {
+	if len(f.PC) > 0 {
+		found := false
+		for _, r := range f.PC {
+			found = found || pc >= r.From && pc <= r.To
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Banks) > 0 {
+		found := false
+		for _, b := range f.Banks {
+			found = found || bank == b
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Opcodes) > 0 {
+		found := false
+		for _, o := range f.Opcodes {
+			found = found || op == o
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+//ParseFilter reads a filter from comma separated lists, as given on the command line, all in hex:
+// PC ranges, e.g. 0150-01FF,4000; banks, e.g. 1,2; and opcodes, e.g. CD,C9.  Empty lists don't filter anything
+func ParseFilter(pcs, banks, opcodes string) (Filter, error) {
+	var f Filter
+	for _, s := range list(pcs) {
+		bounds := strings.SplitN(s, "-", 2)
+		from, err := parseHex(bounds[0], 16)
+		if err != nil {
+			return Filter{}, err
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = parseHex(bounds[1], 16); err != nil {
+				return Filter{}, err
+			}
+		}
+		if to < from {
+			return Filter{}, fmt.Errorf("PC range %s ends before it starts", s)
+		}
+		f.PC = append(f.PC, Range{From: uint16(from), To: uint16(to)})
+	}
+	for _, s := range list(banks) {
+		b, err := parseHex(s, 16)
+		if err != nil {
+			return Filter{}, err
+		}
+		f.Banks = append(f.Banks, int(b))
+	}
+	for _, s := range list(opcodes) {
+		op, err := parseHex(s, 8)
+		if err != nil {
+			return Filter{}, err
+		}
+		f.Opcodes = append(f.Opcodes, byte(op))
+	}
+	return f, nil
+}
+
+func list(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func parseHex(s string, bits int) (uint64, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(s, "$"), "0x"), 16, bits)
+	if err != nil {
+		return 0, fmt.Errorf("bad hex number %q", s)
+	}
+	return v, nil
+}
+
+// Tracer logs every instruction the CPU runs, one line each, with the registers before it and the 4 bytes at PC:
+//  A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+// which is the format other emulators' logs, and Gameboy Doctor, use, so they can be compared line by line.
+// hook it up with gb.CPU.Trace = tracer.Instruction
+type Tracer struct {
+	Filter Filter
+	// Annotate adds the disassembled instruction after each line, and its symbol if Symbols has one.
+	// it's off by default, as reference logs don't have it
+	Annotate bool
+	Symbols  *symbols.Table
+
+	w   *bufio.Writer
+	ram *mem.RAM
+	mbc mem.MBC
+	err error
+}
+
+//New creates a tracer that writes to w.  The MBC, which may be nil, tells which ROM bank code is in
+func New(w io.Writer, ram *mem.RAM, mbc mem.MBC) *Tracer {
+	return &Tracer{w: bufio.NewWriter(w), ram: ram, mbc: mbc}
+}
+
+//Instruction logs the instruction about to run, if the filter lets it through
+func (t *Tracer) Instruction(r cpu.Registers) {
+	if t.err != nil {
+		return
+	}
+	var pcmem [4]byte
+	for i := range pcmem {
+		pcmem[i] = t.ram.Peek(r.PC + uint16(i))
+	}
+	bank := t.bank(r.PC)
+	if !t.Filter.match(r.PC, bank, pcmem[0]) {
+		return
+	}
+	_, t.err = fmt.Fprintf(t.w, "A:%02X F:%02X B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X PCMEM:%02X,%02X,%02X,%02X",
+		r.A, r.F, r.B, r.C, r.D, r.E, r.H, r.L, r.SP, r.PC, pcmem[0], pcmem[1], pcmem[2], pcmem[3])
+	if t.err == nil && t.Annotate {
+		inst, _ := disasm.Decode(pcmem[:], r.PC)
+		_, t.err = fmt.Fprintf(t.w, " ; %s", inst.Format(func(addr uint16) string {
+			return t.Symbols.Describe(t.bank(addr), addr)
+		}))
+		if name := t.Symbols.Describe(bank, r.PC); name != "" && t.err == nil {
+			_, t.err = fmt.Fprintf(t.w, " ; %s", name)
+		}
+	}
+	if t.err == nil {
+		t.err = t.w.WriteByte('\n')
+	}
+}
+
+//bank returns the ROM bank an address is in: 0 for 0000-3FFF, whichever is switched in for 4000-7FFF, and -1 for RAM
+func (t *Tracer) bank(addr uint16) int {
+	switch {
+	case addr < 0x4000:
+		return 0
+	case addr < 0x8000 && t.mbc != nil:
+		return t.mbc.ROMBank()
+	case addr < 0x8000:
+		return 1
+	}
+	return -1
+}
+
+//Flush writes out any buffered lines, and returns the first error writing them, if any
+func (t *Tracer) Flush() error {
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
@@ -0,0 +1,71 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/raidancampbell/goby/cartridge"
+	"github.com/raidancampbell/goby/gameboy"
+	"github.com/raidancampbell/goby/render"
+	"github.com/raidancampbell/goby/symbols"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer(t *testing.T) {
+	rom := make(cartridge.ROM, 0x8000)
+	for i := range rom {
+		rom[i] = 0x76
+	}
+	rom[0x0143], rom[0x0146], rom[0x0147], rom[0x0149] = 0, 0, 0, 0
+	copy(rom[0x0100:], []byte{
+		0x31, 0xFE, 0xFF, // LD SP,$FFFE
+		0xCD, 0x00, 0x02, // CALL $0200
+		0x76, // HALT
+	})
+	rom[0x0200] = 0xC9 // RET
+	gb := gameboy.New(&rom, gameboy.ModelDMG, &render.Headless{})
+
+	var out bytes.Buffer
+	tracer := New(&out, gb.RAM, gb.MBC)
+	tracer.Filter.PC = []Range{{From: 0x0100, To: 0x01FF}}
+	tracer.Annotate = true
+	var err error
+	tracer.Symbols, err = symbols.Parse(strings.NewReader("00:0100 Start\n00:0200 Sub\n"))
+	require.NoError(t, err)
+	gb.CPU.Trace = tracer.Instruction
+
+	require.NoError(t, gb.RunFrame())
+	require.NoError(t, tracer.Flush())
+	// the RET is filtered out, and nothing is logged while halted
+	assert.Equal(t, []string{
+		"A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:31,FE,FF,CD ; LD SP,$FFFE ; Start",
+		"A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0103 PCMEM:CD,00,02,76 ; CALL Sub ; Start+$3",
+		"A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0106 PCMEM:76,76,76,76 ; HALT ; Start+$6",
+	}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter("0150-01FF, 4000", "1,2", "CD,$C9")
+	require.NoError(t, err)
+	assert.Equal(t, Filter{
+		PC:      []Range{{0x0150, 0x01FF}, {0x4000, 0x4000}},
+		Banks:   []int{1, 2},
+		Opcodes: []byte{0xCD, 0xC9},
+	}, f)
+	assert.True(t, f.match(0x0150, 1, 0xCD))
+	assert.True(t, f.match(0x4000, 2, 0xC9))
+	assert.False(t, f.match(0x4001, 2, 0xC9))
+	assert.False(t, f.match(0x0150, 3, 0xC9))
+	assert.False(t, f.match(0x0150, 1, 0x00))
+
+	f, err = ParseFilter("", "", "")
+	require.NoError(t, err)
+	assert.True(t, f.match(0xC000, -1, 0x00))
+
+	for _, bad := range [][3]string{{"01FF-0150", "", ""}, {"xyz", "", ""}, {"", "q", ""}, {"", "", "100"}} {
+		_, err := ParseFilter(bad[0], bad[1], bad[2])
+		assert.Error(t, err, bad)
+	}
+}